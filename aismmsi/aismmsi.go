@@ -32,7 +32,7 @@ func DecodeMmsi(mmsi string) string {
 	}
 
 	msg := ""
-	mid := mmsi[0:3]
+	mid := Mid(mmsi)
 
 	// https://en.wikipedia.org/wiki/Maritime_Mobile_Service_Identity
 	// https://en.wikipedia.org/wiki/Maritime_identification_digits
@@ -43,24 +43,19 @@ func DecodeMmsi(mmsi string) string {
 		// 00: Coast radio station
 		if mmsi[1] == '0' {
 			msg += "Coast radio station "
-			mid = mmsi[2:5]
 		} else {
 			msg += "Ship group "
-			mid = mmsi[1:4]
 		}
 	case '1':
 		// 111: For use by SAR aircraft (111MIDaxx)
 		if mmsi[0:3] == "111" {
 			msg += "SAR aircraft "
-			mid = mmsi[3:6]
 		} else {
 			msg += "Unknown type 1 "
-			mid = mmsi[1:4]
 		}
 	case '8':
 		// Handheld VHF transceiver with DSC and GNSS
 		msg += "Handheld VHF "
-		mid = mmsi[1:4]
 	case '9':
 		// Devices using a free-form number identity:
 		//   Search and Rescue Transponders (970yyzzzz)
@@ -73,21 +68,16 @@ func DecodeMmsi(mmsi string) string {
 		switch mmsi[0:2] {
 		case "98":
 			msg += "craft associated with parent ship "
-			mid = mmsi[2:5]
 		case "99":
 			msg += "aid to navigation "
-			mid = mmsi[2:5]
 		default:
 			switch mmsi[0:3] {
 			case "970":
 				msg += "SAR transponder "
-				mid = mmsi[3:6]
 			case "972":
 				msg += "man overboard device "
-				mid = mmsi[3:6]
 			case "974":
 				msg += "EPIRB with AIS transmitter "
-				mid = mmsi[3:6]
 			}
 		}
 	}
@@ -68,3 +68,48 @@ func TestDecodeMmsi(t *testing.T) {
 		}
 	}
 }
+
+func TestMid(t *testing.T) {
+	testCases := []struct {
+		mmsi     string
+		expected string
+	}{
+		{"366990520", "366"},
+		{"041211111", "412"},
+		{"004121111", "412"},
+		{"111444111", "444"},
+		{"855511111", "555"},
+		{"987771111", "777"},
+		{"970777111", "777"},
+		{"12345678", ""},
+	}
+
+	for _, testCase := range testCases {
+		answer := Mid(testCase.mmsi)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %v expected %v, got %v", testCase.mmsi, testCase.expected, answer)
+		}
+	}
+}
+
+func TestCountry(t *testing.T) {
+	testCases := []struct {
+		mmsi     string
+		expected string
+	}{
+		{"366990520", "US"},
+		{"319762000", "KY"},
+		{"538007561", "MH"},
+		{"477123400", "HK"},
+		{"111338111", "US"},
+		{"200111111", ""},
+		{"a23456789", ""},
+	}
+
+	for _, testCase := range testCases {
+		answer := Country(testCase.mmsi)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %v expected %v, got %v", testCase.mmsi, testCase.expected, answer)
+		}
+	}
+}
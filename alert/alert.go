@@ -4,17 +4,20 @@ package alert
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math"
+	"io"
+	"os"
 	"strings"
 	"time"
 
-	"github.com/erikbryant/beepspeak"
-	"github.com/erikbryant/shipahoy/aismmsi"
+	"github.com/erikbryant/shipahoy/clearance"
 	"github.com/erikbryant/shipahoy/database"
+	"github.com/erikbryant/shipahoy/speech"
 )
 
 // readableName makes a string more human readable by removing all non alphanumeric and non-punctuation.
+// How to pronounce what is left is up to the pronunciation dictionary.
 func readableName(text string) string {
 	text = strings.TrimSpace(text)
 
@@ -26,42 +29,9 @@ func readableName(text string) string {
 	text = strings.ReplaceAll(text, "]", " ")
 	text = strings.ReplaceAll(text, "\"", "")
 
-	// Specific ships we have seen that read poorly.
-	text = strings.ReplaceAll(text, "SEASPAN HAMBURG", "SEA SPAN HAMBURG")
-	text = strings.ReplaceAll(text, "RO-ROZAFER", "RO-RO ZAFER")
-	text = strings.ReplaceAll(text, "RO-RO ", "ROW ROW ")
-	text = strings.ReplaceAll(text, "ERISORT", "AIRY SORT")
-	text = strings.ReplaceAll(text, "T0WBOATUS_ALAMEDA", "TOW BOAT U S ALAMEDA")
-	text = strings.ReplaceAll(text, "SAILDRONE", "SAIL DRONE")
-	text = strings.ReplaceAll(text, "JOHNZIPSER", "JOHN ZIPSER")
-	text = strings.ReplaceAll(text, "EKSPRES", "EXPRESS")
-	// ASSATEAGUE
-
 	return text
 }
 
-// readableCourse takes a compass heading and formats it in a human speakable way.
-func readableCourse(heading float64) string {
-	course := int(math.Round(heading))
-	courseText := ""
-
-	if course%100 == 0 {
-		courseText = fmt.Sprintf("%d", course)
-	} else if course < 100 {
-		courseText = fmt.Sprintf("%d", course)
-	} else {
-		hundreds := course / 100
-		tens := course % 100
-		if tens < 10 {
-			courseText = fmt.Sprintf("%dO%d", hundreds, tens)
-		} else {
-			courseText = fmt.Sprintf("%d %d", hundreds, tens)
-		}
-	}
-
-	return courseText
-}
-
 // prettify formats the input for printing.
 func prettify(i interface{}) string {
 	s, err := json.MarshalIndent(i, "", " ")
@@ -72,70 +42,74 @@ func prettify(i interface{}) string {
 	return string(s)
 }
 
-// Alert prints a message and plays an alert tone.
-func Alert(details database.Ship) error {
-	fmt.Printf(
-		"\nShip Ahoy!  %s  %s\n%+v\n\n",
-		time.Now().Format("Mon Jan 2 15:04:05"),
-		aismmsi.DecodeMmsi(details.MMSI),
-		prettify(details),
-	)
-
-	var sound string
-	if strings.Contains(strings.ToLower(details.Type), "vehicle") {
-		sound = "meep.wav"
-	} else if strings.Contains(strings.ToLower(details.Type), "pilot") {
-		sound = "pilot.mp3"
-	} else {
-		sound = "horn.mp3"
-	}
-	err := beepspeak.Play("./alert/" + sound)
-	if err != nil {
-		return err
-	}
+// Console prints events.
+type Console struct {
+	out io.Writer
+}
 
-	summary := fmt.Sprintf("Ship ahoy! %s. %s. Course %s degrees.", readableName(details.Name), details.Type, readableCourse(details.Course))
+// NewConsole returns a notifier that prints to stdout.
+func NewConsole() *Console {
+	return &Console{out: os.Stdout}
+}
 
-	// Hearing, "eleven point zero knots" sounds awkward. Remove the "point zero".
-	if math.Trunc(details.Speed) == details.Speed {
-		summary = fmt.Sprintf("%s Speed %3.0f knots. ", summary, math.Trunc(details.Speed))
-	} else {
-		summary = fmt.Sprintf("%s Speed %3.1f knots. ", summary, details.Speed)
-	}
+// Name returns "console".
+func (c *Console) Name() string {
+	return "console"
+}
 
-	// Read out interesting navigational statuses.
-	switch details.NavigationalStatus {
-	case 2:
-		summary += "Not under command. "
-	case 3:
-		summary += "Restricted maneuverability. "
-	case 4:
-		summary += "Constrained by her draught. "
-	case 6:
-		summary += "Aground. "
-	case 7:
-		summary += "Engaged in fishing. "
-	case 11:
-		summary += "Power-driven vessel towing astern. "
-	case 12:
-		summary += "Power-driven vessel pushing ahead or towing alongside. "
-	case 14:
-		summary += "AIS-SART, MOB-AIS, or EPIRB-AIS. "
-	}
+// Notify prints what each observer wants printed about the event.
+func (c *Console) Notify(e Event) error {
+	found, err := messages.render(ConsoleChannel, e)
 
-	switch details.Sightings {
-	case 0:
-		summary += "This is the first sighting. "
-	case 1:
-		summary += "One previous sighting. "
-	default:
-		summary = fmt.Sprintf("%s %d previous sightings.", summary, details.Sightings)
+	for _, r := range found {
+		if err == nil {
+			_, err = io.WriteString(c.out, r.text)
+		}
 	}
 
-	err = beepspeak.Say(summary)
-	if err != nil {
-		return err
+	return err
+}
+
+// Speech reads events out loud.
+type Speech struct {
+	say func(u speech.Utterance) error
+}
+
+// NewSpeech returns a notifier that speaks with a provider.
+func NewSpeech(voice speech.Provider) *Speech {
+	return &Speech{say: func(u speech.Utterance) error { return speech.Speak(voice, u) }}
+}
+
+// Name returns "speech".
+func (s *Speech) Name() string {
+	return "speech"
+}
+
+// Notify reads out what each observer wants said about the event.
+func (s *Speech) Notify(e Event) error {
+	found, err := messages.render(SpeechChannel, e)
+
+	errs := []error{err}
+	for _, r := range found {
+		errs = append(errs, s.say(utterance(r.text)))
 	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// Alert prints a message, plays an alert tone and reads out a sighting.
+// Any bridges or channels the ship only just fits through are announced
+// too.
+func Alert(details database.Ship, warnings ...clearance.Warning) error {
+	return dispatcher.Notify(Event{Kind: Sighting, Time: time.Now(), Ship: details, Warnings: warnings})
+}
+
+// Anomaly prints a message and announces a suspicious ship report.
+func Anomaly(details database.Ship, anomaly database.Anomaly) error {
+	return dispatcher.Notify(Event{Kind: Suspicious, Time: time.Now(), Ship: details, Anomaly: anomaly})
+}
+
+// StorageFailure prints a message and announces that ships are not being saved.
+func StorageFailure(err error) error {
+	return dispatcher.Notify(Event{Kind: DatabaseDown, Time: time.Now(), Err: err})
 }
@@ -1,3 +1,102 @@
 package alert
 
-// TODO: write tests
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erikbryant/shipahoy/database"
+	"github.com/erikbryant/shipahoy/speech"
+)
+
+// recorder is a notifier that remembers what it was told and can be made to fail.
+type recorder struct {
+	name   string
+	events []Event
+	err    error
+}
+
+func (r *recorder) Name() string { return r.name }
+
+func (r *recorder) Notify(e Event) error {
+	r.events = append(r.events, e)
+	return r.err
+}
+
+func TestDispatcher(t *testing.T) {
+	first := &recorder{name: "first", err: errors.New("speaker unplugged")}
+	second := &recorder{name: "second"}
+	d := NewDispatcher(first)
+	d.Add(second)
+
+	err := d.Notify(Event{Kind: Sighting, Ship: database.Ship{MMSI: "366990520"}})
+	if err == nil || !strings.Contains(err.Error(), "first: speaker unplugged") {
+		t.Errorf("ERROR: Expected the first notifier's error, got %v", err)
+	}
+	if len(first.events) != 1 || len(second.events) != 1 || second.events[0].Ship.MMSI != "366990520" {
+		t.Errorf("ERROR: Expected both notifiers to hear of the sighting, got %v %v", first.events, second.events)
+	}
+
+	first.err = nil
+	err = d.Notify(Event{Kind: DatabaseDown})
+	if err != nil {
+		t.Errorf("ERROR: Expected no error, got %v", err)
+	}
+}
+
+func TestConsole(t *testing.T) {
+	var out bytes.Buffer
+	c := &Console{out: &out}
+	when := time.Date(2024, time.March, 1, 8, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		e        Event
+		expected string
+	}{
+		{Event{Kind: Sighting, Time: when, Ship: database.Ship{MMSI: "366990520", Name: "DEL NORTE"}}, "Ship Ahoy!  Fri Mar 1 08:00:00"},
+		{Event{Kind: Suspicious, Time: when, Ship: database.Ship{MMSI: "319762000"}, Anomaly: database.Anomaly{Kind: "impossible speed", Detail: "102.3 knots"}}, "319762000  impossible speed: 102.3 knots"},
+		{Event{Kind: DatabaseDown, Time: when, Err: errors.New("connection refused")}, "Database down!  Fri Mar 1 08:00:00  connection refused"},
+	}
+
+	for _, testCase := range testCases {
+		out.Reset()
+		err := c.Notify(testCase.e)
+		if err != nil || !strings.Contains(out.String(), testCase.expected) {
+			t.Errorf("ERROR: For %s expected %q, got %q %v", testCase.e.Kind, testCase.expected, out.String(), err)
+		}
+	}
+}
+
+func TestSoundAndSpeech(t *testing.T) {
+	var played, said []string
+	sound := DefaultSound()
+	sound.cache = t.TempDir()
+	sound.play = func(file string, volume float64) error { played = append(played, filepath.Base(file)); return nil }
+	speech := &Speech{say: func(u speech.Utterance) error { said = append(said, u.Text); return nil }}
+	d := NewDispatcher(sound, speech)
+
+	d.Notify(Event{Kind: Sighting, Ship: database.Ship{Name: "HOEGH TRACER", Type: "Vehicles carrier", Course: 270, Speed: 11, Sightings: 1}})
+	d.Notify(Event{Kind: Sighting, Ship: database.Ship{Name: "SF BAR PILOT", Type: "Pilot vessel", Course: 45, Speed: 9.5}})
+	d.Notify(Event{Kind: Suspicious, Ship: database.Ship{Name: "SAINT NICHOLAS"}, Anomaly: database.Anomaly{Kind: "impossible speed"}})
+
+	if len(played) != 2 || played[0] != "meep.wav" || played[1] != "pilot.mp3" {
+		t.Errorf("ERROR: Expected a tone for each sighting, got %v", played)
+	}
+
+	expected := []string{
+		"Ship ahoy! HOEGH TRACER. Vehicles carrier. Course two seven zero degrees. Speed eleven knots. One previous sighting.",
+		"Ship ahoy! SF BAR PILOT. Pilot vessel. Course zero four five degrees. Speed nine point five knots. This is the first sighting.",
+		"Anomaly. SAINT NICHOLAS. impossible speed.",
+	}
+	if len(said) != len(expected) {
+		t.Fatalf("ERROR: Expected %d things said, got %v", len(expected), said)
+	}
+	for i := range expected {
+		if said[i] != expected[i] {
+			t.Errorf("ERROR: Expected %q, got %q", expected[i], said[i])
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package alert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/erikbryant/shipahoy/database"
+)
+
+// EmailConfig says how to send email and who gets it.
+type EmailConfig struct {
+	Host       string   `json:"host"` // SMTP server; empty means do not send email
+	Port       int      `json:"port"`
+	Username   string   `json:"username"` // empty means do not authenticate
+	Password   string   `json:"password"`
+	From       string   `json:"from"`
+	To         []string `json:"to"`
+	MinScore   int      `json:"minScore"`   // email sightings scoring at least this straight away
+	DigestHour int      `json:"digestHour"` // local hour to send the day's digest; -1 for none
+}
+
+// DefaultEmailConfig returns settings with no server, so nothing is sent.
+func DefaultEmailConfig() EmailConfig {
+	return EmailConfig{
+		Port:       587,
+		From:       "shipahoy@localhost",
+		MinScore:   60,
+		DigestHour: 7,
+	}
+}
+
+// Email sends high scoring sightings, and a daily digest, by email.
+type Email struct {
+	cfg      EmailConfig
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewEmail returns a notifier that sends email through the configured server.
+func NewEmail(cfg EmailConfig) *Email {
+	return &Email{cfg: cfg, sendMail: smtp.SendMail}
+}
+
+// Name returns "email".
+func (m *Email) Name() string {
+	return "email"
+}
+
+// send sends a plain text message.
+func (m *Email) send(to []string, subject, body string) error {
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+
+	return m.sendMail(addr, auth, m.cfg.From, to, msg.Bytes())
+}
+
+// Notify emails sightings that score at least the configured minimum, and
+// tells people when the database is down. Other events are not emailed.
+// Each observer gets their own message, to their own addresses if they
+// have any.
+func (m *Email) Notify(e Event) error {
+	switch e.Kind {
+	case Sighting:
+		if e.Score < m.cfg.MinScore {
+			return nil
+		}
+	case DatabaseDown:
+	default:
+		return nil
+	}
+
+	found, err := messages.render(NotificationChannel, e)
+
+	errs := []error{err}
+	for _, r := range found {
+		to := r.observer.Email
+		if len(to) == 0 {
+			to = m.cfg.To
+		}
+		subject, body, _ := strings.Cut(r.text, "\n")
+		errs = append(errs, m.send(to, subject, body))
+	}
+
+	return errors.Join(errs...)
+}
+
+// size describes a ship's dimensions.
+func size(details database.Ship) string {
+	if details.Length <= 0 {
+		return "size unknown"
+	}
+	if details.Beam <= 0 {
+		return fmt.Sprintf("%d m", details.Length)
+	}
+	return fmt.Sprintf("%d m x %d m", details.Length, details.Beam)
+}
+
+// inView describes how long a ship was, or is expected to be, in view.
+func inView(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return d.Round(time.Minute).String()
+}
+
+// DigestEntry is one sighting in a digest.
+type DigestEntry struct {
+	Ship   database.Ship
+	Time   time.Time
+	InView time.Duration // zero if we cannot tell
+	First  bool          // never seen before
+}
+
+// Digest is every sighting in a day.
+type Digest struct {
+	Day     time.Time
+	Entries []DigestEntry
+}
+
+// table writes entries in aligned columns.
+func table(b *bytes.Buffer, entries []DigestEntry) {
+	w := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  Time\tName\tType\tFlag\tSize\tIn view\tLink")
+	for _, e := range entries {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Time.Format("15:04"), e.Ship.Name, e.Ship.Type, e.Ship.Flag, size(e.Ship), inView(e.InView), e.Ship.DirectLink)
+	}
+	w.Flush()
+}
+
+// String formats the digest as the body of an email: the firsts, then
+// every sighting.
+func (d Digest) String() string {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "Ships seen on %s\n", d.Day.Format("Monday, January 2, 2006"))
+	if len(d.Entries) == 0 {
+		b.WriteString("\nNo ships.\n")
+		return b.String()
+	}
+
+	var firsts []DigestEntry
+	for _, e := range d.Entries {
+		if e.First {
+			firsts = append(firsts, e)
+		}
+	}
+	if len(firsts) > 0 {
+		fmt.Fprintf(&b, "\nFirsts (%d never seen before)\n\n", len(firsts))
+		table(&b, firsts)
+	}
+
+	fmt.Fprintf(&b, "\nAll sightings (%d)\n\n", len(d.Entries))
+	table(&b, d.Entries)
+
+	return b.String()
+}
+
+// SendDigest emails a digest.
+func (m *Email) SendDigest(d Digest) error {
+	subject := fmt.Sprintf("Ship ahoy digest for %s: %d ships", d.Day.Format("Mon Jan 2"), len(d.Entries))
+
+	return m.send(m.cfg.To, subject, d.String())
+}
+
+// NextDigest returns when the digest after now is due.
+func (m *Email) NextDigest(now time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), m.cfg.DigestHour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next
+}
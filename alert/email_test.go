@@ -0,0 +1,184 @@
+package alert
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erikbryant/shipahoy/database"
+)
+
+// smtpSink is a local SMTP server that accepts every message and keeps it.
+type smtpSink struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []string
+	done     sync.WaitGroup
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ERROR: Unable to listen: %v", err)
+	}
+
+	s := &smtpSink{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.done.Add(1)
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+
+	return s
+}
+
+// serve speaks just enough SMTP for net/smtp.
+func (s *smtpSink) serve(conn net.Conn) {
+	defer s.done.Done()
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 sink ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 sink")
+		case command == "DATA":
+			reply("354 go ahead")
+			var msg strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				msg.WriteString(line)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, msg.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// config returns settings that send to the sink.
+func (s *smtpSink) config() EmailConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	cfg := DefaultEmailConfig()
+	cfg.Host = host
+	cfg.Port, _ = strconv.Atoi(port)
+	cfg.To = []string{"crew@example.com"}
+	return cfg
+}
+
+// received returns the messages the sink has been sent.
+func (s *smtpSink) received() []string {
+	s.done.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.messages
+}
+
+func TestEmailNotify(t *testing.T) {
+	sink := newSMTPSink(t)
+	m := NewEmail(sink.config())
+
+	ship := database.Ship{MMSI: "366999712", Name: "EVER GIVEN", Type: "Cargo", Flag: "PA", Length: 400, Beam: 59, DirectLink: "https://example.com/366999712"}
+
+	err := m.Notify(Event{Kind: Sighting, Time: time.Now(), Ship: ship, Zone: "apartment view", Dwell: 14 * time.Minute, Score: 30})
+	if err != nil {
+		t.Errorf("ERROR: Unable to notify: %v", err)
+	}
+	err = m.Notify(Event{Kind: Sighting, Time: time.Now(), Ship: ship, Zone: "apartment view", Dwell: 14 * time.Minute, Score: 90})
+	if err != nil {
+		t.Errorf("ERROR: Unable to notify: %v", err)
+	}
+
+	messages := sink.received()
+	if len(messages) != 1 {
+		t.Fatalf("ERROR: Expected only the high scoring sighting to be emailed, got %d", len(messages))
+	}
+	for _, want := range []string{"Subject: Ship ahoy: EVER GIVEN (Cargo)", "To: crew@example.com", "400 m x 59 m", "apartment view, for about 14m0s", "https://example.com/366999712"} {
+		if !strings.Contains(messages[0], want) {
+			t.Errorf("ERROR: Expected %q in\n%s", want, messages[0])
+		}
+	}
+}
+
+func TestDigest(t *testing.T) {
+	day := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	digest := Digest{
+		Day: day,
+		Entries: []DigestEntry{
+			{Ship: database.Ship{Name: "HAWK", Type: "Tug", Flag: "US"}, Time: day.Add(9 * time.Hour), InView: 11 * time.Minute},
+			{Ship: database.Ship{Name: "EVER GIVEN", Type: "Cargo", Flag: "PA", Length: 400, Beam: 59}, Time: day.Add(13 * time.Hour), First: true},
+		},
+	}
+
+	sink := newSMTPSink(t)
+	err := NewEmail(sink.config()).SendDigest(digest)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to send digest: %v", err)
+	}
+
+	messages := sink.received()
+	if len(messages) != 1 {
+		t.Fatalf("ERROR: Expected a digest, got %d messages", len(messages))
+	}
+	msg := messages[0]
+	for _, want := range []string{"Subject: Ship ahoy digest for Sun Oct 18: 2 ships", "Firsts (1 never seen before)", "All sightings (2)", "11m0s", "size unknown"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("ERROR: Expected %q in\n%s", want, msg)
+		}
+	}
+	firsts := msg[strings.Index(msg, "Firsts"):strings.Index(msg, "All sightings")]
+	if strings.Contains(firsts, "HAWK") || !strings.Contains(firsts, "EVER GIVEN") {
+		t.Errorf("ERROR: Expected only new ships under firsts, got\n%s", firsts)
+	}
+}
+
+func TestNextDigest(t *testing.T) {
+	m := NewEmail(EmailConfig{DigestHour: 7})
+
+	testCases := []struct {
+		now      time.Time
+		expected time.Time
+	}{
+		{time.Date(2026, 10, 19, 6, 59, 0, 0, time.UTC), time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC), time.Date(2026, 10, 20, 7, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 12, 31, 23, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 7, 0, 0, 0, time.UTC)},
+	}
+
+	for _, testCase := range testCases {
+		answer := m.NextDigest(testCase.now)
+		if !answer.Equal(testCase.expected) {
+			t.Errorf("ERROR: For %v expected %v, got %v", testCase.now, testCase.expected, answer)
+		}
+	}
+}
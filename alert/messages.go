@@ -0,0 +1,468 @@
+package alert
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/erikbryant/shipahoy/aismmsi"
+	"github.com/erikbryant/shipahoy/clearance"
+	"github.com/erikbryant/shipahoy/database"
+	"github.com/erikbryant/shipahoy/landmark"
+	"github.com/erikbryant/shipahoy/speech"
+)
+
+// Channels an observer can hear about events on.
+const (
+	SpeechChannel       = "speech"       // read out loud
+	ConsoleChannel      = "console"      // printed
+	NotificationChannel = "notification" // emailed
+)
+
+var channels = []string{SpeechChannel, ConsoleChannel, NotificationChannel}
+
+// How much an observer wants to hear.
+const (
+	Brief  = "brief"
+	Normal = "normal"
+	Full   = "full"
+)
+
+// Units lengths can be given in.
+const (
+	Metric   = "metric"
+	Imperial = "imperial"
+)
+
+// Observer is someone who hears about events, in their own language and
+// at their own level of detail.
+type Observer struct {
+	Name     string   `json:"name"`
+	Language string   `json:"language"` // a catalog in alert/messages, such as "en" or "es"; empty means "en"
+	Detail   string   `json:"detail"`   // Brief, Normal or Full; empty means Normal
+	Channels []string `json:"channels"` // which of speech, console and notification they get; empty means console
+	Email    []string `json:"email"`    // where their notifications go, instead of the configured recipients
+	Units    string   `json:"units"`    // Metric or Imperial, for ship lengths; empty means Metric
+	Facing   *float64 `json:"facing"`   // degrees true they look out toward; if set, ships are also placed by the clock
+
+	// Templates replace the defaults, keyed by channel and event kind,
+	// such as "speech.sighting". They may use the default templates,
+	// named by kind and detail, such as {{template "sighting.brief" .}}.
+	Templates map[string]string `json:"templates"`
+}
+
+// WithDefaults returns the observer with the settings left empty filled
+// in. One with no channels gets the console.
+func (o Observer) WithDefaults() Observer {
+	if o.Language == "" {
+		o.Language = "en"
+	}
+	if o.Detail == "" {
+		o.Detail = Normal
+	}
+	if o.Units == "" {
+		o.Units = Metric
+	}
+	if len(o.Channels) == 0 {
+		o.Channels = []string{ConsoleChannel}
+	}
+
+	return o
+}
+
+// DefaultObservers returns one observer who hears everything, in English.
+func DefaultObservers() []Observer {
+	return []Observer{
+		{Name: "household", Language: "en", Detail: Normal, Channels: channels},
+	}
+}
+
+// Message is what templates are given: the event, and who it is for.
+type Message struct {
+	Event
+	Observer string
+	Language string
+}
+
+//go:embed templates/*.tmpl messages/*.json
+var assets embed.FS
+
+// catalog holds the phrases of a language, by key.
+type catalog map[string]string
+
+// loadCatalog returns the phrases of a language, falling back to English
+// for any it lacks.
+func loadCatalog(language string) (catalog, error) {
+	c := catalog{}
+
+	for _, lang := range []string{"en", language} {
+		contents, err := assets.ReadFile("messages/" + lang + ".json")
+		if err != nil {
+			return nil, fmt.Errorf("no messages for language %q", language)
+		}
+		err = json.Unmarshal(contents, &c)
+		if err != nil {
+			return nil, fmt.Errorf("messages/%s.json: %w", lang, err)
+		}
+	}
+
+	return c, nil
+}
+
+// t returns the phrase for a key, formatted with args. Keys without a
+// phrase are returned as they are.
+func (c catalog) t(key string, args ...interface{}) string {
+	phrase, ok := c[key]
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return phrase
+	}
+
+	return fmt.Sprintf(phrase, args...)
+}
+
+// Speech templates mark the parts that SSML treats specially, such as
+// names and call signs. The marks are split out again by utterance.
+const (
+	markStart = "\x00"
+	markSep   = "\x01"
+)
+
+// mark returns a mark of a kind, with its arguments.
+func mark(kind string, args ...string) string {
+	return markStart + kind + markSep + strings.Join(args, markSep) + markStart
+}
+
+// utterance turns rendered speech, with its marks, into something to say.
+func utterance(rendered string) speech.Utterance {
+	b := speech.NewBuilder(pronunciations)
+
+	for i, part := range strings.Split(rendered, markStart) {
+		if i%2 == 0 {
+			b.Text(part)
+			continue
+		}
+		fields := strings.Split(part, markSep)
+		switch fields[0] {
+		case "name":
+			b.Name(fields[1])
+		case "say-as":
+			b.SayAs(fields[1], fields[2], fields[3])
+		case "chars":
+			b.Characters(fields[1])
+		case "pause":
+			b.Pause(300 * time.Millisecond)
+		}
+	}
+
+	return b.Utterance()
+}
+
+// knots formats a speed. Whole numbers have no decimal.
+func knots(speed float64) string {
+	if math.Trunc(speed) == speed {
+		return strconv.FormatFloat(speed, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(speed, 'f', 1, 64)
+}
+
+// toFeet converts a length in meters to the nearest foot.
+func toFeet(meters int) int {
+	return int(math.Round(float64(meters) / 0.3048))
+}
+
+// funcs returns the functions templates can use, in an observer's
+// language and units. For speech they mark what SSML says specially, and
+// in English spell numbers out the way they are said on the radio.
+func funcs(c catalog, o Observer, spoken bool) template.FuncMap {
+	language := o.Language
+	imperial := o.Units == Imperial
+
+	m := template.FuncMap{
+		"t": c.t,
+		"kind": func(shipType string) string {
+			if _, ok := c["type."+shipType]; ok {
+				return c.t("type." + shipType)
+			}
+			return shipType
+		},
+		"status": func(status int) string {
+			if _, ok := c["status."+strconv.Itoa(status)]; ok {
+				return c.t("status." + strconv.Itoa(status))
+			}
+			return ""
+		},
+		"when":     func(t time.Time) string { return t.Format("Mon Jan 2 15:04:05") },
+		"mmsi":     aismmsi.DecodeMmsi,
+		"json":     prettify,
+		"duration": inView,
+		"sentence": func(s string) string {
+			r, n := utf8.DecodeRuneInString(s)
+			return string(unicode.ToUpper(r)) + s[n:]
+		},
+		"size": func(details database.Ship) string {
+			switch {
+			case details.Length <= 0:
+				return c.t("size.unknown")
+			case !imperial:
+				return size(details)
+			case details.Beam <= 0:
+				return fmt.Sprintf("%d ft", toFeet(details.Length))
+			}
+			return fmt.Sprintf("%d ft x %d ft", toFeet(details.Length), toFeet(details.Beam))
+		},
+
+		// Said differently.
+		"name":   func(name string) string { return name },
+		"course": func(course float64) string { return fmt.Sprintf("%03d", (int(math.Round(course))%360+360)%360) },
+		"knots":  func(speed float64) string { return c.t("knots", knots(speed)) },
+		"feet":   func(f float64) string { return strconv.FormatFloat(f, 'f', 1, 64) },
+		"chars":  func(s string) string { return s },
+		"number": func(n int64) string { return strconv.FormatInt(n, 10) },
+		"pause":  func() string { return "" },
+	}
+
+	switch {
+	case spoken && language == "en":
+		m["course"] = speech.Bearing
+		m["knots"] = speech.Speed
+		m["feet"] = speech.Decimal
+		m["number"] = speech.Words
+	case spoken:
+		text := m["course"].(func(float64) string)
+		m["course"] = func(course float64) string {
+			digits := text(course)
+			return mark("say-as", "digits", digits, strings.Join(strings.Split(digits, ""), " "))
+		}
+		m["number"] = func(n int64) string {
+			s := strconv.FormatInt(n, 10)
+			return mark("say-as", "cardinal", s, s)
+		}
+	}
+	if spoken {
+		m["name"] = func(name string) string { return mark("name", readableName(name)) }
+		m["chars"] = func(s string) string { return mark("chars", s) }
+		m["pause"] = func() string { return mark("pause") }
+	}
+
+	m["miles"] = func(nm float64) string { return c.t("miles", strconv.FormatFloat(nm, 'f', 1, 64)) }
+	if spoken && language == "en" {
+		m["miles"] = speech.Distance
+	}
+	number := m["number"].(func(int64) string)
+
+	m["length"] = func(meters int) string {
+		switch {
+		case spoken && language == "en":
+			return speech.Length(float64(meters), imperial)
+		case spoken && imperial:
+			return c.t("length.imperial", number(int64(toFeet(meters))))
+		case spoken:
+			return c.t("length.metric", number(int64(meters)))
+		case imperial:
+			return fmt.Sprintf("%d ft", toFeet(meters))
+		}
+		return fmt.Sprintf("%d m", meters)
+	}
+	m["clock"] = func(bearing float64) string {
+		switch {
+		case o.Facing == nil:
+			return ""
+		case spoken && language == "en":
+			return speech.Clock(bearing, *o.Facing)
+		}
+		hour := speech.ClockHour(bearing, *o.Facing)
+		if _, ok := c["clock."+strconv.FormatInt(hour, 10)]; ok {
+			return c.t("clock."+strconv.FormatInt(hour, 10), number(hour))
+		}
+		return c.t("clock", number(hour))
+	}
+	m["position"] = position(c, m["course"].(func(float64) string), m["miles"].(func(float64) string), m["clock"].(func(float64) string))
+
+	m["minutes"] = func(d time.Duration) string { return number(int64(d.Round(time.Minute).Minutes())) }
+	m["warning"] = func(w clearance.Warning) string {
+		switch w := w.(type) {
+		case clearance.Transit:
+			if w.Level == clearance.TooLow {
+				return c.t("warning."+w.Level, w.Bridge.Name)
+			}
+			return c.t("warning."+w.Level, w.Bridge.Name, number(int64(math.Round(w.Margin))))
+		case clearance.KeelTransit:
+			if w.Level == clearance.TooShallow {
+				return c.t("warning."+w.Level, w.Channel.Name)
+			}
+			return c.t("warning."+w.Level, w.Channel.Name, number(int64(math.Round(w.UKC))))
+		}
+		return w.String()
+	}
+	m["anomaly"] = func(kind string) string {
+		if _, ok := c["anomaly."+kind]; ok {
+			return c.t("anomaly." + kind)
+		}
+		return kind
+	}
+	m["sightings"] = func(n int64) string {
+		switch n {
+		case 0:
+			return c.t("sightings.0")
+		case 1:
+			return c.t("sightings.1")
+		default:
+			return c.t("sightings.n", number(n))
+		}
+	}
+
+	return m
+}
+
+// position returns a function that says where a ship is, by the landmark
+// nearest it and from the observer, with bearings and distances said by
+// course and miles, and by clock if the observer faces a known way.
+func position(c catalog, course, miles, clock func(float64) string) func(landmark.Description) string {
+	return func(d landmark.Description) string {
+		var parts []string
+
+		switch {
+		case d.Landmark == "":
+		case d.Approaching:
+			parts = append(parts, c.t("position.approaching", d.Landmark))
+		case d.Underway:
+			parts = append(parts, c.t("position.passing", c.t("compass."+d.Side), d.Landmark))
+		default:
+			parts = append(parts, c.t("position.stopped", c.t("compass."+d.Side), d.Landmark))
+		}
+		if d.Underway {
+			parts = append(parts, c.t("position.heading", c.t("compass."+d.Heading)))
+		}
+		parts = append(parts, c.t("position.from you", course(d.Bearing), miles(d.Distance)))
+		if hour := clock(d.Bearing); hour != "" {
+			parts = append(parts, c.t("position.clock", hour))
+		}
+
+		return strings.Join(parts, ", ")
+	}
+}
+
+// observer is an Observer with its templates ready to use.
+type observer struct {
+	Observer
+	templates map[string]*template.Template // by channel
+}
+
+// Messages turns events into what each observer hears.
+type Messages struct {
+	observers []*observer
+}
+
+// NewMessages parses the templates for each observer.
+func NewMessages(observers []Observer) (*Messages, error) {
+	m := &Messages{}
+
+	for _, o := range observers {
+		o = o.WithDefaults()
+		switch o.Detail {
+		case Brief, Normal, Full:
+		default:
+			return nil, fmt.Errorf("observer %s: unknown detail %q", o.Name, o.Detail)
+		}
+		if o.Units != Metric && o.Units != Imperial {
+			return nil, fmt.Errorf("observer %s: unknown units %q", o.Name, o.Units)
+		}
+		c, err := loadCatalog(o.Language)
+		if err != nil {
+			return nil, fmt.Errorf("observer %s: %w", o.Name, err)
+		}
+
+		parsed := &observer{Observer: o, templates: map[string]*template.Template{}}
+		for _, channel := range o.Channels {
+			if !slices.Contains(channels, channel) {
+				return nil, fmt.Errorf("observer %s: unknown channel %q", o.Name, channel)
+			}
+			tmpl, err := template.New(channel).Funcs(funcs(c, o, channel == SpeechChannel)).ParseFS(assets, "templates/"+channel+".tmpl")
+			if err != nil {
+				return nil, fmt.Errorf("observer %s: %w", o.Name, err)
+			}
+			parsed.templates[channel] = tmpl
+		}
+		for key, text := range o.Templates {
+			channel, _, _ := strings.Cut(key, ".")
+			tmpl, ok := parsed.templates[channel]
+			if !ok {
+				return nil, fmt.Errorf("observer %s: template %s is for a channel they do not get", o.Name, key)
+			}
+			_, err := tmpl.New(key).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("observer %s: %w", o.Name, err)
+			}
+		}
+
+		m.observers = append(m.observers, parsed)
+	}
+
+	return m, nil
+}
+
+// DefaultMessages returns the messages of DefaultObservers.
+func DefaultMessages() *Messages {
+	m, err := NewMessages(DefaultObservers())
+	if err != nil {
+		panic(fmt.Sprintf("default messages: %v", err))
+	}
+
+	return m
+}
+
+// rendered is what an observer hears about an event on a channel.
+type rendered struct {
+	observer Observer
+	text     string
+}
+
+// render returns what each observer on a channel hears about an event.
+// Observers with no template for the event hear nothing. One observer's
+// template failing does not stop the rest; their errors are returned
+// together.
+func (m *Messages) render(channel string, e Event) ([]rendered, error) {
+	var found []rendered
+	var errs []error
+
+	for _, o := range m.observers {
+		tmpl, ok := o.templates[channel]
+		if !ok {
+			continue
+		}
+
+		var chosen *template.Template
+		for _, name := range []string{channel + "." + e.Kind, e.Kind + "." + o.Detail, e.Kind + "." + Normal} {
+			chosen = tmpl.Lookup(name)
+			if chosen != nil {
+				break
+			}
+		}
+		if chosen == nil {
+			continue
+		}
+
+		var b bytes.Buffer
+		err := chosen.Execute(&b, Message{Event: e, Observer: o.Name, Language: o.Language})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", o.Name, err))
+			continue
+		}
+		found = append(found, rendered{observer: o.Observer, text: b.String()})
+	}
+
+	return found, errors.Join(errs...)
+}
@@ -0,0 +1,221 @@
+package alert
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erikbryant/shipahoy/anomaly"
+	"github.com/erikbryant/shipahoy/clearance"
+	"github.com/erikbryant/shipahoy/database"
+	"github.com/erikbryant/shipahoy/landmark"
+)
+
+// renderOne returns what the only observer hears about an event on a channel.
+func renderOne(t *testing.T, o Observer, channel string, e Event) string {
+	t.Helper()
+
+	m, err := NewMessages([]Observer{o})
+	if err != nil {
+		t.Fatalf("ERROR: Unable to parse messages for %s: %v", o.Name, err)
+	}
+	found, err := m.render(channel, e)
+	if err != nil || len(found) != 1 {
+		t.Fatalf("ERROR: Expected one rendering for %s, got %v %v", o.Name, found, err)
+	}
+
+	return found[0].text
+}
+
+func TestSpeechSSML(t *testing.T) {
+	o := Observer{Name: "test", Channels: []string{SpeechChannel}}
+	e := Event{Kind: Sighting, Ship: database.Ship{Name: "SAILDRONE_1053", Type: "Sailing", Course: 5, Speed: 3, CallSign: "WDK2", Sightings: 12}}
+	u := utterance(renderOne(t, o, SpeechChannel, e))
+
+	if !strings.HasPrefix(u.Text, "Ship ahoy! SAIL DRONE 1053. Sailing. Course zero zero five degrees. Speed three knots.") || !strings.Contains(u.Text, "Call sign W D K 2. ") {
+		t.Errorf("ERROR: Unexpected text %q", u.Text)
+	}
+	for _, want := range []string{
+		`<say-as interpret-as="characters">WDK2</say-as>`,
+		`twelve previous sightings.`,
+		`<break time="300ms"/>SAIL DRONE 1053.`,
+	} {
+		if !strings.Contains(u.SSML, want) {
+			t.Errorf("ERROR: Expected %s in %s", want, u.SSML)
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	when := time.Date(2024, time.March, 1, 8, 0, 0, 0, time.UTC)
+	sighting := Event{
+		Kind:  Sighting,
+		Time:  when,
+		Ship:  database.Ship{MMSI: "366990520", Name: "HOEGH TRACER", Type: "Vehicles carrier", Course: 270, Speed: 11, Destination: "OAKLAND", NavigationalStatus: 3, Length: 200},
+		Zone:  "apartment view",
+		Dwell: 12 * time.Minute,
+		Tide:  Tide{Level: 4.3, Known: true},
+	}
+	located := sighting
+	located.Where = &landmark.Description{Landmark: "Alcatraz", Side: "north", Underway: true, Heading: "east", Bearing: 320, Distance: 2.1}
+	north := 0.0
+	warned := sighting
+	warned.Warnings = []clearance.Warning{
+		clearance.Transit{Bridge: clearance.Bridge{Name: "Golden Gate Bridge"}, Level: clearance.Tight, Margin: 12.4},
+		clearance.KeelTransit{Channel: clearance.Channel{Name: "Bar Channel"}, Level: clearance.TooShallow},
+	}
+	suspicious := Event{Kind: Suspicious, Time: when, Ship: sighting.Ship, Anomaly: database.Anomaly{Kind: anomaly.Teleport, Detail: "moved 40 nm in a minute"}}
+
+	testCases := []struct {
+		o        Observer
+		channel  string
+		e        Event
+		expected []string
+		absent   []string
+	}{
+		{
+			Observer{Name: "brief", Detail: Brief, Channels: []string{SpeechChannel}}, SpeechChannel, sighting,
+			[]string{"Ship ahoy! HOEGH TRACER. Vehicles carrier."},
+			[]string{"Course"},
+		},
+		{
+			Observer{Name: "normal", Channels: []string{SpeechChannel}}, SpeechChannel, sighting,
+			[]string{"Course two seven zero degrees. Speed eleven knots. Restricted maneuverability. This is the first sighting."},
+			[]string{"OAKLAND", "tide"},
+		},
+		{
+			Observer{Name: "full", Detail: Full, Channels: []string{SpeechChannel}}, SpeechChannel, sighting,
+			[]string{"Heading to OAKLAND. Length two hundred meters.", "In view for about twelve minutes.", "The tide is four point three feet."},
+			nil,
+		},
+		{
+			Observer{Name: "imperial", Detail: Full, Units: Imperial, Channels: []string{SpeechChannel}}, SpeechChannel, sighting,
+			[]string{"Length six hundred fifty six feet."},
+			nil,
+		},
+		{
+			Observer{Name: "imperial", Units: Imperial, Channels: []string{NotificationChannel}}, NotificationChannel, sighting,
+			[]string{"Size: 656 ft\n"},
+			nil,
+		},
+		{
+			Observer{Name: "español", Language: "es", Channels: []string{SpeechChannel}}, SpeechChannel, sighting,
+			[]string{"¡Barco a la vista! HOEGH TRACER. Buque portavehículos. Rumbo 2 7 0 grados. Velocidad 11 nudos. Maniobrabilidad restringida. Es el primer avistamiento."},
+			nil,
+		},
+		{
+			Observer{Name: "español", Language: "es", Detail: Full, Channels: []string{ConsoleChannel}}, ConsoleChannel, sighting,
+			[]string{"¡Barco a la vista!  Fri Mar 1 08:00:00", "Dónde: apartment view, durante unos 12m0s", "Marea: 4.3 ft"},
+			nil,
+		},
+		{
+			Observer{Name: "español", Language: "es", Channels: []string{NotificationChannel}}, NotificationChannel, Event{Kind: DatabaseDown, Time: when, Err: errors.New("connection refused")},
+			[]string{"Barco a la vista: base de datos caída\n", "No se guardan barcos desde Fri Mar 1 08:00:00.", "connection refused"},
+			nil,
+		},
+		{
+			Observer{Name: "located", Channels: []string{SpeechChannel}}, SpeechChannel, located,
+			[]string{"Vehicles carrier. Passing north of Alcatraz, heading east, bearing three two zero from you, two point one nautical miles. Course"},
+			nil,
+		},
+		{
+			Observer{Name: "located", Channels: []string{ConsoleChannel}}, ConsoleChannel, located,
+			[]string{"passing north of Alcatraz, heading east, bearing 320 from you, 2.1 miles\n"},
+			nil,
+		},
+		{
+			Observer{Name: "localizado", Language: "es", Channels: []string{SpeechChannel}}, SpeechChannel, located,
+			[]string{"Pasando al norte de Alcatraz, con rumbo este, demora 3 2 0 desde usted, a 2.1 millas."},
+			nil,
+		},
+		{
+			Observer{Name: "window", Facing: &north, Channels: []string{SpeechChannel}}, SpeechChannel, located,
+			[]string{"bearing three two zero from you, two point one nautical miles, at eleven o'clock. Course"},
+			nil,
+		},
+		{
+			Observer{Name: "ventana", Language: "es", Facing: &north, Channels: []string{ConsoleChannel}}, ConsoleChannel, located,
+			[]string{"demora 320 desde usted, a 2.1 millas, a las 11\n"},
+			nil,
+		},
+		{
+			Observer{Name: "warned", Channels: []string{SpeechChannel}}, SpeechChannel, warned,
+			[]string{"Tight clearance under the Golden Gate Bridge. twelve feet to spare. Warning. She may not have enough water in the Bar Channel."},
+			nil,
+		},
+		{
+			Observer{Name: "avisado", Language: "es", Channels: []string{SpeechChannel}}, SpeechChannel, warned,
+			[]string{"Paso justo bajo el Golden Gate Bridge. 12 pies de margen. Atención. Puede no tener agua suficiente en el Bar Channel."},
+			[]string{"Warning", "feet"},
+		},
+		{
+			Observer{Name: "sospecha", Language: "es", Channels: []string{SpeechChannel, ConsoleChannel}}, SpeechChannel, suspicious,
+			[]string{"Anomalía. HOEGH TRACER. salto de posición."},
+			[]string{"teleport"},
+		},
+		{
+			Observer{Name: "no channels"}, ConsoleChannel, sighting,
+			[]string{"Ship Ahoy!  Fri Mar 1 08:00:00"},
+			nil,
+		},
+		{
+			Observer{Name: "override", Channels: []string{SpeechChannel}, Templates: map[string]string{"speech.sighting": `{{name .Ship.Name}} for {{.Observer}}. {{template "sighting.brief" .}}`}}, SpeechChannel, sighting,
+			[]string{"HOEGH TRACER for override. Ship ahoy! HOEGH TRACER. Vehicles carrier."},
+			nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		answer := renderOne(t, testCase.o, testCase.channel, testCase.e)
+		if testCase.channel == SpeechChannel {
+			answer = utterance(answer).Text
+		}
+		for _, want := range testCase.expected {
+			if !strings.Contains(answer, want) {
+				t.Errorf("ERROR: For %s on %s expected %q in %q", testCase.o.Name, testCase.channel, want, answer)
+			}
+		}
+		for _, unwanted := range testCase.absent {
+			if strings.Contains(answer, unwanted) {
+				t.Errorf("ERROR: For %s on %s did not expect %q in %q", testCase.o.Name, testCase.channel, unwanted, answer)
+			}
+		}
+	}
+}
+
+func TestNewMessagesErrors(t *testing.T) {
+	testCases := []Observer{
+		{Name: "unknown language", Language: "xx", Channels: []string{SpeechChannel}},
+		{Name: "unknown detail", Detail: "verbose", Channels: []string{SpeechChannel}},
+		{Name: "unknown channel", Channels: []string{"pager"}},
+		{Name: "unknown units", Units: "cubits", Channels: []string{SpeechChannel}},
+		{Name: "unsubscribed override", Channels: []string{SpeechChannel}, Templates: map[string]string{"console.sighting": "{{.Ship.Name}}"}},
+		{Name: "bad override", Channels: []string{SpeechChannel}, Templates: map[string]string{"speech.sighting": "{{.Ship.Name"}},
+	}
+
+	for _, testCase := range testCases {
+		_, err := NewMessages([]Observer{testCase})
+		if err == nil {
+			t.Errorf("ERROR: Expected %s to fail", testCase.Name)
+		}
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	m, err := NewMessages([]Observer{
+		{Name: "broken", Channels: []string{ConsoleChannel}, Templates: map[string]string{"console.sighting": "{{.Ship.NoSuchField}}"}},
+		{Name: "household", Channels: []string{ConsoleChannel}},
+	})
+	if err != nil {
+		t.Fatalf("ERROR: Unable to parse messages: %v", err)
+	}
+
+	found, err := m.render(ConsoleChannel, Event{Kind: Sighting, Ship: database.Ship{Name: "HAWK"}})
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("ERROR: Expected the broken observer's error, got %v", err)
+	}
+	if len(found) != 1 || found[0].observer.Name != "household" {
+		t.Errorf("ERROR: Expected the observers after a failure to still be rendered, got %v", found)
+	}
+}
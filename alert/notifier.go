@@ -0,0 +1,139 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/erikbryant/shipahoy/clearance"
+	"github.com/erikbryant/shipahoy/database"
+	"github.com/erikbryant/shipahoy/landmark"
+	"github.com/erikbryant/shipahoy/speech"
+)
+
+// Kinds of event.
+const (
+	Sighting     = "sighting"      // a ship worth looking at
+	Suspicious   = "anomaly"       // a ship report that does not add up
+	DatabaseDown = "database down" // ships are not being saved
+)
+
+// Event is something to tell people about. Which fields are set depends on the kind.
+type Event struct {
+	Kind     string
+	Time     time.Time
+	Ship     database.Ship
+	Anomaly  database.Anomaly
+	Warnings []clearance.Warning
+	Err      error
+
+	// For sightings.
+	Zone    string                // where the ship was seen
+	Dwell   time.Duration         // how long it is expected to stay there
+	Score   int                   // how interesting it is, 0 to 100
+	Watched bool                  // the ship is one we watch for
+	Tide    Tide                  // the water level when it was seen
+	Where   *landmark.Description // where to look for it; nil if we cannot tell
+}
+
+// Tide is the water level, if we know it.
+type Tide struct {
+	Level float64 // feet
+	Known bool
+}
+
+// Notifier tells people about events, each in its own way.
+type Notifier interface {
+	Name() string
+	Notify(e Event) error
+}
+
+// Dispatcher hands each event to every notifier it has.
+type Dispatcher struct {
+	mu        sync.Mutex
+	notifiers []Notifier
+}
+
+// NewDispatcher returns a dispatcher for the given notifiers.
+func NewDispatcher(notifiers ...Notifier) *Dispatcher {
+	return &Dispatcher{notifiers: notifiers}
+}
+
+// Add adds a notifier.
+func (d *Dispatcher) Add(n Notifier) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.notifiers = append(d.notifiers, n)
+}
+
+// Notify hands the event to every notifier, in the order they were added.
+// One failing does not stop the rest; their errors are returned together.
+func (d *Dispatcher) Notify(e Event) error {
+	d.mu.Lock()
+	notifiers := append([]Notifier(nil), d.notifiers...)
+	d.mu.Unlock()
+
+	var errs []error
+	for _, n := range notifiers {
+		err := n.Notify(e)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", n.Name(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+var (
+	// Reads events out loud; Google's voices until SetVoice says otherwise.
+	speaker = NewSpeech(speech.NewCloud(true))
+
+	// Plays the default sounds until SetSound says otherwise.
+	sounder = DefaultSound()
+
+	// Everything Alert, Anomaly and StorageFailure say goes through here.
+	dispatcher = NewDispatcher(NewConsole(), sounder, speaker)
+)
+
+// How ship names are said, until SetPronunciations says otherwise.
+var pronunciations = speech.DefaultDictionary()
+
+// What each observer hears, until SetMessages says otherwise.
+var messages = DefaultMessages()
+
+// SetVoice changes how Alert, Anomaly and StorageFailure speak. Call it
+// before the first event.
+func SetVoice(voice speech.Provider) {
+	speaker.say = NewSpeech(voice).say
+}
+
+// SetSound changes which sounds Alert plays. Call it before the first
+// event.
+func SetSound(s *Sound) {
+	sounder.rules, sounder.cache = s.rules, s.cache
+}
+
+// SetPronunciations changes how ship names are said. Call it before the
+// first event.
+func SetPronunciations(d *speech.Dictionary) {
+	pronunciations = d
+}
+
+// SetMessages changes who hears about events, and what they hear. Call it
+// before the first event.
+func SetMessages(m *Messages) {
+	messages = m
+}
+
+// Notify hands an event to the notifiers that Alert, Anomaly and
+// StorageFailure use, for callers that know more than those take.
+func Notify(e Event) error {
+	return dispatcher.Notify(e)
+}
+
+// Register adds a notifier to those that Alert, Anomaly and StorageFailure use.
+func Register(n Notifier) {
+	dispatcher.Add(n)
+}
@@ -0,0 +1,198 @@
+package alert
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/erikbryant/beepspeak"
+)
+
+// The sounds played unless told otherwise. They are written out to a
+// cache directory the first time they are played, so that shipahoy can be
+// run from anywhere.
+//
+//go:embed sounds/*
+var defaultSounds embed.FS
+
+// SoundRule picks the sound for sightings that match it. Fields left empty
+// match every sighting.
+type SoundRule struct {
+	Name      string   `json:"name"`      // for people reading the config
+	Types     []string `json:"types"`     // the AIS type contains any of these, ignoring case
+	MinScore  int      `json:"minScore"`  // the sighting scores at least this
+	Watchlist bool     `json:"watchlist"` // the ship is one we watch for
+	Statuses  []int    `json:"statuses"`  // the navigational status is any of these
+	Sound     string   `json:"sound"`     // a default sound, such as "horn.mp3", or a file of the user's
+	Volume    float64  `json:"volume"`    // 1 is as recorded; 0 means 1
+	Repeat    int      `json:"repeat"`    // how many times to play it; 0 means once
+}
+
+// matches returns whether a sighting matches the rule.
+func (r SoundRule) matches(e Event) bool {
+	if len(r.Types) > 0 && !slices.ContainsFunc(r.Types, func(t string) bool {
+		return strings.Contains(strings.ToLower(e.Ship.Type), strings.ToLower(t))
+	}) {
+		return false
+	}
+	if len(r.Statuses) > 0 && !slices.Contains(r.Statuses, e.Ship.NavigationalStatus) {
+		return false
+	}
+	if r.Watchlist && !e.Watched {
+		return false
+	}
+
+	return e.Score >= r.MinScore
+}
+
+// SoundConfig says which sound to play for which sightings.
+type SoundConfig struct {
+	Rules []SoundRule `json:"rules"` // the first that matches is played
+	Dir   string      `json:"dir"`   // where the user's sounds are, if not given as full paths
+	Cache string      `json:"cache"` // where the default sounds are written out to; empty means a temporary directory
+}
+
+// DefaultSoundConfig returns the sounds shipahoy has always played, and an
+// alarm for AIS-SART, MOB-AIS and EPIRB-AIS beacons that is hard to miss.
+func DefaultSoundConfig() SoundConfig {
+	return SoundConfig{
+		Rules: []SoundRule{
+			{Name: "emergency", Statuses: []int{14}, Sound: "alarm.wav", Volume: 1.5, Repeat: 3},
+			{Name: "vehicle carriers", Types: []string{"vehicle"}, Sound: "meep.wav"},
+			{Name: "pilots", Types: []string{"pilot"}, Sound: "pilot.mp3"},
+			{Name: "everything else", Sound: "horn.mp3"},
+		},
+		Cache: filepath.Join(os.TempDir(), "shipahoy-sounds"),
+	}
+}
+
+// Sound plays an alert tone for sightings.
+type Sound struct {
+	rules []SoundRule // with each sound resolved to a file, or a default sound's name
+	cache string
+	play  func(file string, volume float64) error
+}
+
+// NewSound returns a notifier that plays the sound of the first rule a
+// sighting matches. It fails if a rule's sound cannot be found.
+func NewSound(cfg SoundConfig) (*Sound, error) {
+	s := &Sound{cache: cfg.Cache, play: playAt}
+	if s.cache == "" {
+		s.cache = filepath.Join(os.TempDir(), "shipahoy-sounds")
+	}
+
+	for _, r := range cfg.Rules {
+		if r.Volume <= 0 {
+			r.Volume = 1
+		}
+		if r.Repeat <= 0 {
+			r.Repeat = 1
+		}
+
+		if r.Sound == "" {
+			return nil, fmt.Errorf("sound rule %q: no sound", r.Name)
+		}
+		_, err := fs.Stat(defaultSounds, "sounds/"+r.Sound)
+		if err != nil {
+			if !filepath.IsAbs(r.Sound) && cfg.Dir != "" {
+				r.Sound = filepath.Join(cfg.Dir, r.Sound)
+			}
+			_, err = os.Stat(r.Sound)
+			if err != nil {
+				return nil, fmt.Errorf("sound rule %q: %w", r.Name, err)
+			}
+		}
+
+		s.rules = append(s.rules, r)
+	}
+
+	return s, nil
+}
+
+// DefaultSound returns the sounds of DefaultSoundConfig.
+func DefaultSound() *Sound {
+	s, err := NewSound(DefaultSoundConfig())
+	if err != nil {
+		panic(fmt.Sprintf("default sounds: %v", err))
+	}
+
+	return s
+}
+
+// Name returns "sound".
+func (s *Sound) Name() string {
+	return "sound"
+}
+
+// file returns the file to play for a rule's sound, writing a default
+// sound out to the cache if it is not there already.
+func (s *Sound) file(sound string) (string, error) {
+	contents, err := defaultSounds.ReadFile("sounds/" + sound)
+	if err != nil {
+		// One of the user's.
+		return sound, nil
+	}
+
+	path := filepath.Join(s.cache, sound)
+	cached, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(cached, contents) {
+		return path, nil
+	}
+
+	err = os.MkdirAll(s.cache, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	return path, os.WriteFile(path, contents, 0644)
+}
+
+// Notify plays the sound for a sighting. Other events are quiet.
+func (s *Sound) Notify(e Event) error {
+	if e.Kind != Sighting {
+		return nil
+	}
+
+	for _, r := range s.rules {
+		if !r.matches(e) {
+			continue
+		}
+
+		file, err := s.file(r.Sound)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < r.Repeat; i++ {
+			err = s.play(file, r.Volume)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return nil
+}
+
+// playAt plays a file at a volume. beepspeak plays at the system volume,
+// so other volumes need sox's play; without it they are played as
+// recorded.
+func playAt(file string, volume float64) error {
+	if volume == 1 {
+		return beepspeak.Play(file)
+	}
+
+	path, err := exec.LookPath("play")
+	if err != nil {
+		return beepspeak.Play(file)
+	}
+
+	return exec.Command(path, "-q", "-v", strconv.FormatFloat(volume, 'f', -1, 64), file).Run()
+}
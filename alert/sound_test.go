@@ -0,0 +1,91 @@
+package alert
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/erikbryant/shipahoy/database"
+)
+
+// play is a sound being played.
+type play struct {
+	file   string
+	volume float64
+}
+
+func TestSoundRules(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "bell.wav"), []byte("ding"), 0644)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to write a sound: %v", err)
+	}
+
+	cfg := DefaultSoundConfig()
+	cfg.Dir = dir
+	cfg.Cache = t.TempDir()
+	cfg.Rules = append([]SoundRule{
+		{Name: "watched", Watchlist: true, Sound: "bell.wav", Volume: 0.5},
+		{Name: "big", MinScore: 80, Types: []string{"cargo", "tanker"}, Sound: filepath.Join(dir, "bell.wav"), Repeat: 2},
+	}, cfg.Rules...)
+	s, err := NewSound(cfg)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to load sounds: %v", err)
+	}
+	var played []play
+	s.play = func(file string, volume float64) error { played = append(played, play{file, volume}); return nil }
+
+	bell := filepath.Join(dir, "bell.wav")
+	testCases := []struct {
+		e        Event
+		expected []play
+	}{
+		{Event{Kind: Sighting, Ship: database.Ship{Type: "Cargo"}, Watched: true}, []play{{bell, 0.5}}},
+		{Event{Kind: Sighting, Ship: database.Ship{Type: "Oil/Chemical Tanker"}, Score: 85}, []play{{bell, 1}, {bell, 1}}},
+		{Event{Kind: Sighting, Ship: database.Ship{Type: "Cargo"}, Score: 40}, []play{{filepath.Join(cfg.Cache, "horn.mp3"), 1}}},
+		{Event{Kind: Sighting, Ship: database.Ship{Type: "Pleasure craft", NavigationalStatus: 14}}, []play{
+			{filepath.Join(cfg.Cache, "alarm.wav"), 1.5}, {filepath.Join(cfg.Cache, "alarm.wav"), 1.5}, {filepath.Join(cfg.Cache, "alarm.wav"), 1.5},
+		}},
+		{Event{Kind: Sighting, Ship: database.Ship{Type: "Vehicles Carrier"}}, []play{{filepath.Join(cfg.Cache, "meep.wav"), 1}}},
+		{Event{Kind: Suspicious, Ship: database.Ship{Type: "Cargo"}}, nil},
+	}
+
+	for _, testCase := range testCases {
+		played = nil
+		err := s.Notify(testCase.e)
+		if err != nil {
+			t.Errorf("ERROR: Unable to play for %+v: %v", testCase.e.Ship, err)
+		}
+		if len(played) != len(testCase.expected) {
+			t.Errorf("ERROR: For %s %q expected %v, got %v", testCase.e.Kind, testCase.e.Ship.Type, testCase.expected, played)
+			continue
+		}
+		for i := range played {
+			if played[i] != testCase.expected[i] {
+				t.Errorf("ERROR: For %s %q expected %v, got %v", testCase.e.Kind, testCase.e.Ship.Type, testCase.expected, played)
+			}
+		}
+	}
+
+	// The default sounds are written out as they were embedded.
+	cached, err := os.ReadFile(filepath.Join(cfg.Cache, "alarm.wav"))
+	embedded, _ := defaultSounds.ReadFile("sounds/alarm.wav")
+	if err != nil || !bytes.Equal(cached, embedded) {
+		t.Errorf("ERROR: Expected the alarm to be written out, got %d bytes %v", len(cached), err)
+	}
+}
+
+func TestNewSoundErrors(t *testing.T) {
+	testCases := []SoundRule{
+		{Name: "missing", Sound: "no such sound.wav"},
+		{Name: "silent"},
+	}
+
+	for _, testCase := range testCases {
+		_, err := NewSound(SoundConfig{Rules: []SoundRule{testCase}, Dir: t.TempDir()})
+		if err == nil {
+			t.Errorf("ERROR: Expected %s to fail", testCase.Name)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package alert
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WebhookConfig says where to POST events and how hard to try.
+type WebhookConfig struct {
+	URLs           []string      `json:"urls"`
+	Secret         string        `json:"secret"`         // key for the HMAC-SHA256 signature; empty means unsigned
+	Attempts       int           `json:"attempts"`       // tries per URL before giving up
+	RetryDelay     time.Duration `json:"retryDelay"`     // wait before the second try, doubling after that
+	Timeout        time.Duration `json:"timeout"`        // for each request
+	DeadLetter     string        `json:"deadLetter"`     // directory for events that could not be delivered
+	RedeliverEvery time.Duration `json:"redeliverEvery"` // how often to try the dead letters again
+}
+
+// DefaultWebhookConfig returns settings with no URLs, so nothing is sent.
+func DefaultWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		Attempts:       3,
+		RetryDelay:     2 * time.Second,
+		Timeout:        10 * time.Second,
+		RedeliverEvery: 10 * time.Minute,
+	}
+}
+
+// UnmarshalJSON reads a WebhookConfig, taking durations as strings such as "5s".
+func (c *WebhookConfig) UnmarshalJSON(data []byte) error {
+	type config WebhookConfig
+
+	raw := struct {
+		*config
+		RetryDelay     string `json:"retryDelay"`
+		Timeout        string `json:"timeout"`
+		RedeliverEvery string `json:"redeliverEvery"`
+	}{config: (*config)(c)}
+
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	durations := []struct {
+		name  string
+		value string
+		field *time.Duration
+	}{
+		{"retryDelay", raw.RetryDelay, &c.RetryDelay},
+		{"timeout", raw.Timeout, &c.Timeout},
+		{"redeliverEvery", raw.RedeliverEvery, &c.RedeliverEvery},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		*d.field, err = time.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("webhook %s: %w", d.name, err)
+		}
+	}
+
+	return nil
+}
+
+// Headers sent with each request. The signature is the hex HMAC-SHA256,
+// keyed with the secret, of the timestamp, a ".", and the body.
+const (
+	TimestampHeader = "X-Shipahoy-Timestamp"
+	SignatureHeader = "X-Shipahoy-Signature"
+)
+
+// webhookShip is the ship in a webhook event.
+type webhookShip struct {
+	MMSI               string  `json:"mmsi"`
+	IMO                string  `json:"imo,omitempty"`
+	Name               string  `json:"name"`
+	Type               string  `json:"type"`
+	Flag               string  `json:"flag,omitempty"`
+	Length             int     `json:"length,omitempty"`
+	Beam               int     `json:"beam,omitempty"`
+	Draught            float64 `json:"draught,omitempty"`
+	Lat                float64 `json:"lat"`
+	Lon                float64 `json:"lon"`
+	Course             float64 `json:"course"`
+	Speed              float64 `json:"speed"`
+	NavigationalStatus int     `json:"navStatus"`
+	Destination        string  `json:"destination,omitempty"`
+	Sightings          int64   `json:"sightings"`
+}
+
+// WebhookEvent is the JSON body POSTed for each event.
+type WebhookEvent struct {
+	Kind         string       `json:"kind"`
+	Time         time.Time    `json:"time"`
+	Ship         *webhookShip `json:"ship,omitempty"`
+	Zone         string       `json:"zone,omitempty"`
+	DwellSeconds int64        `json:"dwellSeconds,omitempty"`
+	Score        int          `json:"score,omitempty"`
+	DirectLink   string       `json:"directLink,omitempty"`
+	Warnings     []string     `json:"warnings,omitempty"`
+	Anomaly      string       `json:"anomaly,omitempty"`
+	Detail       string       `json:"detail,omitempty"`
+	Error        string       `json:"error,omitempty"`
+}
+
+// webhookEvent converts an event to what is sent.
+func webhookEvent(e Event) WebhookEvent {
+	w := WebhookEvent{
+		Kind:         e.Kind,
+		Time:         e.Time.UTC(),
+		Zone:         e.Zone,
+		DwellSeconds: int64(e.Dwell.Seconds()),
+		Score:        e.Score,
+		DirectLink:   e.Ship.DirectLink,
+		Anomaly:      e.Anomaly.Kind,
+		Detail:       e.Anomaly.Detail,
+	}
+
+	if e.Ship.MMSI != "" {
+		w.Ship = &webhookShip{
+			MMSI:               e.Ship.MMSI,
+			IMO:                e.Ship.IMO,
+			Name:               e.Ship.Name,
+			Type:               e.Ship.Type,
+			Flag:               e.Ship.Flag,
+			Length:             e.Ship.Length,
+			Beam:               e.Ship.Beam,
+			Draught:            e.Ship.Draught,
+			Lat:                e.Ship.Lat,
+			Lon:                e.Ship.Lon,
+			Course:             e.Ship.Course,
+			Speed:              e.Ship.Speed,
+			NavigationalStatus: e.Ship.NavigationalStatus,
+			Destination:        e.Ship.Destination,
+			Sightings:          e.Ship.Sightings,
+		}
+	}
+	for _, warning := range e.Warnings {
+		w.Warnings = append(w.Warnings, warning.String())
+	}
+	if e.Err != nil {
+		w.Error = e.Err.Error()
+	}
+
+	return w
+}
+
+// Sign returns the signature of a body sent at a timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// deadLetter is an event that could not be delivered, as kept on disk.
+type deadLetter struct {
+	URL   string          `json:"url"`
+	Body  json.RawMessage `json:"body"`
+	Error string          `json:"error"`
+}
+
+// errPermanent marks a failure that trying again will not fix.
+var errPermanent = errors.New("rejected")
+
+// The most failed deliveries waiting to be tried again. Beyond that they
+// go straight to the dead letter directory.
+const webhookQueue = 100
+
+// retry is a delivery to try again, in the background.
+type retry struct {
+	url  string
+	body []byte
+}
+
+// Webhook POSTs events as JSON.
+type Webhook struct {
+	cfg     WebhookConfig
+	client  *http.Client
+	retries chan retry
+	pending sync.WaitGroup // retries not yet done with
+}
+
+// NewWebhook returns a notifier that POSTs to the configured URLs. It
+// starts the worker that tries failed deliveries again.
+func NewWebhook(cfg WebhookConfig) *Webhook {
+	if cfg.Attempts < 1 {
+		cfg.Attempts = 1
+	}
+
+	w := &Webhook{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		retries: make(chan retry, webhookQueue),
+	}
+	go w.work()
+
+	return w
+}
+
+// Name returns "webhook".
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+// post sends a body to a URL once.
+func (w *Webhook) post(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.cfg.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, "sha256="+Sign(w.cfg.Secret, timestamp, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	default:
+		return fmt.Errorf("%w: %s: %s", errPermanent, url, resp.Status)
+	}
+}
+
+// deliver sends a body to a URL, starting at an attempt, trying again
+// after failures that might pass.
+func (w *Webhook) deliver(url string, body []byte, attempt int) error {
+	var err error
+
+	delay := w.cfg.RetryDelay << (attempt - 1)
+	for ; attempt <= w.cfg.Attempts; attempt++ {
+		err = w.post(url, body)
+		if err == nil || errors.Is(err, errPermanent) {
+			break
+		}
+		if attempt < w.cfg.Attempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+
+	return err
+}
+
+// work tries the queued deliveries again, burying those that still fail.
+func (w *Webhook) work() {
+	for r := range w.retries {
+		time.Sleep(w.cfg.RetryDelay)
+		err := w.deliver(r.url, r.body, 2)
+		if err != nil {
+			fmt.Println("ERROR: Unable to deliver webhook:", err)
+			err = w.bury(r.url, r.body, err)
+			if err != nil {
+				fmt.Println("ERROR: Unable to keep webhook as a dead letter:", err)
+			}
+		}
+		w.pending.Done()
+	}
+}
+
+// bury keeps an undeliverable event on disk for Redeliver.
+func (w *Webhook) bury(url string, body []byte, failure error) error {
+	if w.cfg.DeadLetter == "" {
+		return nil
+	}
+
+	contents, err := json.Marshal(deadLetter{URL: url, Body: body, Error: failure.Error()})
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(w.cfg.DeadLetter, 0755)
+	if err != nil {
+		return err
+	}
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + ".json"
+
+	return os.WriteFile(filepath.Join(w.cfg.DeadLetter, name), contents, 0644)
+}
+
+// Notify POSTs the event to every URL once. Failures that might pass are
+// tried again in the background, so that other notifiers are not kept
+// waiting. Events that cannot be delivered go to the dead letter
+// directory.
+func (w *Webhook) Notify(e Event) error {
+	body, err := json.Marshal(webhookEvent(e))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, url := range w.cfg.URLs {
+		err := w.post(url, body)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, errPermanent) && w.cfg.Attempts > 1 {
+			w.pending.Add(1)
+			select {
+			case w.retries <- retry{url: url, body: body}:
+				continue
+			default:
+				w.pending.Done()
+			}
+		}
+		errs = append(errs, err)
+		err = w.bury(url, body, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("dead letter: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Redeliver tries again to send the events in the dead letter directory,
+// oldest first. Those delivered are removed; the rest stay for next time.
+func (w *Webhook) Redeliver() error {
+	if w.cfg.DeadLetter == "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(w.cfg.DeadLetter)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var errs []error
+	for _, entry := range entries {
+		path := filepath.Join(w.cfg.DeadLetter, entry.Name())
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var letter deadLetter
+		err = json.Unmarshal(contents, &letter)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+
+		err = w.deliver(letter.URL, letter.Body, 1)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		err = os.Remove(path)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
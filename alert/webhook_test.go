@@ -0,0 +1,185 @@
+package alert
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erikbryant/shipahoy/database"
+)
+
+// receiver is a webhook endpoint that answers with the statuses it is
+// given, in turn, and keeps what it was sent.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.bodies = append(r.bodies, body)
+	r.headers = append(r.headers, req.Header.Clone())
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status = r.statuses[0]
+		r.statuses = r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func testWebhook(url, deadLetter string) *Webhook {
+	return NewWebhook(WebhookConfig{
+		URLs:       []string{url},
+		Secret:     "sekrit",
+		Attempts:   3,
+		RetryDelay: time.Millisecond,
+		Timeout:    time.Second,
+		DeadLetter: deadLetter,
+	})
+}
+
+func TestWebhook(t *testing.T) {
+	r := &receiver{}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	w := testWebhook(server.URL, t.TempDir())
+	e := Event{
+		Kind:  Sighting,
+		Time:  time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC),
+		Ship:  database.Ship{MMSI: "366999712", Name: "Hawk", DirectLink: "https://example.com/366999712", Length: 120},
+		Zone:  "apartment view",
+		Dwell: 12 * time.Minute,
+		Score: 45,
+	}
+
+	err := w.Notify(e)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to notify: %v", err)
+	}
+	if len(r.bodies) != 1 {
+		t.Fatalf("ERROR: Expected 1 request, got %d", len(r.bodies))
+	}
+
+	timestamp := r.headers[0].Get(TimestampHeader)
+	signature := r.headers[0].Get(SignatureHeader)
+	if signature != "sha256="+Sign("sekrit", timestamp, r.bodies[0]) {
+		t.Errorf("ERROR: Expected a valid signature, got %q", signature)
+	}
+	if signature == "sha256="+Sign("wrong", timestamp, r.bodies[0]) {
+		t.Errorf("ERROR: Expected the signature to depend on the secret")
+	}
+
+	var got WebhookEvent
+	err = json.Unmarshal(r.bodies[0], &got)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to decode body %s: %v", r.bodies[0], err)
+	}
+	if got.Kind != Sighting || got.Ship == nil || got.Ship.MMSI != "366999712" || got.Zone != "apartment view" ||
+		got.DwellSeconds != 720 || got.Score != 45 || got.DirectLink != "https://example.com/366999712" {
+		t.Errorf("ERROR: Unexpected body %s", r.bodies[0])
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	testCases := []struct {
+		statuses []int
+		requests int
+		rejected bool // Notify fails at once
+		fails    bool // it ends up a dead letter
+	}{
+		{[]int{http.StatusOK}, 1, false, false},
+		{[]int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK}, 3, false, false},
+		{[]int{http.StatusTooManyRequests, http.StatusOK}, 2, false, false},
+		{[]int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}, 3, false, true},
+		{[]int{http.StatusBadRequest}, 1, true, true},
+	}
+
+	for _, testCase := range testCases {
+		r := &receiver{statuses: append([]int(nil), testCase.statuses...)}
+		server := httptest.NewServer(r)
+		dir := t.TempDir()
+
+		w := testWebhook(server.URL, dir)
+		err := w.Notify(Event{Kind: Sighting, Ship: database.Ship{MMSI: "1"}})
+		w.pending.Wait()
+		server.Close()
+		if (err != nil) != testCase.rejected {
+			t.Errorf("ERROR: For %v expected failure %v, got %v", testCase.statuses, testCase.rejected, err)
+		}
+		if len(r.bodies) != testCase.requests {
+			t.Errorf("ERROR: For %v expected %d requests, got %d", testCase.statuses, testCase.requests, len(r.bodies))
+		}
+
+		entries, _ := os.ReadDir(dir)
+		if (len(entries) == 1) != testCase.fails {
+			t.Errorf("ERROR: For %v expected dead letter %v, got %d", testCase.statuses, testCase.fails, len(entries))
+		}
+	}
+}
+
+func TestWebhookRedeliver(t *testing.T) {
+	r := &receiver{statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	dir := t.TempDir()
+	w := testWebhook(server.URL, dir)
+
+	err := w.Notify(Event{Kind: Sighting, Ship: database.Ship{MMSI: "2"}})
+	if err != nil {
+		t.Fatalf("ERROR: Expected the receiver being down to be tried again later, got %v", err)
+	}
+	w.pending.Wait()
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("ERROR: Expected 1 dead letter, got %d", len(entries))
+	}
+
+	// The receiver is back up.
+	err = w.Redeliver()
+	if err != nil {
+		t.Errorf("ERROR: Unable to redeliver: %v", err)
+	}
+	entries, _ = os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("ERROR: Expected redelivered letters to be removed, got %d", len(entries))
+	}
+	if len(r.bodies) != 4 || string(r.bodies[3]) != string(r.bodies[0]) {
+		t.Errorf("ERROR: Expected the original body to be redelivered, got %d requests", len(r.bodies))
+	}
+}
+
+func TestWebhookBackground(t *testing.T) {
+	r := &receiver{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	w := testWebhook(server.URL, t.TempDir())
+	w.cfg.RetryDelay = 100 * time.Millisecond
+
+	// Notify does not wait to try again.
+	err := w.Notify(Event{Kind: Sighting, Ship: database.Ship{MMSI: "3"}})
+	r.mu.Lock()
+	requests := len(r.bodies)
+	r.mu.Unlock()
+	if err != nil || requests != 1 {
+		t.Errorf("ERROR: Expected Notify to return after 1 request, got %d and %v", requests, err)
+	}
+
+	w.pending.Wait()
+	if len(r.bodies) != 2 {
+		t.Errorf("ERROR: Expected the retry to be delivered in the background, got %d requests", len(r.bodies))
+	}
+}
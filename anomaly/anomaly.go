@@ -0,0 +1,273 @@
+package anomaly
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/erikbryant/shipahoy/aismmsi"
+	"github.com/erikbryant/shipahoy/database"
+	"github.com/erikbryant/shipahoy/geo"
+	"github.com/erikbryant/shipahoy/shipid"
+)
+
+// Kinds of anomaly.
+const (
+	ImpossibleSpeed = "impossible speed"
+	Teleport        = "teleport"
+	DuplicateMmsi   = "duplicate MMSI"
+	FlagMismatch    = "MMSI flag mismatch"
+	TestMmsi        = "default or test MMSI"
+	NameMismatch    = "name mismatch"
+	WentDark        = "went dark"
+)
+
+var (
+	// No surface vessel we are likely to see moves faster than this (knots).
+	// The M/Y SAINT NICHOLAS (319762000) sometimes reports 102.3 knots
+	// while at anchor.
+	maxSpeed = 60.0
+
+	// Two reports this close together in time (seconds) but far apart
+	// in space are two transmitters sharing one MMSI, not one ship moving.
+	duplicateWindow = int64(60)
+
+	// Movements shorter than this (nautical miles) are GPS noise.
+	minJump = 1.0
+
+	// A ship in the zone that has not reported for this long (seconds) has gone dark.
+	darkAfter = int64(30 * 60)
+
+	// Do not report the same kind of anomaly for the same ship more often than this (seconds).
+	quietPeriod = int64(60 * 60)
+
+	// Forget the position of a ship not heard from for this long (seconds).
+	// If it comes back, its stored history is asked for again.
+	forgetAfter = int64(6 * 60 * 60)
+
+	// Anomalies that mean the report should not be trusted at all.
+	disqualifying = map[string]bool{
+		ImpossibleSpeed: true,
+		NameMismatch:    true,
+		TestMmsi:        true,
+	}
+
+	// MMSIs that are factory defaults or typed in by installers as filler.
+	testMmsi = map[string]bool{
+		"123456789": true,
+		"987654321": true,
+		"012345678": true,
+		"100000000": true,
+	}
+)
+
+// fix is the last known position of a ship.
+type fix struct {
+	lat       float64
+	lon       float64
+	timestamp int64
+	speed     float64
+	status    int
+}
+
+// Detector looks for anomalies in the stream of ship reports. It remembers
+// the last position of every ship it has been shown, until Forget.
+type Detector struct {
+	mu           sync.Mutex
+	inZone       func(lat, lon float64) bool
+	lastPosition func(mmsi string) (database.Position, bool)
+	last         map[string]fix
+	reported     map[string]int64
+}
+
+// NewDetector returns a detector that watches for ships going dark inside
+// the area defined by inZone. The first time it sees a ship it asks
+// lastPosition (if not nil) for the ship's stored position history.
+func NewDetector(inZone func(lat, lon float64) bool, lastPosition func(mmsi string) (database.Position, bool)) *Detector {
+	return &Detector{
+		inZone:       inZone,
+		lastPosition: lastPosition,
+		last:         map[string]fix{},
+		reported:     map[string]int64{},
+	}
+}
+
+// previous returns the last known position of a ship, falling back to the stored history.
+func (d *Detector) previous(mmsi string) (fix, bool) {
+	f, ok := d.last[mmsi]
+	if ok || d.lastPosition == nil {
+		return f, ok
+	}
+
+	p, ok := d.lastPosition(mmsi)
+	if !ok {
+		return f, false
+	}
+
+	f = fix{
+		lat:       p.Lat,
+		lon:       p.Lon,
+		timestamp: p.Timestamp,
+		speed:     p.Speed,
+		status:    p.NavigationalStatus,
+	}
+	d.last[mmsi] = f
+
+	return f, true
+}
+
+// isTestMmsi returns whether the MMSI is a default, filler or unallocated ship station identity.
+func isTestMmsi(mmsi string) bool {
+	if testMmsi[mmsi] {
+		return true
+	}
+
+	// 000000000, 111111111, ...
+	if strings.Count(mmsi, mmsi[0:1]) == len(mmsi) {
+		return true
+	}
+
+	// Ship stations (first digit 2-7) must carry an allocated MID.
+	if mmsi[0] >= '2' && mmsi[0] <= '7' && aismmsi.Country(mmsi) == "" {
+		return true
+	}
+
+	return false
+}
+
+// moving returns whether a ship with this speed and navigational status is under way.
+func moving(speed float64, status int) bool {
+	switch status {
+	case 1, 5, 6: // at anchor, moored, aground
+		return false
+	}
+	return speed >= 1.0
+}
+
+// Check examines a ship report for anomalies. mapName is the name shown
+// for the MMSI on the map, which should match the name in the details.
+func (d *Detector) Check(details database.Ship, mapName string, now int64) []database.Anomaly {
+	var found []database.Anomaly
+
+	add := func(kind, detail string) {
+		found = append(found, database.Anomaly{
+			MMSI:      details.MMSI,
+			Timestamp: now,
+			Kind:      kind,
+			Detail:    detail,
+			Lat:       details.Lat,
+			Lon:       details.Lon,
+		})
+	}
+
+	if aismmsi.ValidateMmsi(details.MMSI) != nil || isTestMmsi(details.MMSI) {
+		add(TestMmsi, details.MMSI)
+		return found
+	}
+
+	if mapName != "" && mapName != details.Name {
+		// VesselFinder returned details for some other ship.
+		add(NameMismatch, fmt.Sprintf("map %q, details %q", mapName, details.Name))
+		return found
+	}
+
+	// SAR aircraft are allowed to be fast.
+	if details.Speed > maxSpeed && !strings.HasPrefix(details.MMSI, "111") {
+		add(ImpossibleSpeed, fmt.Sprintf("%.1f knots", details.Speed))
+	}
+
+	country := aismmsi.Country(details.MMSI)
+	if details.MMSI[0] >= '2' && details.MMSI[0] <= '7' && details.Flag != "" && !shipid.SameAdministration(country, details.Flag) {
+		add(FlagMismatch, fmt.Sprintf("MID %s is %s, flag is %s", aismmsi.Mid(details.MMSI), country, details.Flag))
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current := fix{
+		lat:       details.Lat,
+		lon:       details.Lon,
+		timestamp: int64(details.LastPosUpdate),
+		speed:     details.Speed,
+		status:    details.NavigationalStatus,
+	}
+
+	previous, ok := d.previous(details.MMSI)
+	if ok && current.timestamp != previous.timestamp {
+		distance := geo.Distance(previous.lat, previous.lon, current.lat, current.lon)
+		elapsed := current.timestamp - previous.timestamp
+		if elapsed < 0 {
+			elapsed = -elapsed
+		}
+
+		if distance > minJump {
+			if elapsed <= duplicateWindow {
+				add(DuplicateMmsi, fmt.Sprintf("%.1f nm apart %d seconds apart", distance, elapsed))
+			} else if speed := distance / (float64(elapsed) / 3600); speed > maxSpeed {
+				add(Teleport, fmt.Sprintf("%.1f nm in %d seconds (%.0f knots)", distance, elapsed, speed))
+			}
+		}
+	}
+
+	// Only track positions we believe. Keep the newer of the two fixes.
+	if !Disqualified(found) && (!ok || current.timestamp >= previous.timestamp) {
+		d.last[details.MMSI] = current
+	}
+
+	if d.inZone != nil && d.inZone(details.Lat, details.Lon) && now-current.timestamp > darkAfter && moving(current.speed, current.status) {
+		add(WentDark, fmt.Sprintf("no report for %d minutes", (now-current.timestamp)/60))
+	}
+
+	return found
+}
+
+// Disqualified returns whether any of the anomalies mean the report should be ignored.
+func Disqualified(anomalies []database.Anomaly) bool {
+	for _, anomaly := range anomalies {
+		if disqualifying[anomaly.Kind] {
+			return true
+		}
+	}
+	return false
+}
+
+// Unreported filters out anomalies that were already reported for the same
+// ship within the quiet period, and marks the remainder as reported.
+func (d *Detector) Unreported(anomalies []database.Anomaly) []database.Anomaly {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var fresh []database.Anomaly
+
+	for _, anomaly := range anomalies {
+		key := anomaly.MMSI + " " + anomaly.Kind
+		last, ok := d.reported[key]
+		if ok && anomaly.Timestamp-last < quietPeriod {
+			continue
+		}
+		d.reported[key] = anomaly.Timestamp
+		fresh = append(fresh, anomaly)
+	}
+
+	return fresh
+}
+
+// Forget drops the positions of ships not heard from within forgetAfter,
+// and the reports whose quiet period is over, so that memory does not grow
+// with every ship ever seen. Call it once per scan.
+func (d *Detector) Forget(now int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for mmsi, f := range d.last {
+		if now-f.timestamp >= forgetAfter {
+			delete(d.last, mmsi)
+		}
+	}
+
+	for key, last := range d.reported {
+		if now-last >= quietPeriod {
+			delete(d.reported, key)
+		}
+	}
+}
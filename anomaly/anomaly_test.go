@@ -0,0 +1,183 @@
+package anomaly
+
+import (
+	"testing"
+
+	"github.com/erikbryant/shipahoy/database"
+)
+
+// kinds returns the kind of each anomaly.
+func kinds(anomalies []database.Anomaly) []string {
+	var k []string
+	for _, anomaly := range anomalies {
+		k = append(k, anomaly.Kind)
+	}
+	return k
+}
+
+// sameKinds returns whether two lists of kinds are identical.
+func sameKinds(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIsTestMmsi(t *testing.T) {
+	testCases := []struct {
+		mmsi     string
+		expected bool
+	}{
+		{"366990520", false},
+		{"123456789", true},
+		{"111111111", true},
+		{"000000000", true},
+		{"200111111", true},  // MID 200 is not allocated
+		{"970777111", false}, // SAR transponders do not carry a MID
+	}
+
+	for _, testCase := range testCases {
+		answer := isTestMmsi(testCase.mmsi)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %v expected %v, got %v", testCase.mmsi, testCase.expected, answer)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	inZone := func(lat, lon float64) bool { return lat > 37.8 && lat < 37.9 }
+	now := int64(1700000000)
+
+	testCases := []struct {
+		details  database.Ship
+		mapName  string
+		expected []string
+	}{
+		// A ship seen for the first time.
+		{database.Ship{MMSI: "366990520", Name: "DEL NORTE", Flag: "US", Lat: 37.5, Lon: -122.4, LastPosUpdate: int(now - 600), Speed: 10}, "DEL NORTE", nil},
+		// Ten minutes later, two miles on.
+		{database.Ship{MMSI: "366990520", Name: "DEL NORTE", Flag: "US", Lat: 37.533, Lon: -122.4, LastPosUpdate: int(now), Speed: 10}, "DEL NORTE", nil},
+		// Thirty seconds later, thirty miles away.
+		{database.Ship{MMSI: "366990520", Name: "DEL NORTE", Flag: "US", Lat: 38.033, Lon: -122.4, LastPosUpdate: int(now + 30), Speed: 10}, "DEL NORTE", []string{DuplicateMmsi}},
+		// An hour later, two hundred miles away.
+		{database.Ship{MMSI: "366990520", Name: "DEL NORTE", Flag: "US", Lat: 41.4, Lon: -122.4, LastPosUpdate: int(now + 3630), Speed: 10}, "DEL NORTE", []string{Teleport}},
+		{database.Ship{MMSI: "319762000", Name: "SAINT NICOLAS", Flag: "KY", Lat: 37.5, Lon: -122.4, LastPosUpdate: int(now), Speed: 102.3}, "SAINT NICOLAS", []string{ImpossibleSpeed}},
+		{database.Ship{MMSI: "111338111", Name: "COAST GUARD 1", Flag: "US", Lat: 37.5, Lon: -122.4, LastPosUpdate: int(now), Speed: 120}, "COAST GUARD 1", nil},
+		{database.Ship{MMSI: "538007561", Name: "EVER GIVEN", Flag: "PA", Lat: 37.5, Lon: -122.4, LastPosUpdate: int(now), Speed: 10}, "EVER GIVEN", []string{FlagMismatch}},
+		{database.Ship{MMSI: "538007561", Name: "EVER GIVEN", Flag: "MH", Lat: 37.5, Lon: -122.4, LastPosUpdate: int(now), Speed: 10}, "EVER GREEN", []string{NameMismatch}},
+		{database.Ship{MMSI: "123456789", Name: "MY BOAT", Lat: 37.5, Lon: -122.4, LastPosUpdate: int(now), Speed: 10}, "MY BOAT", []string{TestMmsi}},
+		// Moving in the zone, silent for an hour.
+		{database.Ship{MMSI: "367123640", Name: "HAWK", Flag: "US", Lat: 37.85, Lon: -122.4, LastPosUpdate: int(now - 3600), Speed: 8}, "HAWK", []string{WentDark}},
+		// Moored in the zone, silent for an hour.
+		{database.Ship{MMSI: "367389640", Name: "OSKI", Flag: "US", Lat: 37.85, Lon: -122.4, LastPosUpdate: int(now - 3600), Speed: 8, NavigationalStatus: 5}, "OSKI", nil},
+	}
+
+	d := NewDetector(inZone, nil)
+
+	for _, testCase := range testCases {
+		answer := kinds(d.Check(testCase.details, testCase.mapName, now))
+		if !sameKinds(answer, testCase.expected) {
+			t.Errorf("ERROR: For %v expected %v, got %v", testCase.details, testCase.expected, answer)
+		}
+	}
+}
+
+func TestDisqualified(t *testing.T) {
+	testCases := []struct {
+		kinds    []string
+		expected bool
+	}{
+		{nil, false},
+		{[]string{Teleport}, false},
+		{[]string{FlagMismatch, WentDark}, false},
+		{[]string{Teleport, ImpossibleSpeed}, true},
+		{[]string{NameMismatch}, true},
+		{[]string{TestMmsi}, true},
+	}
+
+	for _, testCase := range testCases {
+		var anomalies []database.Anomaly
+		for _, kind := range testCase.kinds {
+			anomalies = append(anomalies, database.Anomaly{Kind: kind})
+		}
+		answer := Disqualified(anomalies)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %v expected %v, got %v", testCase.kinds, testCase.expected, answer)
+		}
+	}
+}
+
+func TestUnreported(t *testing.T) {
+	d := NewDetector(nil, nil)
+
+	first := []database.Anomaly{
+		{MMSI: "366990520", Kind: Teleport, Timestamp: 1000},
+		{MMSI: "366990520", Kind: FlagMismatch, Timestamp: 1000},
+	}
+	if len(d.Unreported(first)) != 2 {
+		t.Errorf("ERROR: Expected both anomalies to be reported the first time")
+	}
+
+	again := []database.Anomaly{
+		{MMSI: "366990520", Kind: Teleport, Timestamp: 1000 + quietPeriod - 1},
+		{MMSI: "367123640", Kind: Teleport, Timestamp: 1000 + quietPeriod - 1},
+	}
+	answer := d.Unreported(again)
+	if len(answer) != 1 || answer[0].MMSI != "367123640" {
+		t.Errorf("ERROR: Expected only the new ship to be reported, got %v", answer)
+	}
+
+	later := []database.Anomaly{
+		{MMSI: "366990520", Kind: Teleport, Timestamp: 1000 + quietPeriod},
+	}
+	if len(d.Unreported(later)) != 1 {
+		t.Errorf("ERROR: Expected anomaly to be reported again after the quiet period")
+	}
+}
+
+func TestForget(t *testing.T) {
+	d := NewDetector(nil, nil)
+
+	d.Check(database.Ship{MMSI: "366990520", Name: "DEL NORTE", Flag: "US", Lat: 37.5, Lon: -122.4, LastPosUpdate: 1000}, "", 1000)
+	d.Check(database.Ship{MMSI: "367123640", Name: "HAWK", Flag: "US", Lat: 37.8, Lon: -122.4, LastPosUpdate: 5000}, "", 5000)
+	d.Unreported([]database.Anomaly{
+		{MMSI: "366990520", Kind: Teleport, Timestamp: 1000},
+		{MMSI: "367123640", Kind: Teleport, Timestamp: 5000},
+	})
+
+	d.Forget(1000 + forgetAfter)
+	if _, ok := d.last["366990520"]; ok || len(d.last) != 1 {
+		t.Errorf("ERROR: Expected only the ship not heard from to be forgotten, got %v", d.last)
+	}
+	if len(d.reported) != 0 {
+		t.Errorf("ERROR: Expected reports past their quiet period to be forgotten, got %v", d.reported)
+	}
+
+	d.Forget(5000 + forgetAfter)
+	if len(d.last) != 0 {
+		t.Errorf("ERROR: Expected every ship to be forgotten, got %v", d.last)
+	}
+}
+
+func TestCheckHistory(t *testing.T) {
+	history := func(mmsi string) (database.Position, bool) {
+		if mmsi != "366990520" {
+			return database.Position{}, false
+		}
+		return database.Position{MMSI: mmsi, Timestamp: 1000, Lat: 37.5, Lon: -122.4}, true
+	}
+
+	d := NewDetector(nil, history)
+
+	// Two hundred miles from the stored position, an hour later.
+	details := database.Ship{MMSI: "366990520", Name: "DEL NORTE", Flag: "US", Lat: 41.4, Lon: -122.4, LastPosUpdate: 4600}
+	answer := kinds(d.Check(details, "DEL NORTE", 4600))
+	if !sameKinds(answer, []string{Teleport}) {
+		t.Errorf("ERROR: For %v expected %v, got %v", details, []string{Teleport}, answer)
+	}
+}
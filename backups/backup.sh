@@ -1,6 +1,6 @@
 #!/bin/zsh -u
 
-for table in ships sightings; do
+for table in schema_version ships ship_history sightings positions anomalies noaa_readings; do
   mysqldump --user=ships --password=ships_password ship_ahoy --no-tablespaces ${table} > ${table}.sql
   gzip --best --force ${table}.sql
 done
@@ -0,0 +1,256 @@
+// Package clearance works out whether a ship fits under the bay's bridges.
+package clearance
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/erikbryant/shipahoy/database"
+	"github.com/erikbryant/shipahoy/geo"
+)
+
+// How close a transit is.
+const (
+	Tight  = "tight"   // less than the configured margin to spare
+	TooLow = "too low" // the estimate says it does not fit
+)
+
+// feetPerMeter converts the ship dimensions we get (meters) to feet.
+const feetPerMeter = 3.28084
+
+// Bridge is a bridge that ships pass under.
+type Bridge struct {
+	Name      string  `json:"name"`
+	Lat       float64 `json:"lat"` // middle of the main span
+	Lon       float64 `json:"lon"`
+	Clearance float64 `json:"clearance"` // vertical clearance of the main span at MHHW, feet
+	MHHW      float64 `json:"mhhw"`      // height of MHHW above MLLW, feet
+	Station   string  `json:"station"`   // NOAA air gap station, if there is one
+}
+
+// FromTide returns the air gap under the bridge for a water level above MLLW.
+func (b Bridge) FromTide(tide float64) float64 {
+	return b.Clearance + b.MHHW - tide
+}
+
+// Config holds the bridges and channels to check and what we know about the ships.
+type Config struct {
+	Bridges    []Bridge           `json:"bridges"`
+	AirDrafts  map[string]float64 `json:"airDrafts"`  // known air drafts by MMSI, feet
+	Margin     float64            `json:"margin"`     // flag transits with less than this to spare, feet
+	Range      float64            `json:"range"`      // check bridges this close ahead of a ship, nautical miles
+	Channels   []Channel          `json:"channels"`   // deep draught channels
+	KeelMargin float64            `json:"keelMargin"` // flag transits with less than this under the keel, feet
+	Lookahead  time.Duration      `json:"lookahead"`  // follow a ship's track this far ahead
+}
+
+// DefaultConfig returns the bridges of San Francisco Bay.
+func DefaultConfig() Config {
+	return Config{
+		Bridges: []Bridge{
+			{Name: "Golden Gate Bridge", Lat: 37.8199, Lon: -122.4783, Clearance: 220, MHHW: 5.8},
+			{Name: "Bay Bridge", Lat: 37.7983, Lon: -122.3778, Clearance: 204, MHHW: 5.9, Station: "9414304"},
+			{Name: "Richmond-San Rafael Bridge", Lat: 37.9359, Lon: -122.4458, Clearance: 185, MHHW: 5.9},
+		},
+		AirDrafts:  map[string]float64{},
+		Margin:     20,
+		Range:      3,
+		Channels:   defaultChannels(),
+		KeelMargin: 5,
+		Lookahead:  time.Hour,
+	}
+}
+
+// UnmarshalJSON reads a Config, taking the lookahead as a string such as "45m".
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type config Config
+
+	raw := struct {
+		*config
+		Lookahead string `json:"lookahead"`
+	}{config: (*config)(c)}
+
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	if raw.Lookahead != "" {
+		c.Lookahead, err = time.ParseDuration(raw.Lookahead)
+		if err != nil {
+			return fmt.Errorf("clearance lookahead: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// AirDraft is how far a ship reaches above the water, in feet.
+type AirDraft struct {
+	Feet   float64
+	Source string // where the figure came from
+}
+
+// Rough air drafts for a ship type, in meters, as a multiple of its length
+// plus a constant. They are fitted to a few well known ships of each kind
+// and err on the tall side.
+var airDraftByType = []struct {
+	contains string
+	perMeter float64
+	plus     float64
+}{
+	{"container", 0.12, 15},
+	{"passenger", 0.19, 3},
+	{"vehicle", 0.17, 10},
+	{"tanker", 0.15, 12},
+	{"cargo", 0.15, 12},
+	{"bulk", 0.15, 12},
+	{"sailing", 1.3, 0}, // the mast
+	{"tug", 0.5, 5},
+	{"towing", 0.5, 5},
+}
+
+// EstimateAirDraft returns the air draft of a ship. A known air draft for
+// its MMSI wins; otherwise it is worked out from the type and length (or,
+// lacking a length, the gross tonnage). Types we cannot estimate, such as
+// crane barges, return false.
+func (c Config) EstimateAirDraft(details database.Ship) (AirDraft, bool) {
+	if feet, ok := c.AirDrafts[details.MMSI]; ok {
+		return AirDraft{Feet: feet, Source: "known"}, true
+	}
+
+	length := float64(details.Length)
+	source := "type and length"
+	if length <= 0 && details.GT > 0 {
+		// Length grows with the cube root of the volume.
+		length = 6.3 * math.Cbrt(float64(details.GT))
+		source = "type and tonnage"
+	}
+	if length <= 0 {
+		return AirDraft{}, false
+	}
+
+	kind := strings.ToLower(details.Type)
+	for _, t := range airDraftByType {
+		if strings.Contains(kind, t.contains) {
+			return AirDraft{Feet: (t.perMeter*length + t.plus) * feetPerMeter, Source: source}, true
+		}
+	}
+
+	return AirDraft{}, false
+}
+
+// Transit is a ship heading for a bridge with little room to spare.
+type Transit struct {
+	Bridge    Bridge
+	AirDraft  AirDraft
+	AirGap    float64 // feet
+	GapSource string  // where the air gap came from
+	Margin    float64 // air gap less air draft, feet
+	Level     string  // Tight or TooLow
+	Distance  float64 // nautical miles to the bridge
+}
+
+// String describes the transit.
+func (t Transit) String() string {
+	return fmt.Sprintf("%s clearance under the %s: %.0f ft air gap (%s), %.0f ft air draft (%s), %.0f ft to spare, %.1f nm ahead",
+		t.Level, t.Bridge.Name, t.AirGap, t.GapSource, t.AirDraft.Feet, t.AirDraft.Source, t.Margin, t.Distance)
+}
+
+// Warning is a transit worth mentioning in an alert: a Transit or a
+// KeelTransit. Alerts say them in the observer's language from their
+// fields; String is for logs.
+type Warning interface {
+	String() string
+}
+
+// Checker finds ships heading for bridges they only just fit under and
+// channels they only just float in.
+type Checker struct {
+	cfg    Config
+	airGap func(b Bridge) (gap float64, source string, ok bool)
+	tide   func(at time.Time) (level float64, ok bool)
+}
+
+// NewChecker returns a checker that gets the current air gap under a
+// bridge from airGap and the water level above MLLW at a time from tide.
+func NewChecker(cfg Config, airGap func(b Bridge) (gap float64, source string, ok bool), tide func(at time.Time) (level float64, ok bool)) *Checker {
+	return &Checker{
+		cfg:    cfg,
+		airGap: airGap,
+		tide:   tide,
+	}
+}
+
+// Warnings returns everything worth mentioning about a ship's transit.
+func (c *Checker) Warnings(details database.Ship, now time.Time) []Warning {
+	var found []Warning
+
+	for _, t := range c.Check(details) {
+		found = append(found, t)
+	}
+	for _, k := range c.CheckKeel(details, now) {
+		found = append(found, k)
+	}
+
+	return found
+}
+
+// ahead returns whether the bridge is within range and roughly where the ship is heading.
+func (c *Checker) ahead(details database.Ship, b Bridge) (float64, bool) {
+	distance := geo.Distance(details.Lat, details.Lon, b.Lat, b.Lon)
+	if distance > c.cfg.Range {
+		return distance, false
+	}
+
+	bearing := geo.Bearing(details.Lat, details.Lon, b.Lat, b.Lon)
+	off := math.Abs(math.Mod(bearing-details.Course+540, 360) - 180)
+
+	// Right underneath counts whichever way the ship is pointing.
+	return distance, off <= 45 || distance < 0.1
+}
+
+// Check returns the bridges ahead of a ship that it has little room to pass under.
+func (c *Checker) Check(details database.Ship) []Transit {
+	var found []Transit
+
+	draft, ok := c.cfg.EstimateAirDraft(details)
+	if !ok || c.airGap == nil {
+		return nil
+	}
+
+	for _, b := range c.cfg.Bridges {
+		distance, ahead := c.ahead(details, b)
+		if !ahead {
+			continue
+		}
+
+		gap, source, ok := c.airGap(b)
+		if !ok {
+			continue
+		}
+
+		t := Transit{
+			Bridge:    b,
+			AirDraft:  draft,
+			AirGap:    gap,
+			GapSource: source,
+			Margin:    gap - draft.Feet,
+			Distance:  distance,
+		}
+		switch {
+		case t.Margin < 0:
+			t.Level = TooLow
+		case t.Margin < c.cfg.Margin:
+			t.Level = Tight
+		default:
+			continue
+		}
+		found = append(found, t)
+	}
+
+	return found
+}
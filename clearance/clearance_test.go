@@ -0,0 +1,182 @@
+package clearance
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/erikbryant/shipahoy/database"
+)
+
+func TestEstimateAirDraft(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AirDrafts["311000917"] = 210
+
+	testCases := []struct {
+		details  database.Ship
+		expected float64
+		source   string
+		ok       bool
+	}{
+		{database.Ship{MMSI: "311000917", Type: "Passenger ship", Length: 347}, 210, "known", true},
+		{database.Ship{MMSI: "636019825", Type: "Container ship", Length: 366}, 193.3, "type and length", true},
+		{database.Ship{MMSI: "311000100", Type: "Passenger ship", Length: 347}, 226.1, "type and length", true},
+		{database.Ship{MMSI: "538007561", Type: "Crude oil tanker", GT: 125000}, 194.4, "type and tonnage", true},
+		{database.Ship{MMSI: "367000001", Type: "Sailing vessel", Length: 20}, 85.3, "type and length", true},
+		{database.Ship{MMSI: "367000002", Type: "Crane barge", Length: 80}, 0, "", false},
+		{database.Ship{MMSI: "367000003", Type: "Container ship"}, 0, "", false},
+	}
+
+	for _, testCase := range testCases {
+		draft, ok := cfg.EstimateAirDraft(testCase.details)
+		if ok != testCase.ok || math.Abs(draft.Feet-testCase.expected) > 0.1 || draft.Source != testCase.source {
+			t.Errorf("ERROR: For %s expected %.1f ft from %q %v, got %.1f %q %v", testCase.details.Type, testCase.expected, testCase.source, testCase.ok, draft.Feet, draft.Source, ok)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AirDrafts["311000917"] = 210
+	cfg.AirDrafts["367000004"] = 180
+
+	// High water: the air gap is the published clearance.
+	airGap := func(b Bridge) (float64, string, bool) {
+		return b.FromTide(b.MHHW), "test", true
+	}
+	checker := NewChecker(cfg, airGap, nil)
+
+	testCases := []struct {
+		details database.Ship
+		bridges []string
+		levels  []string
+	}{
+		// Inbound past Point Bonita for the Golden Gate.
+		{database.Ship{MMSI: "311000917", Lat: 37.81, Lon: -122.52, Course: 75}, []string{"Golden Gate Bridge"}, []string{Tight}},
+		// Outbound, the Golden Gate is behind.
+		{database.Ship{MMSI: "311000917", Lat: 37.81, Lon: -122.52, Course: 255}, nil, nil},
+		// Off Alcatraz heading for the Bay Bridge; too tall for it.
+		{database.Ship{MMSI: "311000917", Lat: 37.81, Lon: -122.42, Course: 110}, []string{"Bay Bridge"}, []string{TooLow}},
+		// Plenty of room.
+		{database.Ship{MMSI: "367000004", Lat: 37.81, Lon: -122.52, Course: 75}, nil, nil},
+		// Too far away.
+		{database.Ship{MMSI: "311000917", Lat: 37.70, Lon: -122.70, Course: 45}, nil, nil},
+		// No air draft to go on.
+		{database.Ship{MMSI: "367000002", Type: "Crane barge", Lat: 37.81, Lon: -122.52, Course: 75}, nil, nil},
+	}
+
+	for _, testCase := range testCases {
+		transits := checker.Check(testCase.details)
+		if len(transits) != len(testCase.bridges) {
+			t.Errorf("ERROR: For %v expected %v, got %v", testCase.details, testCase.bridges, transits)
+			continue
+		}
+		for i, transit := range transits {
+			if transit.Bridge.Name != testCase.bridges[i] || transit.Level != testCase.levels[i] {
+				t.Errorf("ERROR: For %v expected %s %s, got %v", testCase.details, testCase.bridges[i], testCase.levels[i], transit)
+			}
+		}
+	}
+
+	// Without an air gap there is nothing to say.
+	checker = NewChecker(cfg, func(b Bridge) (float64, string, bool) { return 0, "", false }, nil)
+	if transits := checker.Check(testCases[0].details); len(transits) != 0 {
+		t.Errorf("ERROR: Expected no transits without an air gap, got %v", transits)
+	}
+}
+
+func TestChannelContains(t *testing.T) {
+	channels := defaultChannels()
+
+	testCases := []struct {
+		lat      float64
+		lon      float64
+		expected string
+	}{
+		{37.78, -122.60, "San Francisco Bar Channel"},
+		{37.815, -122.478, "Golden Gate"},
+		{37.795, -122.30, "Oakland Estuary"},
+		{37.8267, -122.4230, ""}, // Alcatraz
+		{37.70, -122.60, ""},
+	}
+
+	for _, testCase := range testCases {
+		found := ""
+		for _, channel := range channels {
+			if channel.contains(testCase.lat, testCase.lon) {
+				found = channel.Name
+			}
+		}
+		if found != testCase.expected {
+			t.Errorf("ERROR: For %f, %f expected %q, got %q", testCase.lat, testCase.lon, testCase.expected, found)
+		}
+	}
+}
+
+func TestCheckKeel(t *testing.T) {
+	cfg := DefaultConfig()
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	// The tide rises a foot every 10 minutes from 3 ft now.
+	tide := func(at time.Time) (float64, bool) {
+		return 3 + at.Sub(now).Minutes()/10, true
+	}
+	checker := NewChecker(cfg, nil, tide)
+
+	// 16 m is 52.5 ft: more than the 55 ft Bar Channel allows with a 5 ft margin.
+	inbound := database.Ship{MMSI: "636019825", Lat: 37.74, Lon: -122.70, Course: 60, Speed: 12, Draught: 16}
+
+	testCases := []struct {
+		details database.Ship
+		level   string
+	}{
+		{inbound, TideConstrained},
+		// Deeper than the bar allows at the tide when she gets there.
+		{database.Ship{Lat: 37.74, Lon: -122.70, Course: 60, Speed: 12, Draught: 18}, TooShallow},
+		// Plenty of water.
+		{database.Ship{Lat: 37.74, Lon: -122.70, Course: 60, Speed: 12, Draught: 10}, ""},
+		// Heading out to sea, away from the channel.
+		{database.Ship{Lat: 37.74, Lon: -122.70, Course: 240, Speed: 12, Draught: 16}, ""},
+		// No draught reported.
+		{database.Ship{Lat: 37.74, Lon: -122.70, Course: 60, Speed: 12}, ""},
+	}
+
+	for _, testCase := range testCases {
+		found := checker.CheckKeel(testCase.details, now)
+		if testCase.level == "" {
+			if len(found) != 0 {
+				t.Errorf("ERROR: For %v expected nothing, got %v", testCase.details, found)
+			}
+			continue
+		}
+		if len(found) != 1 || found[0].Channel.Name != "San Francisco Bar Channel" || found[0].Level != testCase.level {
+			t.Errorf("ERROR: For %v expected %s in the Bar Channel, got %v", testCase.details, testCase.level, found)
+		}
+	}
+
+	// The ship reaches the channel on a rising tide; the shallowest point is the first.
+	found := checker.CheckKeel(inbound, now)
+	if len(found) == 1 && (found[0].At.Before(now) || found[0].UKC != 55+found[0].Tide-found[0].Draught) {
+		t.Errorf("ERROR: Expected the clearance when the ship enters the channel, got %v", found[0])
+	}
+
+	warnings := checker.Warnings(inbound, now)
+	if len(warnings) != 1 {
+		t.Errorf("ERROR: Expected one warning, got %v", warnings)
+	}
+}
+
+func TestConfigUnmarshal(t *testing.T) {
+	cfg := DefaultConfig()
+
+	err := json.Unmarshal([]byte(`{"lookahead": "45m", "keelMargin": 3, "airDrafts": {"311000917": 210}}`), &cfg)
+	if err != nil || cfg.Lookahead != 45*time.Minute || cfg.KeelMargin != 3 || cfg.AirDrafts["311000917"] != 210 || len(cfg.Bridges) != 3 {
+		t.Errorf("ERROR: Expected the settings from JSON over the defaults, got %+v %v", cfg, err)
+	}
+
+	err = json.Unmarshal([]byte(`{"lookahead": "soon"}`), &cfg)
+	if err == nil {
+		t.Errorf("ERROR: Expected a bad lookahead to fail")
+	}
+}
@@ -0,0 +1,149 @@
+package clearance
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/erikbryant/shipahoy/database"
+	"github.com/erikbryant/shipahoy/geo"
+)
+
+// How tight the water under a ship is.
+const (
+	TideConstrained = "tide constrained" // only deep enough because of the tide
+	TooShallow      = "too shallow"      // not deep enough even with the tide
+)
+
+// Channel is a stretch of charted water, bounded by a polygon.
+type Channel struct {
+	Name    string       `json:"name"`
+	Depth   float64      `json:"depth"`   // controlling depth at MLLW, feet
+	Polygon [][2]float64 `json:"polygon"` // lat/lon corners, in order
+}
+
+// contains returns whether a lat/lon is inside the channel.
+func (c Channel) contains(lat, lon float64) bool {
+	inside := false
+
+	// Count the edges a ray heading east from the point crosses.
+	for i, j := 0, len(c.Polygon)-1; i < len(c.Polygon); j, i = i, i+1 {
+		latI, lonI := c.Polygon[i][0], c.Polygon[i][1]
+		latJ, lonJ := c.Polygon[j][0], c.Polygon[j][1]
+		if (latI > lat) != (latJ > lat) && lon < lonI+(lat-latI)*(lonJ-lonI)/(latJ-latI) {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// defaultChannels are the bay's deep draught channels, simplified.
+func defaultChannels() []Channel {
+	return []Channel{
+		{
+			Name:    "San Francisco Bar Channel",
+			Depth:   55,
+			Polygon: [][2]float64{{37.757, -122.640}, {37.771, -122.641}, {37.800, -122.563}, {37.787, -122.557}},
+		},
+		{
+			Name:    "Golden Gate",
+			Depth:   110,
+			Polygon: [][2]float64{{37.803, -122.505}, {37.826, -122.505}, {37.826, -122.462}, {37.803, -122.462}},
+		},
+		{
+			Name:    "Oakland Estuary",
+			Depth:   50,
+			Polygon: [][2]float64{{37.800, -122.350}, {37.813, -122.350}, {37.800, -122.300}, {37.780, -122.255}, {37.772, -122.262}, {37.793, -122.305}},
+		},
+	}
+}
+
+// KeelTransit is a deep draught ship heading through a channel with little water to spare.
+type KeelTransit struct {
+	Channel Channel
+	Draught float64   // feet
+	Tide    float64   // predicted water level above MLLW when the ship gets there, feet
+	UKC     float64   // under-keel clearance: charted depth plus tide less draught, feet
+	At      time.Time // when the ship gets there
+	Level   string    // TideConstrained or TooShallow
+}
+
+// String describes the transit.
+func (k KeelTransit) String() string {
+	return fmt.Sprintf("%s in the %s at %s: %.0f ft charted, %.1f ft tide, %.0f ft draught, %.1f ft under the keel",
+		k.Level, k.Channel.Name, k.At.Format("15:04"), k.Channel.Depth, k.Tide, k.Draught, k.UKC)
+}
+
+// waypoint is where a ship is expected to be, and when.
+type waypoint struct {
+	lat float64
+	lon float64
+	at  time.Time
+}
+
+// track returns where a ship will be over the next while, a step apart,
+// if it holds its course and speed. The first point is where it is now.
+func track(details database.Ship, now time.Time, ahead, step time.Duration) []waypoint {
+	var points []waypoint
+
+	for elapsed := time.Duration(0); elapsed <= ahead; elapsed += step {
+		lat, lon := geo.Destination(details.Lat, details.Lon, details.Course, details.Speed*elapsed.Hours())
+		points = append(points, waypoint{lat: lat, lon: lon, at: now.Add(elapsed)})
+		if details.Speed < 1 {
+			break
+		}
+	}
+
+	return points
+}
+
+// CheckKeel returns the channels along a ship's projected track where it
+// has little water under its keel. Each channel is reported once, at its
+// shallowest point.
+func (c *Checker) CheckKeel(details database.Ship, now time.Time) []KeelTransit {
+	if details.Draught <= 0 || c.tide == nil {
+		return nil
+	}
+	draught := details.Draught * feetPerMeter
+
+	var found []KeelTransit
+	seen := map[string]int{}
+
+	for _, p := range track(details, now, c.cfg.Lookahead, 5*time.Minute) {
+		for _, channel := range c.cfg.Channels {
+			if !channel.contains(p.lat, p.lon) {
+				continue
+			}
+			tide, ok := c.tide(p.at)
+			if !ok {
+				continue
+			}
+
+			k := KeelTransit{
+				Channel: channel,
+				Draught: draught,
+				Tide:    tide,
+				UKC:     channel.Depth + tide - draught,
+				At:      p.at,
+			}
+			switch {
+			case k.UKC < c.cfg.KeelMargin:
+				k.Level = TooShallow
+			case channel.Depth-draught < c.cfg.KeelMargin:
+				k.Level = TideConstrained
+			default:
+				continue
+			}
+
+			i, ok := seen[channel.Name]
+			if !ok {
+				seen[channel.Name] = len(found)
+				found = append(found, k)
+			} else if k.UKC < found[i].UKC {
+				found[i] = k
+			}
+		}
+	}
+
+	return found
+}
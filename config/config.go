@@ -0,0 +1,118 @@
+// Package config gathers the settings for every part of shipahoy. They
+// come from the defaults, then an optional JSON file, then the
+// environment; main applies its command line flags last.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/erikbryant/shipahoy/alert"
+	"github.com/erikbryant/shipahoy/clearance"
+	"github.com/erikbryant/shipahoy/database"
+	"github.com/erikbryant/shipahoy/landmark"
+	"github.com/erikbryant/shipahoy/mqtt"
+	"github.com/erikbryant/shipahoy/speech"
+)
+
+// Config holds the settings for every part of shipahoy.
+type Config struct {
+	Database  database.Config     `json:"database"`
+	Clearance clearance.Config    `json:"clearance"`
+	Webhook   alert.WebhookConfig `json:"webhook"`
+	MQTT      mqtt.Config         `json:"mqtt"`
+	Email     alert.EmailConfig   `json:"email"`
+	Speech    speech.Config       `json:"speech"`
+	Observers []alert.Observer    `json:"observers"`
+	Landmarks landmark.Config     `json:"landmarks"`
+	Sound     alert.SoundConfig   `json:"sound"`
+}
+
+// Default returns the settings used unless told otherwise.
+func Default() Config {
+	return Config{
+		Database:  database.DefaultConfig(),
+		Clearance: clearance.DefaultConfig(),
+		Webhook:   alert.DefaultWebhookConfig(),
+		MQTT:      mqtt.DefaultConfig(),
+		Email:     alert.DefaultEmailConfig(),
+		Speech:    speech.DefaultConfig(),
+		Observers: alert.DefaultObservers(),
+		Landmarks: landmark.DefaultConfig(),
+		Sound:     alert.DefaultSoundConfig(),
+	}
+}
+
+// Load returns the default settings, overridden by those in the JSON file
+// at path (if path is not empty) and then by the environment. A list in
+// the file replaces the default list; its elements do not take the
+// default elements' settings.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		// Decoding into a list reuses the elements already there, so the
+		// lists are decoded from empty and the defaults put back after.
+		defaults := Default()
+		cfg.Observers = nil
+		cfg.Clearance.Bridges = nil
+		cfg.Clearance.Channels = nil
+		cfg.Landmarks.Landmarks = nil
+		cfg.Sound.Rules = nil
+		err = json.Unmarshal(contents, &cfg)
+		if err != nil {
+			return defaults, fmt.Errorf("%s: %w", path, err)
+		}
+		if cfg.Observers == nil {
+			cfg.Observers = defaults.Observers
+		}
+		if cfg.Clearance.Bridges == nil {
+			cfg.Clearance.Bridges = defaults.Clearance.Bridges
+		}
+		if cfg.Clearance.Channels == nil {
+			cfg.Clearance.Channels = defaults.Clearance.Channels
+		}
+		if cfg.Landmarks.Landmarks == nil {
+			cfg.Landmarks.Landmarks = defaults.Landmarks.Landmarks
+		}
+		if cfg.Sound.Rules == nil {
+			cfg.Sound.Rules = defaults.Sound.Rules
+		}
+	}
+	for i, o := range cfg.Observers {
+		cfg.Observers[i] = o.WithDefaults()
+	}
+
+	cfg.applyEnv(os.Getenv)
+
+	return cfg, nil
+}
+
+// applyEnv overrides settings with any that are set in the environment.
+// Secrets such as the database, MQTT and SMTP passwords and the webhook
+// key are best kept here rather than in a file.
+func (c *Config) applyEnv(getenv func(string) string) {
+	vars := []struct {
+		name  string
+		field *string
+	}{
+		{"SHIPAHOY_DB_DRIVER", &c.Database.Driver},
+		{"SHIPAHOY_DB_DSN", &c.Database.DSN},
+		{"SHIPAHOY_WEBHOOK_SECRET", &c.Webhook.Secret},
+		{"SHIPAHOY_MQTT_BROKER", &c.MQTT.Broker},
+		{"SHIPAHOY_MQTT_PASSWORD", &c.MQTT.Password},
+		{"SHIPAHOY_SMTP_PASSWORD", &c.Email.Password},
+	}
+
+	for _, v := range vars {
+		value := getenv(v.name)
+		if value != "" {
+			*v.field = value
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shipahoy.json")
+	err := os.WriteFile(path, []byte(`{"database": {"driver": "sqlite", "dsn": "ships.db", "timeout": "2s"}}`), 0644)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to load config: %v", err)
+	}
+
+	if cfg.Database.Driver != "sqlite" || cfg.Database.DSN != "ships.db" || cfg.Database.Timeout != 2*time.Second {
+		t.Errorf("ERROR: Expected settings from the file, got %+v", cfg.Database)
+	}
+	if cfg.Database.MaxOpenConns != Default().Database.MaxOpenConns {
+		t.Errorf("ERROR: Expected settings not in the file to keep their defaults, got %+v", cfg.Database)
+	}
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err == nil {
+		t.Errorf("ERROR: Expected a missing file to fail")
+	}
+}
+
+func TestLoadLists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shipahoy.json")
+	err := os.WriteFile(path, []byte(`{
+		"observers": [
+			{"name": "kitchen", "channels": ["speech"]},
+			{"name": "abuela", "language": "es"}
+		],
+		"clearance": {"bridges": [
+			{"name": "Golden Gate Bridge", "lat": 37.8199, "lon": -122.4783, "clearance": 220},
+			{"name": "Carquinez Bridge", "lat": 38.0614, "lon": -122.2258, "clearance": 146}
+		]}
+	}`), 0644)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to load config: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		language string
+		channels string
+	}{
+		{"kitchen", "en", "speech"},
+		{"abuela", "es", "console"},
+	}
+	if len(cfg.Observers) != len(testCases) {
+		t.Fatalf("ERROR: Expected %d observers, got %+v", len(testCases), cfg.Observers)
+	}
+	for i, testCase := range testCases {
+		o := cfg.Observers[i]
+		if o.Name != testCase.name || o.Language != testCase.language || o.Detail != "normal" || strings.Join(o.Channels, ",") != testCase.channels {
+			t.Errorf("ERROR: Expected %s to hear %s in %s, got %+v", testCase.name, testCase.channels, testCase.language, o)
+		}
+	}
+
+	// The second bridge is not the default second bridge.
+	bridges := cfg.Clearance.Bridges
+	if len(bridges) != 2 || bridges[1].Name != "Carquinez Bridge" || bridges[1].Station != "" || bridges[1].MHHW != 0 {
+		t.Errorf("ERROR: Expected only the bridges in the file, got %+v", bridges)
+	}
+	if len(cfg.Landmarks.Landmarks) != len(Default().Landmarks.Landmarks) {
+		t.Errorf("ERROR: Expected the default landmarks, got %d", len(cfg.Landmarks.Landmarks))
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"SHIPAHOY_DB_DSN": "ships:secret@tcp(db:3306)/ship_ahoy",
+	}
+
+	cfg := Default()
+	cfg.applyEnv(func(name string) string { return env[name] })
+
+	if cfg.Database.DSN != env["SHIPAHOY_DB_DSN"] {
+		t.Errorf("ERROR: Expected DSN from the environment, got %s", cfg.Database.DSN)
+	}
+	if cfg.Database.Driver != "mysql" {
+		t.Errorf("ERROR: Expected unset variables to leave the default, got %s", cfg.Database.Driver)
+	}
+}
@@ -1,36 +1,44 @@
 package database
 
 //
-// Consumers of this package need to add this import line:
+// Consumers of this package need to add the import line for the
+// driver(s) they use:
 //
 //	_ "github.com/go-sql-driver/mysql"
+//	_ "modernc.org/sqlite"
 //
 
 import (
-	"database/sql"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 )
 
 // Ship holds all the information we get back from the web service about a single ship.
 type Ship struct {
 	// Stored in db ...
-	MMSI              string
-	IMO               string
-	Name              string
-	AIS               int // not currently available
-	Type              string
-	SAR               bool
-	DirectLink        string
-	Draught           float64
-	Year              int
-	GT                int
-	Length            int
-	Beam              int
-	DW                int
-	Flag              string
-	InvalidDimensions bool
-	MarineTrafficID   int64
+	MMSI              string  `db:"mmsi"`
+	IMO               string  `db:"imo"`
+	Name              string  `db:"name"`
+	AIS               int     `db:"ais"` // not currently available
+	Type              string  `db:"type"`
+	SAR               bool    `db:"sar"`
+	DirectLink        string  `db:"direct_link"`
+	Draught           float64 `db:"draught"`
+	Year              int     `db:"year"`
+	GT                int     `db:"gt"`
+	Length            int     `db:"length"`
+	Beam              int     `db:"beam"`
+	DW                int     `db:"dw"`
+	Flag              string  `db:"flag"`
+	InvalidDimensions bool    `db:"invalidDimensions"`
+	MarineTrafficID   int64   `db:"marineTrafficID"`
+	CallSign          string  `db:"call_sign"`
+	ENI               string  `db:"eni"`
+	DataQuality       int     `db:"data_quality"` // bitmask of shipid quality flags
 
 	// Not stored in db ...
 	Lat                float64
@@ -49,175 +57,379 @@ type Ship struct {
 
 // Sighting holds the relevant information about a ship sighting.
 type Sighting struct {
-	MMSI               string
-	ShipCourse         float64
-	Timestamp          int64
-	Lat                float64
-	Lon                float64
-	MyLat              float64
-	MyLon              float64
-	NavigationalStatus int64
+	MMSI               string  `db:"mmsi"`
+	ShipCourse         float64 `db:"ship_course"`
+	Timestamp          int64   `db:"timestamp"`
+	Lat                float64 `db:"lat"`
+	Lon                float64 `db:"lon"`
+	MyLat              float64 `db:"my_lat"`
+	MyLon              float64 `db:"my_lon"`
+	NavigationalStatus int64   `db:"nav_status"`
 }
 
-// NoaaDatum holds the information we get back from the NOAA web service.
-type NoaaDatum struct {
-	Station string
-	Product string
-	Datum   string
-	Value   string
-	S       string
-	Flags   string
-	// processing_level string // "p" - preliminary, "v" - verified
+// ShipChange holds one change to a stored field of a ship.
+type ShipChange struct {
+	MMSI      string `db:"mmsi"`
+	Timestamp int64  `db:"timestamp"`
+	Field     string `db:"field"`
+	OldValue  string `db:"old_value"`
+	NewValue  string `db:"new_value"`
+	Source    string `db:"source"`
 }
 
-var (
-	db *sql.DB
-)
-
-// Open opens a connection to the database.
-func Open() error {
-	var err error
+// Position holds a single position report for a ship.
+type Position struct {
+	MMSI               string  `db:"mmsi"`
+	Timestamp          int64   `db:"timestamp"` // when the ship reported this position
+	Lat                float64 `db:"lat"`
+	Lon                float64 `db:"lon"`
+	Speed              float64 `db:"sog"` // speed over ground
+	Course             float64 `db:"cog"` // course over ground
+	Heading            float64 `db:"heading"`
+	NavigationalStatus int     `db:"nav_status"`
+	Source             string  `db:"source"`
+}
 
-	db, err = sql.Open("mysql", "ships:ships_password@tcp(127.0.0.1:3306)/ship_ahoy")
-	return err
+// Anomaly holds a suspicious observation of a ship's identity or position.
+type Anomaly struct {
+	MMSI      string  `db:"mmsi"`
+	Timestamp int64   `db:"timestamp"`
+	Kind      string  `db:"kind"`
+	Detail    string  `db:"detail"`
+	Lat       float64 `db:"lat"`
+	Lon       float64 `db:"lon"`
 }
 
-// Close closes the connection to the database opened by Open.
-func Close() {
-	db.Close()
+// NoaaDatum holds the information we get back from the NOAA web service.
+type NoaaDatum struct {
+	Station         string `db:"station"`
+	Product         string `db:"product"`
+	Datum           string `db:"datum"`
+	Value           string `db:"value"`
+	S               string `db:"s"`
+	Flags           string `db:"flags"`
+	ProcessingLevel string `db:"processing_level"` // "p" - preliminary, "v" - verified
+	Timestamp       int64  `db:"timestamp"`        // Unix time of the reading
 }
 
-// SaveShip writes ship details to the database.
-func SaveShip(details Ship) {
-	sqlString := "INSERT IGNORE INTO ships ( mmsi, imo, name, ais, Type, sar, direct_link, draught, year, gt, length, beam, dw, flag, invalidDimensions, marineTrafficID ) VALUES ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? )"
+// Config says which database to use and how to talk to it.
+type Config struct {
+	// "mysql", "sqlite" or "memory".
+	Driver string `json:"driver"`
+	// The driver's data source name; for SQLite, the file name.
+	DSN string `json:"dsn"`
+
+	// Connection pool tuning. SQLite always uses a single connection.
+	MaxOpenConns    int           `json:"maxOpenConns"`
+	MaxIdleConns    int           `json:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `json:"connMaxLifetime"`
+	ConnMaxIdleTime time.Duration `json:"connMaxIdleTime"`
+
+	// How long any one call may take, unless the caller's context says otherwise.
+	Timeout time.Duration `json:"timeout"`
+}
 
-	_, err := db.Exec(sqlString, details.MMSI, details.IMO, details.Name, details.AIS, details.Type, details.SAR, details.DirectLink, details.Draught, details.Year, details.GT, details.Length, details.Beam, details.DW, details.Flag, details.InvalidDimensions, details.MarineTrafficID)
-	if err != nil {
-		fmt.Println("dbSaveShip Exec:", err)
+// DefaultConfig returns the settings used unless told otherwise: the local
+// MySQL server set up as in README.md.
+func DefaultConfig() Config {
+	return Config{
+		Driver:          "mysql",
+		DSN:             "ships:ships_password@tcp(127.0.0.1:3306)/ship_ahoy",
+		MaxOpenConns:    10,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: 5 * time.Minute,
+		Timeout:         10 * time.Second,
 	}
 }
 
-// LookupShip reads ship details from the database.
-func LookupShip(mmsi string) (Ship, bool) {
-	var details Ship
+// UnmarshalJSON reads a Config, taking durations as strings such as "5s".
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type config Config
 
-	sqlString := "SELECT * FROM ships WHERE mmsi = " + mmsi + " LIMIT 1"
+	raw := struct {
+		*config
+		ConnMaxLifetime string `json:"connMaxLifetime"`
+		ConnMaxIdleTime string `json:"connMaxIdleTime"`
+		Timeout         string `json:"timeout"`
+	}{config: (*config)(c)}
 
-	rows := db.QueryRow(sqlString)
-	err := rows.Scan(&details.MMSI, &details.IMO, &details.Name, &details.AIS, &details.Type, &details.SAR, &details.DirectLink, &details.Draught, &details.Year, &details.GT, &details.Length, &details.Beam, &details.DW, &details.Flag, &details.InvalidDimensions, &details.MarineTrafficID)
+	err := json.Unmarshal(data, &raw)
 	if err != nil {
-		if err != sql.ErrNoRows {
-			fmt.Println("lookup_ship Scan:", err)
+		return err
+	}
+
+	durations := []struct {
+		name  string
+		value string
+		field *time.Duration
+	}{
+		{"connMaxLifetime", raw.ConnMaxLifetime, &c.ConnMaxLifetime},
+		{"connMaxIdleTime", raw.ConnMaxIdleTime, &c.ConnMaxIdleTime},
+		{"timeout", raw.Timeout, &c.Timeout},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		*d.field, err = time.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("database %s: %w", d.name, err)
 		}
-		return details, false
 	}
 
-	details.Sightings = CountSightings(details.MMSI)
+	return nil
+}
+
+// Store is a place to keep ships, sightings, positions and NOAA readings.
+// Lookups that find nothing return false, not an error.
+type Store interface {
+	Close() error
+
+	SaveShip(ctx context.Context, details Ship, source string) error
+	LookupShip(ctx context.Context, mmsi string) (Ship, bool, error)
+	LookupShipExists(ctx context.Context, mmsi string) (bool, error)
+	LookupShipHistory(ctx context.Context, mmsi string) ([]ShipChange, error)
+
+	SaveSighting(ctx context.Context, details Ship, myLat, myLon float64) error
+	LookupSighting(ctx context.Context, details Ship) (Sighting, bool, error)
+	LookupLastSighting(ctx context.Context, details Ship) (int64, error)
+	CountSightings(ctx context.Context, mmsi string) (int64, error)
+	CountSightingsBefore(ctx context.Context, mmsi string, before int64) (int64, error)
+	LookupSightings(ctx context.Context, from, to int64) ([]Sighting, error)
 
-	return details, true
+	SavePositions(ctx context.Context, positions []Position) error
+	LookupTrack(ctx context.Context, mmsi string, from, to int64) ([]Position, error)
+	LookupLastPosition(ctx context.Context, mmsi string) (Position, bool, error)
+
+	SaveAnomaly(ctx context.Context, anomaly Anomaly) error
+
+	SaveNoaaData(ctx context.Context, data []NoaaDatum) error
+	LookupNoaaData(ctx context.Context, station, product string, from, to int64) ([]NoaaDatum, error)
+	LookupLatestNoaaDatum(ctx context.Context, station, product string) (NoaaDatum, bool, error)
+
+	CountRows(ctx context.Context, table string) (int64, error)
 }
 
-// LookupShipExists is [hopefully] faster than loading the entire record like dbLookupShip() does.
-func LookupShipExists(mmsi string) bool {
-	var exists int
+// ErrUnknownTable is returned when asked about a table we do not keep.
+var ErrUnknownTable = errors.New("unknown table")
 
-	sqlString := "SELECT EXISTS( SELECT mmsi FROM ships WHERE mmsi = " + mmsi + " LIMIT 1 )"
+var (
+	// The store used by the package level functions.
+	store Store
 
-	rows := db.QueryRow(sqlString)
-	err := rows.Scan(&exists)
-	if err != nil {
-		if err != sql.ErrNoRows {
-			fmt.Println("lookup_ship_exists Scan:", err)
+	// The tables TableStats reports on.
+	tables = []string{"ships", "ship_history", "sightings", "positions", "anomalies", "noaa_readings"}
+)
+
+// knownTable returns whether the table is one of ours.
+func knownTable(table string) bool {
+	for _, t := range tables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+// Open opens the database described by cfg, creating or upgrading its
+// tables as needed.
+func Open(ctx context.Context, cfg Config) error {
+	switch cfg.Driver {
+	case "memory":
+		store = NewMemoryStore()
+		return nil
+	case "mysql", "sqlite":
+		s, err := openSQL(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("open %s database: %w", cfg.Driver, err)
 		}
-		return false
+		store = s
+		return nil
 	}
 
-	return exists == 1
+	return fmt.Errorf("unknown database driver %q", cfg.Driver)
 }
 
-// SaveSighting writes the ship sighting details to the database.
-func SaveSighting(details Ship, myLat, myLon float64) {
-	sqlString := "INSERT IGNORE INTO sightings ( mmsi, ship_course, timestamp, lat, lon, my_lat, my_lon ) VALUES ( ?, ?, ?, ?, ?, ?, ?)"
+// Use makes the package level functions use the given store.
+func Use(s Store) {
+	store = s
+}
 
-	_, err := db.Exec(sqlString, details.MMSI, details.Course, time.Now().Unix(), details.Lat, details.Lon, myLat, myLon)
-	if err != nil {
-		fmt.Println("dbSaveSighting Exec:", err)
-	}
+// Close closes the database opened by Open.
+func Close() error {
+	return store.Close()
 }
 
-// LookupSighting reads sighting details from the database.
-func LookupSighting(details Ship) (Sighting, bool) {
-	var sighting Sighting
+// diffShips compares incoming ship details against what is stored. Fields
+// the source left blank are filled in from the stored record rather than
+// erasing it. It returns the stored fields whose values changed.
+func diffShips(stored Ship, details *Ship) []ShipChange {
+	var changes []ShipChange
 
-	sqlString := "SELECT * FROM sightings WHERE mmsi = " + details.MMSI + " ORDER BY timestamp DESC LIMIT 1"
+	add := func(field, oldValue, newValue string) {
+		if oldValue != newValue {
+			changes = append(changes, ShipChange{Field: field, OldValue: oldValue, NewValue: newValue})
+		}
+	}
 
-	rows := db.QueryRow(sqlString)
-	err := rows.Scan(&sighting.MMSI, &sighting.ShipCourse, &sighting.Timestamp, &sighting.Lat, &sighting.Lon, &sighting.MyLat, &sighting.MyLon)
-	if err != nil {
-		if err != sql.ErrNoRows {
-			fmt.Println("lookup_sighting Scan:", err)
+	str := func(field string, old string, new *string) {
+		if *new == "" {
+			*new = old
 		}
-		return sighting, false
+		add(field, old, *new)
 	}
+	integer := func(field string, old int, new *int) {
+		if *new == 0 {
+			*new = old
+		}
+		add(field, strconv.Itoa(old), strconv.Itoa(*new))
+	}
+	boolean := func(field string, old bool, new bool) {
+		add(field, strconv.FormatBool(old), strconv.FormatBool(new))
+	}
+
+	str("imo", stored.IMO, &details.IMO)
+	str("name", stored.Name, &details.Name)
+	integer("ais", stored.AIS, &details.AIS)
+	str("type", stored.Type, &details.Type)
+	boolean("sar", stored.SAR, details.SAR)
+	str("direct_link", stored.DirectLink, &details.DirectLink)
+	if details.Draught == 0 {
+		details.Draught = stored.Draught
+	}
+	add("draught", strconv.FormatFloat(stored.Draught, 'f', -1, 64), strconv.FormatFloat(details.Draught, 'f', -1, 64))
+	integer("year", stored.Year, &details.Year)
+	integer("gt", stored.GT, &details.GT)
+	integer("length", stored.Length, &details.Length)
+	integer("beam", stored.Beam, &details.Beam)
+	integer("dw", stored.DW, &details.DW)
+	str("flag", stored.Flag, &details.Flag)
+	boolean("invalidDimensions", stored.InvalidDimensions, details.InvalidDimensions)
+	if details.MarineTrafficID == 0 {
+		details.MarineTrafficID = stored.MarineTrafficID
+	}
+	add("marineTrafficID", strconv.FormatInt(stored.MarineTrafficID, 10), strconv.FormatInt(details.MarineTrafficID, 10))
+	str("call_sign", stored.CallSign, &details.CallSign)
+	str("eni", stored.ENI, &details.ENI)
+	// data_quality is derived from the other fields, so its changes are not history.
 
-	return sighting, true
+	return changes
 }
 
-// LookupLastSighting is [hopefully] faster than dbLookupSighting() because it only queries the timestamp.
-func LookupLastSighting(details Ship) (timestamp int64) {
-	sqlString := "SELECT timestamp FROM sightings WHERE mmsi = " + details.MMSI + " ORDER BY timestamp DESC LIMIT 1"
+// SaveShip writes ship details to the database, updating any record we
+// already have for the ship. Each changed field is logged in ship_history
+// against the given source.
+func SaveShip(ctx context.Context, details Ship, source string) error {
+	return store.SaveShip(ctx, details, source)
+}
 
-	rows := db.QueryRow(sqlString)
-	err := rows.Scan(&timestamp)
-	if err != nil && err != sql.ErrNoRows {
-		fmt.Println("lookup_last_sighting Scan:", err)
-	}
+// LookupShip reads ship details from the database.
+func LookupShip(ctx context.Context, mmsi string) (Ship, bool, error) {
+	return store.LookupShip(ctx, mmsi)
+}
+
+// LookupShipExists is [hopefully] faster than loading the entire record like LookupShip() does.
+func LookupShipExists(ctx context.Context, mmsi string) (bool, error) {
+	return store.LookupShipExists(ctx, mmsi)
+}
+
+// LookupShipHistory reads every recorded change to a ship's stored details, oldest first.
+func LookupShipHistory(ctx context.Context, mmsi string) ([]ShipChange, error) {
+	return store.LookupShipHistory(ctx, mmsi)
+}
+
+// SaveSighting writes the ship sighting details to the database.
+func SaveSighting(ctx context.Context, details Ship, myLat, myLon float64) error {
+	return store.SaveSighting(ctx, details, myLat, myLon)
+}
 
-	return
+// LookupSighting reads sighting details from the database.
+func LookupSighting(ctx context.Context, details Ship) (Sighting, bool, error) {
+	return store.LookupSighting(ctx, details)
+}
+
+// LookupLastSighting is [hopefully] faster than LookupSighting() because it only queries the timestamp.
+func LookupLastSighting(ctx context.Context, details Ship) (int64, error) {
+	return store.LookupLastSighting(ctx, details)
 }
 
 // CountSightings counts the number of times we have seen this ship.
-func CountSightings(mmsi string) (count int64) {
-	sqlString := "SELECT COUNT(*) FROM sightings WHERE mmsi = " + mmsi
+func CountSightings(ctx context.Context, mmsi string) (int64, error) {
+	return store.CountSightings(ctx, mmsi)
+}
 
-	rows := db.QueryRow(sqlString)
-	err := rows.Scan(&count)
-	if err != nil && err != sql.ErrNoRows {
-		fmt.Println("lookup_last_sighting Scan:", err)
-	}
+// CountSightingsBefore counts the number of times we saw this ship before a Unix time.
+func CountSightingsBefore(ctx context.Context, mmsi string, before int64) (int64, error) {
+	return store.CountSightingsBefore(ctx, mmsi, before)
+}
 
-	return
+// LookupSightings reads the sightings of every ship between two Unix times (inclusive), oldest first.
+func LookupSightings(ctx context.Context, from, to int64) ([]Sighting, error) {
+	return store.LookupSightings(ctx, from, to)
 }
 
-// CountRows returns the number of rows in the given table.
-func CountRows(table string) (int64, bool) {
-	var count int64
+// SavePositions writes a batch of position reports to the database.
+// Reports already stored (same ship, time and source) are skipped.
+func SavePositions(ctx context.Context, positions []Position) error {
+	return store.SavePositions(ctx, positions)
+}
+
+// LookupTrack reads the positions reported by a ship between two Unix times (inclusive), oldest first.
+func LookupTrack(ctx context.Context, mmsi string, from, to int64) ([]Position, error) {
+	return store.LookupTrack(ctx, mmsi, from, to)
+}
 
-	sqlString := "SELECT COUNT(*) FROM " + table
+// LookupLastPosition reads the most recent position reported by a ship.
+func LookupLastPosition(ctx context.Context, mmsi string) (Position, bool, error) {
+	return store.LookupLastPosition(ctx, mmsi)
+}
 
-	row := db.QueryRow(sqlString)
-	err := row.Scan(&count)
-	if err != nil {
-		if err != sql.ErrNoRows {
-			fmt.Println("count_rows Scan:", err)
-		}
-		return 0, false
-	}
+// SaveAnomaly writes an anomaly to the database.
+func SaveAnomaly(ctx context.Context, anomaly Anomaly) error {
+	return store.SaveAnomaly(ctx, anomaly)
+}
+
+// SaveNoaaDatum writes a NOAA reading to the database. A reading already
+// stored (same station, product and time) is skipped.
+func SaveNoaaDatum(ctx context.Context, datum NoaaDatum) error {
+	return store.SaveNoaaData(ctx, []NoaaDatum{datum})
+}
+
+// SaveNoaaData writes a batch of NOAA readings to the database. Readings
+// already stored (same station, product and time) are skipped.
+func SaveNoaaData(ctx context.Context, data []NoaaDatum) error {
+	return store.SaveNoaaData(ctx, data)
+}
 
-	return count, true
+// LookupNoaaData reads the readings of a product at a station between two Unix times (inclusive), oldest first.
+func LookupNoaaData(ctx context.Context, station, product string, from, to int64) ([]NoaaDatum, error) {
+	return store.LookupNoaaData(ctx, station, product, from, to)
+}
+
+// LookupLatestNoaaDatum reads the most recent reading of a product at a station.
+func LookupLatestNoaaDatum(ctx context.Context, station, product string) (NoaaDatum, bool, error) {
+	return store.LookupLatestNoaaDatum(ctx, station, product)
+}
+
+// CountRows returns the number of rows in the given table.
+func CountRows(ctx context.Context, table string) (int64, error) {
+	return store.CountRows(ctx, table)
 }
 
-// TableStats prints interesting statistics about the size of the database.
-func TableStats() map[string]int64 {
-	tables := []string{"ships", "sightings"}
+// TableStats returns the number of rows in each table. Tables that could
+// not be counted are left out and their errors returned.
+func TableStats(ctx context.Context) (map[string]int64, error) {
 	counts := map[string]int64{}
+	var errs []error
 
 	for _, table := range tables {
-		count, ok := CountRows(table)
-		if ok {
-			counts[table] = count
+		count, err := CountRows(ctx, table)
+		if err != nil {
+			errs = append(errs, err)
+			continue
 		}
+		counts[table] = count
 	}
 
-	return counts
+	return counts, errors.Join(errs...)
 }
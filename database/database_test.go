@@ -0,0 +1,55 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestDiffShips(t *testing.T) {
+	stored := Ship{MMSI: "538007561", IMO: "9074729", Name: "EVER GIVEN", Flag: "PA", Length: 400, MarineTrafficID: 123}
+
+	testCases := []struct {
+		details  Ship
+		expected []ShipChange
+		merged   Ship
+	}{
+		{
+			Ship{MMSI: "538007561", IMO: "9074729", Name: "EVER GIVEN", Flag: "PA", Length: 400, MarineTrafficID: 123},
+			nil,
+			Ship{MMSI: "538007561", IMO: "9074729", Name: "EVER GIVEN", Flag: "PA", Length: 400, MarineTrafficID: 123},
+		},
+		{
+			// Renamed and reflagged.
+			Ship{MMSI: "538007561", IMO: "9074729", Name: "EVER GREEN", Flag: "MH", Length: 400},
+			[]ShipChange{
+				{Field: "name", OldValue: "EVER GIVEN", NewValue: "EVER GREEN"},
+				{Field: "flag", OldValue: "PA", NewValue: "MH"},
+			},
+			Ship{MMSI: "538007561", IMO: "9074729", Name: "EVER GREEN", Flag: "MH", Length: 400, MarineTrafficID: 123},
+		},
+		{
+			// Blank fields do not erase what we know.
+			Ship{MMSI: "538007561", Length: 399},
+			[]ShipChange{
+				{Field: "length", OldValue: "400", NewValue: "399"},
+			},
+			Ship{MMSI: "538007561", IMO: "9074729", Name: "EVER GIVEN", Flag: "PA", Length: 399, MarineTrafficID: 123},
+		},
+	}
+
+	for _, testCase := range testCases {
+		details := testCase.details
+		answer := diffShips(stored, &details)
+		if len(answer) != len(testCase.expected) {
+			t.Errorf("ERROR: For %v expected %v, got %v", testCase.details, testCase.expected, answer)
+			continue
+		}
+		for i := range answer {
+			if answer[i] != testCase.expected[i] {
+				t.Errorf("ERROR: For %v expected %v, got %v", testCase.details, testCase.expected, answer)
+			}
+		}
+		if details != testCase.merged {
+			t.Errorf("ERROR: For %v expected merged %v, got %v", testCase.details, testCase.merged, details)
+		}
+	}
+}
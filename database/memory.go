@@ -0,0 +1,351 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps everything in maps and slices. Nothing survives a
+// restart. It is meant for tests and for trying the program out.
+type memoryStore struct {
+	mu        sync.Mutex
+	ships     map[string]Ship
+	history   []ShipChange
+	sightings []Sighting
+	positions []Position
+	reported  map[Position]bool
+	anomalies []Anomaly
+	noaa      []NoaaDatum
+	readings  map[NoaaDatum]bool
+}
+
+// NewMemoryStore returns an empty store that lives only in memory.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		ships:    map[string]Ship{},
+		reported: map[Position]bool{},
+		readings: map[NoaaDatum]bool{},
+	}
+}
+
+// Close discards the contents of the store.
+func (m *memoryStore) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ships = map[string]Ship{}
+	m.history = nil
+	m.sightings = nil
+	m.positions = nil
+	m.reported = map[Position]bool{}
+	m.anomalies = nil
+	m.noaa = nil
+	m.readings = map[NoaaDatum]bool{}
+
+	return nil
+}
+
+// SaveShip writes ship details to the store, logging each changed field.
+func (m *memoryStore) SaveShip(ctx context.Context, details Ship, source string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, exists := m.ships[details.MMSI]
+	if exists {
+		now := time.Now().Unix()
+		for _, change := range diffShips(stored, &details) {
+			change.MMSI = details.MMSI
+			change.Timestamp = now
+			change.Source = source
+			m.history = append(m.history, change)
+		}
+	}
+
+	m.ships[details.MMSI] = details
+
+	return nil
+}
+
+// LookupShip reads ship details from the store.
+func (m *memoryStore) LookupShip(ctx context.Context, mmsi string) (Ship, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	details, ok := m.ships[mmsi]
+	if !ok {
+		return Ship{}, false, nil
+	}
+
+	details.Sightings = m.countSightings(mmsi)
+
+	return details, true, nil
+}
+
+// LookupShipExists returns whether the ship is in the store.
+func (m *memoryStore) LookupShipExists(ctx context.Context, mmsi string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.ships[mmsi]
+	return ok, nil
+}
+
+// LookupShipHistory reads every recorded change to a ship's stored details, oldest first.
+func (m *memoryStore) LookupShipHistory(ctx context.Context, mmsi string) ([]ShipChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var history []ShipChange
+	for _, change := range m.history {
+		if change.MMSI == mmsi {
+			history = append(history, change)
+		}
+	}
+
+	return history, nil
+}
+
+// SaveSighting writes the ship sighting details to the store.
+func (m *memoryStore) SaveSighting(ctx context.Context, details Ship, myLat, myLon float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sightings = append(m.sightings, Sighting{
+		MMSI:               details.MMSI,
+		ShipCourse:         details.Course,
+		Timestamp:          time.Now().Unix(),
+		Lat:                details.Lat,
+		Lon:                details.Lon,
+		MyLat:              myLat,
+		MyLon:              myLon,
+		NavigationalStatus: int64(details.NavigationalStatus),
+	})
+
+	return nil
+}
+
+// lastSighting returns the most recent sighting of a ship. The caller must hold the lock.
+func (m *memoryStore) lastSighting(mmsi string) (Sighting, bool) {
+	var last Sighting
+	found := false
+
+	for _, sighting := range m.sightings {
+		if sighting.MMSI == mmsi && (!found || sighting.Timestamp >= last.Timestamp) {
+			last = sighting
+			found = true
+		}
+	}
+
+	return last, found
+}
+
+// LookupSighting reads the most recent sighting of a ship.
+func (m *memoryStore) LookupSighting(ctx context.Context, details Ship) (Sighting, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sighting, ok := m.lastSighting(details.MMSI)
+	return sighting, ok, nil
+}
+
+// LookupLastSighting returns the time of the most recent sighting of a ship.
+func (m *memoryStore) LookupLastSighting(ctx context.Context, details Ship) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sighting, _ := m.lastSighting(details.MMSI)
+	return sighting.Timestamp, nil
+}
+
+// countSightings counts the sightings of a ship. The caller must hold the lock.
+func (m *memoryStore) countSightings(mmsi string) int64 {
+	var count int64
+
+	for _, sighting := range m.sightings {
+		if sighting.MMSI == mmsi {
+			count++
+		}
+	}
+
+	return count
+}
+
+// CountSightings counts the number of times we have seen this ship.
+func (m *memoryStore) CountSightings(ctx context.Context, mmsi string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.countSightings(mmsi), nil
+}
+
+// CountSightingsBefore counts the number of times we saw this ship before a Unix time.
+func (m *memoryStore) CountSightingsBefore(ctx context.Context, mmsi string, before int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int64
+	for _, sighting := range m.sightings {
+		if sighting.MMSI == mmsi && sighting.Timestamp < before {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// LookupSightings reads the sightings of every ship between two Unix times (inclusive), oldest first.
+func (m *memoryStore) LookupSightings(ctx context.Context, from, to int64) ([]Sighting, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sightings []Sighting
+	for _, s := range m.sightings {
+		if s.Timestamp >= from && s.Timestamp <= to {
+			sightings = append(sightings, s)
+		}
+	}
+
+	sort.SliceStable(sightings, func(i, j int) bool { return sightings[i].Timestamp < sightings[j].Timestamp })
+
+	return sightings, nil
+}
+
+// SavePositions writes a batch of position reports, skipping any already stored.
+func (m *memoryStore) SavePositions(ctx context.Context, positions []Position) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range positions {
+		key := Position{MMSI: p.MMSI, Timestamp: p.Timestamp, Source: p.Source}
+		if m.reported[key] {
+			continue
+		}
+		m.reported[key] = true
+		m.positions = append(m.positions, p)
+	}
+
+	return nil
+}
+
+// LookupTrack reads the positions reported by a ship between two Unix times (inclusive), oldest first.
+func (m *memoryStore) LookupTrack(ctx context.Context, mmsi string, from, to int64) ([]Position, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var track []Position
+	for _, p := range m.positions {
+		if p.MMSI == mmsi && p.Timestamp >= from && p.Timestamp <= to {
+			track = append(track, p)
+		}
+	}
+
+	sort.SliceStable(track, func(i, j int) bool { return track[i].Timestamp < track[j].Timestamp })
+
+	return track, nil
+}
+
+// LookupLastPosition reads the most recent position reported by a ship.
+func (m *memoryStore) LookupLastPosition(ctx context.Context, mmsi string) (Position, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var last Position
+	found := false
+
+	for _, p := range m.positions {
+		if p.MMSI == mmsi && (!found || p.Timestamp > last.Timestamp) {
+			last = p
+			found = true
+		}
+	}
+
+	return last, found, nil
+}
+
+// SaveAnomaly writes an anomaly to the store.
+func (m *memoryStore) SaveAnomaly(ctx context.Context, anomaly Anomaly) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.anomalies = append(m.anomalies, anomaly)
+
+	return nil
+}
+
+// SaveNoaaData writes a batch of NOAA readings, skipping any already stored.
+func (m *memoryStore) SaveNoaaData(ctx context.Context, data []NoaaDatum) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, d := range data {
+		key := NoaaDatum{Station: d.Station, Product: d.Product, Timestamp: d.Timestamp}
+		if m.readings[key] {
+			continue
+		}
+		m.readings[key] = true
+		m.noaa = append(m.noaa, d)
+	}
+
+	return nil
+}
+
+// LookupNoaaData reads the readings of a product at a station between two Unix times (inclusive), oldest first.
+func (m *memoryStore) LookupNoaaData(ctx context.Context, station, product string, from, to int64) ([]NoaaDatum, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var data []NoaaDatum
+	for _, d := range m.noaa {
+		if d.Station == station && d.Product == product && d.Timestamp >= from && d.Timestamp <= to {
+			data = append(data, d)
+		}
+	}
+
+	sort.SliceStable(data, func(i, j int) bool { return data[i].Timestamp < data[j].Timestamp })
+
+	return data, nil
+}
+
+// LookupLatestNoaaDatum reads the most recent reading of a product at a station.
+func (m *memoryStore) LookupLatestNoaaDatum(ctx context.Context, station, product string) (NoaaDatum, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var latest NoaaDatum
+	found := false
+
+	for _, d := range m.noaa {
+		if d.Station == station && d.Product == product && (!found || d.Timestamp > latest.Timestamp) {
+			latest = d
+			found = true
+		}
+	}
+
+	return latest, found, nil
+}
+
+// CountRows returns the number of rows in the given table.
+func (m *memoryStore) CountRows(ctx context.Context, table string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch table {
+	case "ships":
+		return int64(len(m.ships)), nil
+	case "ship_history":
+		return int64(len(m.history)), nil
+	case "sightings":
+		return int64(len(m.sightings)), nil
+	case "positions":
+		return int64(len(m.positions)), nil
+	case "anomalies":
+		return int64(len(m.anomalies)), nil
+	case "noaa_readings":
+		return int64(len(m.noaa)), nil
+	}
+
+	return 0, fmt.Errorf("count rows: %w: %q", ErrUnknownTable, table)
+}
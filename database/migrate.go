@@ -0,0 +1,423 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The schema changes, oldest first, one directory per SQL dialect. Each
+// version NNNN has a NNNN_name.up.sql that applies it and a
+// NNNN_name.down.sql that undoes it.
+//
+// Each migration runs in a transaction with its schema_version row. SQLite
+// rolls back DDL with the rest, so a failed migration leaves nothing
+// behind. MySQL commits each DDL statement as it runs, so a MySQL
+// migration that fails part way stays part applied and is run again from
+// the start. Each of its statements must be harmless to run again: tables
+// are created IF NOT EXISTS with their indexes, dropped IF EXISTS, and
+// changes that take several steps are done on a copy.
+//
+//go:embed migrations
+var migrations embed.FS
+
+// migration is one versioned change to the schema.
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+var (
+	// Databases created before migrations existed have no schema_version
+	// table and are at most at the version of the last entry here. Entry
+	// N is a column that exists once version N+1 has been applied, so they
+	// tell us where such a database is.
+	baselines = []struct {
+		table  string
+		column string
+	}{
+		{"ships", "mmsi"},
+		{"ships", "call_sign"},
+		{"anomalies", "mmsi"},
+		{"positions", "mmsi"},
+		{"ship_history", "id"},
+		{"noaa_readings", "station"},
+		{"sightings", "nav_status"},
+	}
+
+	// The Go structs that are read from or written to each table. Tables
+	// read with SELECT * must match their struct column for column.
+	structs = []struct {
+		table string
+		row   interface{}
+		exact bool
+	}{
+		{"ships", Ship{}, true},
+		{"ship_history", ShipChange{}, false},
+		{"sightings", Sighting{}, true},
+		{"positions", Position{}, false},
+		{"anomalies", Anomaly{}, false},
+		{"noaa_readings", NoaaDatum{}, false},
+	}
+)
+
+// loadMigrations reads the embedded migrations for the given SQL dialect.
+func loadMigrations(driver string) ([]migration, error) {
+	dir := path.Join("migrations", driver)
+
+	entries, err := migrations.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*migration{}
+
+	for _, entry := range entries {
+		// 0001_ships_and_sightings.up.sql
+		fields := strings.SplitN(entry.Name(), "_", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("bad migration file name %s", entry.Name())
+		}
+		version, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("bad migration file name %s: %v", entry.Name(), err)
+		}
+
+		contents, err := migrations.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version}
+			byVersion[version] = m
+		}
+
+		switch {
+		case strings.HasSuffix(fields[1], ".up.sql"):
+			m.name = strings.TrimSuffix(fields[1], ".up.sql")
+			m.up = string(contents)
+		case strings.HasSuffix(fields[1], ".down.sql"):
+			m.down = string(contents)
+		default:
+			return nil, fmt.Errorf("bad migration file name %s", entry.Name())
+		}
+	}
+
+	var all []migration
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d needs both an up and a down script", m.version)
+		}
+		all = append(all, *m)
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].version < all[j].version })
+
+	for i, m := range all {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+	}
+
+	return all, nil
+}
+
+// statements splits a migration script into the statements it contains.
+// Semicolons in quotes and comments do not end a statement, nor do those
+// between BEGIN and END, as in a trigger body, or CASE and END. Comments
+// with no statement after them are dropped.
+func statements(script string) []string {
+	var found []string
+
+	start, depth, empty := 0, 0, true
+	add := func(end int) {
+		if !empty {
+			found = append(found, strings.TrimSpace(script[start:end]))
+		}
+		start, empty = end+1, true
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == ';' && depth == 0:
+			add(i)
+			continue
+		case strings.HasPrefix(script[i:], "--"), strings.HasPrefix(script[i:], "/*"), c == ' ', c == '\t', c == '\n', c == '\r':
+		default:
+			empty = false
+		}
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// A doubled quote is a quote within the string.
+			for i++; i < len(script); i++ {
+				if script[i] != c {
+					continue
+				}
+				if i+1 < len(script) && script[i+1] == c {
+					i++
+					continue
+				}
+				break
+			}
+		case strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			i += end
+		case strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script) - i - 4
+			}
+			i += end + 3
+		case isWordByte(c):
+			j := i
+			for j < len(script) && isWordByte(script[j]) {
+				j++
+			}
+			switch strings.ToUpper(script[i:j]) {
+			case "BEGIN", "CASE":
+				depth++
+			case "END":
+				if depth > 0 {
+					depth--
+				}
+			}
+			i = j - 1
+		}
+	}
+	add(len(script))
+
+	return found
+}
+
+// isWordByte returns whether c can be part of an SQL keyword or name.
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// columns returns the names of the columns of a table, in order. It fails if the table does not exist.
+func (s *sqlStore) columns(ctx context.Context, table string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT * FROM "+table+" LIMIT 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return rows.Columns()
+}
+
+// hasColumn returns whether the table exists and has the column.
+func (s *sqlStore) hasColumn(ctx context.Context, table, column string) bool {
+	live, err := s.columns(ctx, table)
+	if err != nil {
+		return false
+	}
+
+	return containsFold(live, column)
+}
+
+// containsFold returns whether the column is in the list, ignoring case.
+func containsFold(columns []string, column string) bool {
+	for _, c := range columns {
+		if strings.EqualFold(c, column) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// baseline works out which version a database created by hand is at.
+func (s *sqlStore) baseline(ctx context.Context) int {
+	version := 0
+
+	for _, b := range baselines {
+		if !s.hasColumn(ctx, b.table, b.column) {
+			break
+		}
+		version++
+	}
+
+	return version
+}
+
+// schemaVersion returns the version the database is at, creating the
+// schema_version table if needed.
+func (s *sqlStore) schemaVersion(ctx context.Context) (int, error) {
+	_, err := s.columns(ctx, "schema_version")
+	if err != nil {
+		_, err = s.db.ExecContext(ctx, "CREATE TABLE schema_version ( version int not null primary key, applied int not null )")
+		if err != nil {
+			return 0, err
+		}
+
+		now := time.Now().Unix()
+		baseline := s.baseline(ctx)
+		for version := 1; version <= baseline; version++ {
+			_, err = s.db.ExecContext(ctx, "INSERT INTO schema_version ( version, applied ) VALUES ( ?, ? )", version, now)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	var version int
+
+	row := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_version")
+	err = row.Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// run executes each statement of a migration script, then the statement
+// that records it in schema_version, all in one transaction.
+func (s *sqlStore) run(ctx context.Context, m migration, script, record string, args ...interface{}) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migration %d %s: %v", m.version, m.name, err)
+	}
+	defer tx.Rollback()
+
+	for _, statement := range statements(script) {
+		_, err = tx.ExecContext(ctx, statement)
+		if err != nil {
+			return fmt.Errorf("migration %d %s: %v", m.version, m.name, err)
+		}
+	}
+	_, err = tx.ExecContext(ctx, record, args...)
+	if err != nil {
+		return fmt.Errorf("migration %d %s: %v", m.version, m.name, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("migration %d %s: %v", m.version, m.name, err)
+	}
+
+	return nil
+}
+
+// migrate brings the schema up or down to the given version. A negative
+// version means the latest.
+func (s *sqlStore) migrate(ctx context.Context, target int) error {
+	all, err := loadMigrations(s.driver)
+	if err != nil {
+		return err
+	}
+
+	if target < 0 {
+		target = len(all)
+	}
+	if target > len(all) {
+		return fmt.Errorf("no schema version %d, latest is %d", target, len(all))
+	}
+
+	current, err := s.schemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Statements may be cached against tables that are about to change.
+	s.mu.Lock()
+	for query, stmt := range s.stmts {
+		stmt.Close()
+		delete(s.stmts, query)
+	}
+	s.mu.Unlock()
+
+	for _, m := range all {
+		if m.version <= current || m.version > target {
+			continue
+		}
+		err := s.run(ctx, m, m.up, "INSERT INTO schema_version ( version, applied ) VALUES ( ?, ? )", m.version, time.Now().Unix())
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := len(all) - 1; i >= 0; i-- {
+		m := all[i]
+		if m.version > current || m.version <= target {
+			continue
+		}
+		err := s.run(ctx, m, m.down, "DELETE FROM schema_version WHERE version = ?", m.version)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dbColumns returns the column names in the db tags of a struct, in field order.
+func dbColumns(row interface{}) []string {
+	var names []string
+
+	t := reflect.TypeOf(row)
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("db")
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// checkSchema makes sure the Go structs match the live tables.
+func (s *sqlStore) checkSchema(ctx context.Context) error {
+	for _, st := range structs {
+		live, err := s.columns(ctx, st.table)
+		if err != nil {
+			return fmt.Errorf("table %s: %v", st.table, err)
+		}
+		expected := dbColumns(st.row)
+
+		if st.exact {
+			same := len(live) == len(expected)
+			for i := 0; same && i < len(live); i++ {
+				same = strings.EqualFold(live[i], expected[i])
+			}
+			if !same {
+				return fmt.Errorf("table %s has columns %v, %T expects %v", st.table, live, st.row, expected)
+			}
+			continue
+		}
+
+		for _, column := range expected {
+			if !containsFold(live, column) {
+				return fmt.Errorf("table %s has no column %s, which %T expects", st.table, column, st.row)
+			}
+		}
+	}
+
+	return nil
+}
+
+// MigrateTo brings the schema of the open SQL database up or down to the
+// given version. Opening a database already brings it up to the latest.
+func MigrateTo(ctx context.Context, version int) error {
+	s, ok := store.(*sqlStore)
+	if !ok {
+		return fmt.Errorf("store %T has no schema", store)
+	}
+
+	return s.migrate(ctx, version)
+}
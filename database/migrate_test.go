@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	mysql, err := loadMigrations("mysql")
+	if err != nil {
+		t.Fatalf("ERROR: Unable to load MySQL migrations: %v", err)
+	}
+	sqlite, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatalf("ERROR: Unable to load SQLite migrations: %v", err)
+	}
+
+	if len(mysql) != len(sqlite) || len(mysql) < len(baselines) {
+		t.Errorf("ERROR: Expected the same migrations for each dialect, got %d MySQL and %d SQLite", len(mysql), len(sqlite))
+	}
+
+	for i := range mysql {
+		if i < len(sqlite) && mysql[i].name != sqlite[i].name {
+			t.Errorf("ERROR: Migration %d is %s for MySQL but %s for SQLite", i+1, mysql[i].name, sqlite[i].name)
+		}
+	}
+}
+
+func TestStatements(t *testing.T) {
+	testCases := []struct {
+		script   string
+		expected int
+	}{
+		{"", 0},
+		{"DROP TABLE ships;", 1},
+		{"CREATE TABLE a ( b int );\n\nCREATE INDEX c ON a ( b );\n", 2},
+		{"ALTER TABLE ships DROP COLUMN eni", 1},
+		{"INSERT INTO a VALUES ( 'x;y' );\nINSERT INTO a VALUES ( 'it''s; here' );", 2},
+		{"-- Drop it; it is not used.\nDROP TABLE a; /* done; */", 1},
+		{"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  UPDATE b SET c = CASE WHEN c > 0 THEN 1 END;\n  DELETE FROM d;\nEND;\nDROP TABLE e;", 2},
+	}
+
+	for _, testCase := range testCases {
+		answer := statements(testCase.script)
+		if len(answer) != testCase.expected {
+			t.Errorf("ERROR: For %q expected %d statements, got %v", testCase.script, testCase.expected, answer)
+		}
+	}
+}
+
+func TestMigrateRollsBack(t *testing.T) {
+	s, err := openSQLite(filepath.Join(t.TempDir(), "shipahoy.db"))
+	if err != nil {
+		t.Fatalf("ERROR: Unable to open SQLite: %v", err)
+	}
+	defer s.Close()
+
+	// The second statement fails, so the first is undone and the version not recorded.
+	m := migration{version: 99, name: "broken"}
+	err = s.run(context.Background(), m, "CREATE TABLE broken ( a int );\nINSERT INTO nowhere VALUES ( 1 );", "INSERT INTO schema_version ( version, applied ) VALUES ( ?, ? )", m.version, 0)
+	if err == nil {
+		t.Fatalf("ERROR: Expected the broken migration to fail")
+	}
+	if s.hasColumn(context.Background(), "broken", "a") {
+		t.Errorf("ERROR: Expected the table created by the broken migration to be rolled back")
+	}
+	current, err := s.schemaVersion(context.Background())
+	all, _ := loadMigrations("sqlite")
+	if err != nil || current != len(all) {
+		t.Errorf("ERROR: Expected schema version %d, got %d %v", len(all), current, err)
+	}
+}
+
+func TestMigrateDuplicates(t *testing.T) {
+	s, err := openSQLite(filepath.Join(t.TempDir(), "shipahoy.db"))
+	if err != nil {
+		t.Fatalf("ERROR: Unable to open SQLite: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	// Before readings were unique, the same one could be stored twice.
+	err = s.migrate(ctx, 7)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to migrate down to 7: %v", err)
+	}
+	for _, timestamp := range []int{100, 100, 100, 200} {
+		_, err = s.db.ExecContext(ctx, "INSERT INTO noaa_readings ( station, product, datum, value, s, flags, timestamp ) VALUES ( '9414290', 'water_level', 'MLLW', '4.1', '0.01', '0,0,0,0', ? )", timestamp)
+		if err != nil {
+			t.Fatalf("ERROR: Unable to save reading: %v", err)
+		}
+	}
+
+	err = s.upgrade(ctx)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to migrate up with duplicate readings: %v", err)
+	}
+	count, err := s.CountRows(ctx, "noaa_readings")
+	if err != nil || count != 2 {
+		t.Errorf("ERROR: Expected the duplicates dropped leaving 2 readings, got %d %v", count, err)
+	}
+}
+
+func TestMySQLMigrationsRerun(t *testing.T) {
+	all, err := loadMigrations("mysql")
+	if err != nil {
+		t.Fatalf("ERROR: Unable to load MySQL migrations: %v", err)
+	}
+
+	// MySQL cannot roll a migration back, so each statement must be safe to run again.
+	for _, m := range all {
+		for _, script := range []string{m.up, m.down} {
+			for _, statement := range statements(script) {
+				upper := strings.ToUpper(statement)
+				switch {
+				case strings.Contains(upper, "CREATE TABLE") && !strings.Contains(upper, "IF NOT EXISTS"),
+					strings.Contains(upper, "DROP TABLE") && !strings.Contains(upper, "IF EXISTS"),
+					strings.HasPrefix(upper, "CREATE INDEX"), strings.HasPrefix(upper, "CREATE UNIQUE INDEX"), strings.HasPrefix(upper, "DROP INDEX"):
+					t.Errorf("ERROR: Migration %d %s cannot be run again: %s", m.version, m.name, statement)
+				}
+			}
+		}
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	s, err := openSQLite(filepath.Join(t.TempDir(), "shipahoy.db"))
+	if err != nil {
+		t.Fatalf("ERROR: Unable to open SQLite: %v", err)
+	}
+	defer s.Close()
+
+	s.SaveShip(context.Background(), Ship{MMSI: "366990520", Name: "DEL NORTE"}, "test")
+
+	all, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatalf("ERROR: Unable to load migrations: %v", err)
+	}
+
+	for version := len(all) - 1; version >= 0; version-- {
+		err = s.migrate(context.Background(), version)
+		if err != nil {
+			t.Fatalf("ERROR: Unable to migrate down to %d: %v", version, err)
+		}
+		current, err := s.schemaVersion(context.Background())
+		if err != nil || current != version {
+			t.Errorf("ERROR: Expected schema version %d, got %d %v", version, current, err)
+		}
+	}
+
+	if s.hasColumn(context.Background(), "ships", "mmsi") {
+		t.Errorf("ERROR: Expected no ships table at version 0")
+	}
+
+	err = s.upgrade(context.Background())
+	if err != nil {
+		t.Fatalf("ERROR: Unable to migrate back up: %v", err)
+	}
+
+	s.SaveShip(context.Background(), Ship{MMSI: "366990520", Name: "DEL NORTE"}, "test")
+	if _, ok, _ := s.LookupShip(context.Background(), "366990520"); !ok {
+		t.Errorf("ERROR: Expected to find a ship saved after migrating back up")
+	}
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shipahoy.db")
+
+	// A database created by hand before migrations existed, with the
+	// ships and sightings tables and the later identity columns.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to open SQLite: %v", err)
+	}
+	all, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatalf("ERROR: Unable to load migrations: %v", err)
+	}
+	for _, m := range all[:2] {
+		for _, statement := range statements(m.up) {
+			_, err = db.Exec(statement)
+			if err != nil {
+				t.Fatalf("ERROR: Unable to create old schema: %v", err)
+			}
+		}
+	}
+	_, err = db.Exec("INSERT INTO sightings ( mmsi, ship_course, timestamp, lat, lon, my_lat, my_lon ) VALUES ( '366990520', 270, 100, 37.8, -122.4, 37.8, -122.4 )")
+	if err != nil {
+		t.Fatalf("ERROR: Unable to save old sighting: %v", err)
+	}
+	db.Close()
+
+	s, err := openSQLite(path)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to upgrade old database: %v", err)
+	}
+	defer s.Close()
+
+	current, err := s.schemaVersion(context.Background())
+	if err != nil || current != len(all) {
+		t.Errorf("ERROR: Expected schema version %d, got %d %v", len(all), current, err)
+	}
+
+	sighting, ok, _ := s.LookupSighting(context.Background(), Ship{MMSI: "366990520"})
+	if !ok || sighting.Timestamp != 100 || sighting.NavigationalStatus != 0 {
+		t.Errorf("ERROR: Expected the old sighting, got %v %v", ok, sighting)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	s, err := openSQLite(filepath.Join(t.TempDir(), "shipahoy.db"))
+	if err != nil {
+		t.Fatalf("ERROR: Unable to open SQLite: %v", err)
+	}
+	defer s.Close()
+
+	err = s.checkSchema(context.Background())
+	if err != nil {
+		t.Errorf("ERROR: Expected the new schema to match, got %v", err)
+	}
+
+	// A stray column breaks SELECT * into Ship.
+	_, err = s.db.Exec("ALTER TABLE ships ADD COLUMN unknown int")
+	if err != nil {
+		t.Fatalf("ERROR: Unable to add column: %v", err)
+	}
+
+	err = s.checkSchema(context.Background())
+	if err == nil {
+		t.Errorf("ERROR: Expected a stray ships column to be reported")
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS sightings;
+DROP TABLE IF EXISTS ships;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS ships (
+    mmsi varchar(20) not null,
+    imo varchar(20) not null,
+    name varchar(40) not null,
+    ais int not null,
+    type varchar(128) not null,
+    sar boolean not null,
+    direct_link varchar(128) not null,
+    draught float not null,
+    year int not null,
+    gt int not null,
+    length int not null,
+    beam int not null,
+    dw int not null,
+    flag varchar(20) not null,
+    invalidDimensions boolean not null,
+    marineTrafficID int not null,
+    UNIQUE KEY mmsi ( mmsi )
+);
+
+CREATE TABLE IF NOT EXISTS sightings (
+    mmsi varchar(20),
+    ship_course float,
+    timestamp int,  -- Unix datetime
+    lat float,
+    lon float,
+    my_lat float,
+    my_lon float
+);
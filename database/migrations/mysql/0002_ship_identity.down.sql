@@ -0,0 +1,4 @@
+ALTER TABLE ships
+    DROP COLUMN data_quality,
+    DROP COLUMN eni,
+    DROP COLUMN call_sign;
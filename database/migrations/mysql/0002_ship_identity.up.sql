@@ -0,0 +1,4 @@
+ALTER TABLE ships
+    ADD COLUMN call_sign varchar(20) not null default '',
+    ADD COLUMN eni varchar(8) not null default '',
+    ADD COLUMN data_quality int not null default 0;
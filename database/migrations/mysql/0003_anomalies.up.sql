@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS anomalies (
+    mmsi varchar(20) not null,
+    timestamp int not null,  -- Unix datetime
+    kind varchar(40) not null,
+    detail varchar(255) not null,
+    lat float not null,
+    lon float not null,
+    INDEX anomalies_mmsi ( mmsi, timestamp )
+);
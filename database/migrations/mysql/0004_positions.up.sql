@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS positions (
+    mmsi varchar(20) not null,
+    timestamp int not null,  -- Unix datetime the ship reported this position
+    lat float not null,
+    lon float not null,
+    sog float not null,
+    cog float not null,
+    heading float not null,
+    nav_status int not null,
+    source varchar(20) not null,
+    UNIQUE KEY positions_report ( mmsi, timestamp, source ),
+    INDEX positions_timestamp ( timestamp )
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS ship_history (
+    id int not null auto_increment primary key,
+    mmsi varchar(20) not null,
+    timestamp int not null,  -- Unix datetime
+    field varchar(40) not null,
+    old_value varchar(128) not null,
+    new_value varchar(128) not null,
+    source varchar(20) not null,
+    INDEX ship_history_mmsi ( mmsi, timestamp )
+);
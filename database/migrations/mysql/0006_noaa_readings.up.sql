@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS noaa_readings (
+    station varchar(20) not null,
+    product varchar(40) not null,
+    datum varchar(20) not null,
+    value varchar(20) not null,
+    s varchar(20) not null,
+    flags varchar(20) not null,
+    timestamp int not null,  -- Unix datetime of the reading
+    INDEX noaa_readings_station ( station, product, timestamp )
+);
@@ -0,0 +1 @@
+ALTER TABLE sightings ADD COLUMN nav_status int not null default 0;
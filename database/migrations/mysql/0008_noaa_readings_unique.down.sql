@@ -0,0 +1,3 @@
+ALTER TABLE noaa_readings
+    DROP INDEX noaa_readings_reading,
+    ADD INDEX noaa_readings_station ( station, product, timestamp );
@@ -0,0 +1,22 @@
+-- One reading per station, product and time. Copy the table to drop any
+-- duplicates already stored. MySQL commits each statement as it goes, so
+-- each is safe to run again if a later one fails: a copy left over from
+-- an earlier attempt is reused or dropped, and the swap is one statement.
+DROP TABLE IF EXISTS noaa_readings_old;
+
+CREATE TABLE IF NOT EXISTS noaa_readings_unique (
+    station varchar(20) not null,
+    product varchar(40) not null,
+    datum varchar(20) not null,
+    value varchar(20) not null,
+    s varchar(20) not null,
+    flags varchar(20) not null,
+    timestamp int not null,  -- Unix datetime of the reading
+    UNIQUE KEY noaa_readings_reading ( station, product, timestamp )
+);
+
+INSERT IGNORE INTO noaa_readings_unique SELECT * FROM noaa_readings;
+
+RENAME TABLE noaa_readings TO noaa_readings_old, noaa_readings_unique TO noaa_readings;
+
+DROP TABLE IF EXISTS noaa_readings_old;
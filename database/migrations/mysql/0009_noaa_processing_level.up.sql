@@ -0,0 +1 @@
+ALTER TABLE noaa_readings ADD COLUMN processing_level varchar(1) not null default '';
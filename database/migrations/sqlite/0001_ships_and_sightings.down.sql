@@ -0,0 +1,2 @@
+DROP TABLE sightings;
+DROP TABLE ships;
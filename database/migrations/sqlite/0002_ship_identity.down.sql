@@ -0,0 +1,3 @@
+ALTER TABLE ships DROP COLUMN data_quality;
+ALTER TABLE ships DROP COLUMN eni;
+ALTER TABLE ships DROP COLUMN call_sign;
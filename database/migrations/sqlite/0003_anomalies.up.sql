@@ -0,0 +1,10 @@
+CREATE TABLE anomalies (
+    mmsi varchar(20) not null,
+    timestamp int not null,  -- Unix datetime
+    kind varchar(40) not null,
+    detail varchar(255) not null,
+    lat float not null,
+    lon float not null
+);
+
+CREATE INDEX anomalies_mmsi ON anomalies ( mmsi, timestamp );
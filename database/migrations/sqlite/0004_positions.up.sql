@@ -0,0 +1,14 @@
+CREATE TABLE positions (
+    mmsi varchar(20) not null,
+    timestamp int not null,  -- Unix datetime the ship reported this position
+    lat float not null,
+    lon float not null,
+    sog float not null,
+    cog float not null,
+    heading float not null,
+    nav_status int not null,
+    source varchar(20) not null
+);
+
+CREATE UNIQUE INDEX positions_report ON positions ( mmsi, timestamp, source );
+CREATE INDEX positions_timestamp ON positions ( timestamp );
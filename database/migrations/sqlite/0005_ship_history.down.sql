@@ -0,0 +1 @@
+DROP TABLE ship_history;
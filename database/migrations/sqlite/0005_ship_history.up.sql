@@ -0,0 +1,11 @@
+CREATE TABLE ship_history (
+    id integer primary key autoincrement,
+    mmsi varchar(20) not null,
+    timestamp int not null,  -- Unix datetime
+    field varchar(40) not null,
+    old_value varchar(128) not null,
+    new_value varchar(128) not null,
+    source varchar(20) not null
+);
+
+CREATE INDEX ship_history_mmsi ON ship_history ( mmsi, timestamp );
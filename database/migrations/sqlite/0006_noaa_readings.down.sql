@@ -0,0 +1 @@
+DROP TABLE noaa_readings;
@@ -0,0 +1,11 @@
+CREATE TABLE noaa_readings (
+    station varchar(20) not null,
+    product varchar(40) not null,
+    datum varchar(20) not null,
+    value varchar(20) not null,
+    s varchar(20) not null,
+    flags varchar(20) not null,
+    timestamp int not null  -- Unix datetime of the reading
+);
+
+CREATE INDEX noaa_readings_station ON noaa_readings ( station, product, timestamp );
@@ -0,0 +1 @@
+ALTER TABLE sightings DROP COLUMN nav_status;
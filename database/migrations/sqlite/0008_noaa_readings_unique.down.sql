@@ -0,0 +1,3 @@
+DROP INDEX noaa_readings_reading;
+
+CREATE INDEX noaa_readings_station ON noaa_readings ( station, product, timestamp );
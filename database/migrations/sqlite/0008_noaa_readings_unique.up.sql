@@ -0,0 +1,19 @@
+-- One reading per station, product and time. Copy the table to drop any
+-- duplicates already stored.
+CREATE TABLE noaa_readings_unique (
+    station varchar(20) not null,
+    product varchar(40) not null,
+    datum varchar(20) not null,
+    value varchar(20) not null,
+    s varchar(20) not null,
+    flags varchar(20) not null,
+    timestamp int not null  -- Unix datetime of the reading
+);
+
+CREATE UNIQUE INDEX noaa_readings_reading ON noaa_readings_unique ( station, product, timestamp );
+
+INSERT OR IGNORE INTO noaa_readings_unique SELECT * FROM noaa_readings;
+
+DROP TABLE noaa_readings;
+
+ALTER TABLE noaa_readings_unique RENAME TO noaa_readings;
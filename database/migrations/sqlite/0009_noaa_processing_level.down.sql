@@ -0,0 +1 @@
+ALTER TABLE noaa_readings DROP COLUMN processing_level;
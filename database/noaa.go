@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// NoaaDay holds the lowest and highest readings of a product at a station on one day.
+type NoaaDay struct {
+	Day   time.Time // midnight at the start of the day
+	Min   NoaaDatum
+	Max   NoaaDatum
+	Count int // readings with a value
+}
+
+// LookupNoaaDays returns the lowest and highest reading on each day
+// between two Unix times (inclusive), oldest first. Days run midnight to
+// midnight in loc. Readings without a numeric value are skipped.
+func LookupNoaaDays(ctx context.Context, station, product string, from, to int64, loc *time.Location) ([]NoaaDay, error) {
+	data, err := LookupNoaaData(ctx, station, product, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return noaaDays(data, loc), nil
+}
+
+// noaaDays groups readings, oldest first, by day.
+func noaaDays(data []NoaaDatum, loc *time.Location) []NoaaDay {
+	var days []NoaaDay
+	var minValue, maxValue float64
+
+	for _, d := range data {
+		value, err := strconv.ParseFloat(d.Value, 64)
+		if err != nil {
+			continue
+		}
+
+		t := time.Unix(d.Timestamp, 0).In(loc)
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+
+		if len(days) == 0 || !days[len(days)-1].Day.Equal(day) {
+			days = append(days, NoaaDay{Day: day, Min: d, Max: d})
+			minValue, maxValue = value, value
+		}
+
+		today := &days[len(days)-1]
+		today.Count++
+		if value < minValue {
+			today.Min = d
+			minValue = value
+		}
+		if value > maxValue {
+			today.Max = d
+			maxValue = value
+		}
+	}
+
+	return days
+}
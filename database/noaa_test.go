@@ -0,0 +1,50 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNoaaDays(t *testing.T) {
+	pacific := time.FixedZone("PST", -8*60*60)
+	at := func(day, hour int) int64 {
+		return time.Date(2024, time.January, day, hour, 0, 0, 0, pacific).Unix()
+	}
+
+	data := []NoaaDatum{
+		{Value: "1.0", Timestamp: at(1, 0)},
+		{Value: "5.5", Timestamp: at(1, 6)},
+		{Value: "-0.3", Timestamp: at(1, 12)},
+		{Value: "", Timestamp: at(1, 18)}, // missing value
+		{Value: "2.0", Timestamp: at(2, 1)},
+		{Value: "4.0", Timestamp: at(2, 23)},
+	}
+
+	days := noaaDays(data, pacific)
+
+	if len(days) != 2 {
+		t.Fatalf("ERROR: Expected 2 days, got %v", days)
+	}
+
+	testCases := []struct {
+		day   NoaaDay
+		date  int
+		min   string
+		max   string
+		count int
+	}{
+		{days[0], 1, "-0.3", "5.5", 3},
+		{days[1], 2, "2.0", "4.0", 2},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.day.Day.Day() != testCase.date || testCase.day.Min.Value != testCase.min || testCase.day.Max.Value != testCase.max || testCase.day.Count != testCase.count {
+			t.Errorf("ERROR: Expected day %d min %s max %s count %d, got %v", testCase.date, testCase.min, testCase.max, testCase.count, testCase.day)
+		}
+	}
+
+	// The same readings are split differently in UTC.
+	if len(noaaDays(data, time.UTC)) != 3 {
+		t.Errorf("ERROR: Expected 3 UTC days, got %v", noaaDays(data, time.UTC))
+	}
+}
@@ -0,0 +1,678 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sqlStore keeps everything in an SQL database. The MySQL and SQLite
+// dialects differ only in how they spell "insert unless it exists" and
+// "insert or update".
+type sqlStore struct {
+	db      *sql.DB
+	driver  string
+	timeout time.Duration
+
+	// Prepared statements, keyed by their SQL.
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// The most rows to send in a single INSERT.
+var batchSize = 500
+
+// openSQL opens a database with the given driver, tunes its connection
+// pool and brings its schema up to date.
+func openSQL(ctx context.Context, cfg Config) (*sqlStore, error) {
+	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Driver == "sqlite" {
+		// SQLite allows only one writer at a time. Rather than handle
+		// SQLITE_BUSY everywhere, funnel everything through one connection.
+		db.SetMaxOpenConns(1)
+	} else {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	s := &sqlStore{db: db, driver: cfg.Driver, timeout: cfg.Timeout, stmts: map[string]*sql.Stmt{}}
+
+	err = s.upgrade(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// openSQLite opens (creating if needed) an SQLite database file.
+func openSQLite(path string) (*sqlStore, error) {
+	cfg := DefaultConfig()
+	cfg.Driver = "sqlite"
+	cfg.DSN = path
+
+	return openSQL(context.Background(), cfg)
+}
+
+// upgrade brings the schema up to the latest version and checks that the
+// Go structs match it.
+func (s *sqlStore) upgrade(ctx context.Context) error {
+	err := s.migrate(ctx, -1)
+	if err != nil {
+		return err
+	}
+
+	return s.checkSchema(ctx)
+}
+
+// Close closes the prepared statements and the connection to the database.
+func (s *sqlStore) Close() error {
+	s.mu.Lock()
+	for _, stmt := range s.stmts {
+		stmt.Close()
+	}
+	s.stmts = map[string]*sql.Stmt{}
+	s.mu.Unlock()
+
+	return s.db.Close()
+}
+
+// withTimeout bounds a call by the store's timeout, unless the caller
+// already set a deadline.
+func (s *sqlStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	_, ok := ctx.Deadline()
+	if ok || s.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+// prepare returns a prepared statement for the query, preparing it the
+// first time it is asked for. Every value in a query must be passed as a
+// ? placeholder; never build SQL out of values.
+func (s *sqlStore) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stmt, ok := s.stmts[query]
+	if ok {
+		return stmt, nil
+	}
+
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	s.stmts[query] = stmt
+
+	return stmt, nil
+}
+
+// insertIgnore returns the dialect's "insert unless the key already exists".
+func (s *sqlStore) insertIgnore() string {
+	if s.driver == "sqlite" {
+		return "INSERT OR IGNORE"
+	}
+	return "INSERT IGNORE"
+}
+
+// upsert returns the dialect's clause to update the given columns when an
+// insert collides with an existing key.
+func (s *sqlStore) upsert(key string, columns ...string) string {
+	var sets []string
+
+	if s.driver == "sqlite" {
+		for _, column := range columns {
+			sets = append(sets, column+" = excluded."+column)
+		}
+		return " ON CONFLICT( " + key + " ) DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+
+	for _, column := range columns {
+		sets = append(sets, column+" = VALUES("+column+")")
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+// SaveShip writes ship details to the database, updating any record we
+// already have for the ship. Each changed field is logged in ship_history
+// against the given source.
+func (s *sqlStore) SaveShip(ctx context.Context, details Ship, source string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	stored, exists, err := s.LookupShip(ctx, details.MMSI)
+	if err != nil {
+		return err
+	}
+
+	var changes []ShipChange
+	if exists {
+		changes = diffShips(stored, &details)
+	}
+
+	// Prepare before beginning the transaction; an SQLite store has only
+	// one connection and the transaction holds it.
+	historyStmt, err := s.prepare(ctx, "INSERT INTO ship_history ( mmsi, timestamp, field, old_value, new_value, source ) VALUES ( ?, ?, ?, ?, ?, ? )")
+	if err != nil {
+		return fmt.Errorf("save ship %s: %w", details.MMSI, err)
+	}
+
+	sqlString := "INSERT INTO ships ( mmsi, imo, name, ais, Type, sar, direct_link, draught, year, gt, length, beam, dw, flag, invalidDimensions, marineTrafficID, call_sign, eni, data_quality ) VALUES ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? )" +
+		s.upsert("mmsi", "imo", "name", "ais", "Type", "sar", "direct_link", "draught", "year", "gt", "length", "beam", "dw", "flag", "invalidDimensions", "marineTrafficID", "call_sign", "eni", "data_quality")
+
+	shipStmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return fmt.Errorf("save ship %s: %w", details.MMSI, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("save ship %s: %w", details.MMSI, err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	for _, change := range changes {
+		_, err := tx.StmtContext(ctx, historyStmt).ExecContext(ctx, details.MMSI, now, change.Field, change.OldValue, change.NewValue, source)
+		if err != nil {
+			return fmt.Errorf("save ship %s history: %w", details.MMSI, err)
+		}
+	}
+
+	_, err = tx.StmtContext(ctx, shipStmt).ExecContext(ctx, details.MMSI, details.IMO, details.Name, details.AIS, details.Type, details.SAR, details.DirectLink, details.Draught, details.Year, details.GT, details.Length, details.Beam, details.DW, details.Flag, details.InvalidDimensions, details.MarineTrafficID, details.CallSign, details.ENI, details.DataQuality)
+	if err != nil {
+		return fmt.Errorf("save ship %s: %w", details.MMSI, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("save ship %s: %w", details.MMSI, err)
+	}
+
+	return nil
+}
+
+// LookupShipHistory reads every recorded change to a ship's stored details, oldest first.
+func (s *sqlStore) LookupShipHistory(ctx context.Context, mmsi string) ([]ShipChange, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var history []ShipChange
+
+	sqlString := "SELECT mmsi, timestamp, field, old_value, new_value, source FROM ship_history WHERE mmsi = ? ORDER BY timestamp, id"
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return nil, fmt.Errorf("lookup ship history %s: %w", mmsi, err)
+	}
+
+	rows, err := stmt.QueryContext(ctx, mmsi)
+	if err != nil {
+		return nil, fmt.Errorf("lookup ship history %s: %w", mmsi, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c ShipChange
+		err := rows.Scan(&c.MMSI, &c.Timestamp, &c.Field, &c.OldValue, &c.NewValue, &c.Source)
+		if err != nil {
+			return nil, fmt.Errorf("lookup ship history %s: %w", mmsi, err)
+		}
+		history = append(history, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("lookup ship history %s: %w", mmsi, err)
+	}
+
+	return history, nil
+}
+
+// LookupShip reads ship details from the database.
+func (s *sqlStore) LookupShip(ctx context.Context, mmsi string) (Ship, bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var details Ship
+
+	sqlString := "SELECT * FROM ships WHERE mmsi = ? LIMIT 1"
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return details, false, fmt.Errorf("lookup ship %s: %w", mmsi, err)
+	}
+
+	rows := stmt.QueryRowContext(ctx, mmsi)
+	err = rows.Scan(&details.MMSI, &details.IMO, &details.Name, &details.AIS, &details.Type, &details.SAR, &details.DirectLink, &details.Draught, &details.Year, &details.GT, &details.Length, &details.Beam, &details.DW, &details.Flag, &details.InvalidDimensions, &details.MarineTrafficID, &details.CallSign, &details.ENI, &details.DataQuality)
+	if err == sql.ErrNoRows {
+		return details, false, nil
+	}
+	if err != nil {
+		return details, false, fmt.Errorf("lookup ship %s: %w", mmsi, err)
+	}
+
+	details.Sightings, err = s.CountSightings(ctx, details.MMSI)
+	if err != nil {
+		return details, false, err
+	}
+
+	return details, true, nil
+}
+
+// LookupShipExists is [hopefully] faster than loading the entire record like dbLookupShip() does.
+func (s *sqlStore) LookupShipExists(ctx context.Context, mmsi string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var exists int
+
+	sqlString := "SELECT EXISTS( SELECT mmsi FROM ships WHERE mmsi = ? LIMIT 1 )"
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return false, fmt.Errorf("lookup ship exists %s: %w", mmsi, err)
+	}
+
+	rows := stmt.QueryRowContext(ctx, mmsi)
+	err = rows.Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("lookup ship exists %s: %w", mmsi, err)
+	}
+
+	return exists == 1, nil
+}
+
+// SaveSighting writes the ship sighting details to the database.
+func (s *sqlStore) SaveSighting(ctx context.Context, details Ship, myLat, myLon float64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	sqlString := s.insertIgnore() + " INTO sightings ( mmsi, ship_course, timestamp, lat, lon, my_lat, my_lon, nav_status ) VALUES ( ?, ?, ?, ?, ?, ?, ?, ? )"
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return fmt.Errorf("save sighting %s: %w", details.MMSI, err)
+	}
+
+	_, err = stmt.ExecContext(ctx, details.MMSI, details.Course, time.Now().Unix(), details.Lat, details.Lon, myLat, myLon, details.NavigationalStatus)
+	if err != nil {
+		return fmt.Errorf("save sighting %s: %w", details.MMSI, err)
+	}
+
+	return nil
+}
+
+// LookupSighting reads sighting details from the database.
+func (s *sqlStore) LookupSighting(ctx context.Context, details Ship) (Sighting, bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var sighting Sighting
+
+	sqlString := "SELECT * FROM sightings WHERE mmsi = ? ORDER BY timestamp DESC LIMIT 1"
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return sighting, false, fmt.Errorf("lookup sighting %s: %w", details.MMSI, err)
+	}
+
+	rows := stmt.QueryRowContext(ctx, details.MMSI)
+	err = rows.Scan(&sighting.MMSI, &sighting.ShipCourse, &sighting.Timestamp, &sighting.Lat, &sighting.Lon, &sighting.MyLat, &sighting.MyLon, &sighting.NavigationalStatus)
+	if err == sql.ErrNoRows {
+		return sighting, false, nil
+	}
+	if err != nil {
+		return sighting, false, fmt.Errorf("lookup sighting %s: %w", details.MMSI, err)
+	}
+
+	return sighting, true, nil
+}
+
+// LookupSightings reads the sightings of every ship between two Unix times (inclusive), oldest first.
+func (s *sqlStore) LookupSightings(ctx context.Context, from, to int64) ([]Sighting, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var sightings []Sighting
+
+	sqlString := "SELECT * FROM sightings WHERE timestamp BETWEEN ? AND ? ORDER BY timestamp"
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return nil, fmt.Errorf("lookup sightings: %w", err)
+	}
+
+	rows, err := stmt.QueryContext(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("lookup sightings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sighting Sighting
+		err := rows.Scan(&sighting.MMSI, &sighting.ShipCourse, &sighting.Timestamp, &sighting.Lat, &sighting.Lon, &sighting.MyLat, &sighting.MyLon, &sighting.NavigationalStatus)
+		if err != nil {
+			return nil, fmt.Errorf("lookup sightings: %w", err)
+		}
+		sightings = append(sightings, sighting)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("lookup sightings: %w", err)
+	}
+
+	return sightings, nil
+}
+
+// LookupLastSighting is [hopefully] faster than dbLookupSighting() because it only queries the timestamp.
+func (s *sqlStore) LookupLastSighting(ctx context.Context, details Ship) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var timestamp int64
+
+	sqlString := "SELECT timestamp FROM sightings WHERE mmsi = ? ORDER BY timestamp DESC LIMIT 1"
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return 0, fmt.Errorf("lookup last sighting %s: %w", details.MMSI, err)
+	}
+
+	rows := stmt.QueryRowContext(ctx, details.MMSI)
+	err = rows.Scan(&timestamp)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("lookup last sighting %s: %w", details.MMSI, err)
+	}
+
+	return timestamp, nil
+}
+
+// SavePositions writes a batch of position reports to the database.
+// Reports already stored (same ship, time and source) are skipped.
+func (s *sqlStore) SavePositions(ctx context.Context, positions []Position) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	for start := 0; start < len(positions); start += batchSize {
+		end := start + batchSize
+		if end > len(positions) {
+			end = len(positions)
+		}
+		batch := positions[start:end]
+
+		sqlString := s.insertIgnore() + " INTO positions ( mmsi, timestamp, lat, lon, sog, cog, heading, nav_status, source ) VALUES "
+		args := make([]interface{}, 0, len(batch)*9)
+		for i, p := range batch {
+			if i > 0 {
+				sqlString += ", "
+			}
+			sqlString += "( ?, ?, ?, ?, ?, ?, ?, ?, ? )"
+			args = append(args, p.MMSI, p.Timestamp, p.Lat, p.Lon, p.Speed, p.Course, p.Heading, p.NavigationalStatus, p.Source)
+		}
+
+		_, err := s.db.ExecContext(ctx, sqlString, args...)
+		if err != nil {
+			return fmt.Errorf("save positions: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// LookupTrack reads the positions reported by a ship between two Unix times (inclusive), oldest first.
+func (s *sqlStore) LookupTrack(ctx context.Context, mmsi string, from, to int64) ([]Position, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var track []Position
+
+	sqlString := "SELECT mmsi, timestamp, lat, lon, sog, cog, heading, nav_status, source FROM positions WHERE mmsi = ? AND timestamp BETWEEN ? AND ? ORDER BY timestamp"
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return nil, fmt.Errorf("lookup track %s: %w", mmsi, err)
+	}
+
+	rows, err := stmt.QueryContext(ctx, mmsi, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("lookup track %s: %w", mmsi, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p Position
+		err := rows.Scan(&p.MMSI, &p.Timestamp, &p.Lat, &p.Lon, &p.Speed, &p.Course, &p.Heading, &p.NavigationalStatus, &p.Source)
+		if err != nil {
+			return nil, fmt.Errorf("lookup track %s: %w", mmsi, err)
+		}
+		track = append(track, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("lookup track %s: %w", mmsi, err)
+	}
+
+	return track, nil
+}
+
+// LookupLastPosition reads the most recent position reported by a ship.
+func (s *sqlStore) LookupLastPosition(ctx context.Context, mmsi string) (Position, bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var p Position
+
+	sqlString := "SELECT mmsi, timestamp, lat, lon, sog, cog, heading, nav_status, source FROM positions WHERE mmsi = ? ORDER BY timestamp DESC LIMIT 1"
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return p, false, fmt.Errorf("lookup last position %s: %w", mmsi, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, mmsi)
+	err = row.Scan(&p.MMSI, &p.Timestamp, &p.Lat, &p.Lon, &p.Speed, &p.Course, &p.Heading, &p.NavigationalStatus, &p.Source)
+	if err == sql.ErrNoRows {
+		return p, false, nil
+	}
+	if err != nil {
+		return p, false, fmt.Errorf("lookup last position %s: %w", mmsi, err)
+	}
+
+	return p, true, nil
+}
+
+// SaveAnomaly writes an anomaly to the database.
+func (s *sqlStore) SaveAnomaly(ctx context.Context, anomaly Anomaly) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	sqlString := "INSERT INTO anomalies ( mmsi, timestamp, kind, detail, lat, lon ) VALUES ( ?, ?, ?, ?, ?, ? )"
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return fmt.Errorf("save anomaly %s: %w", anomaly.MMSI, err)
+	}
+
+	_, err = stmt.ExecContext(ctx, anomaly.MMSI, anomaly.Timestamp, anomaly.Kind, anomaly.Detail, anomaly.Lat, anomaly.Lon)
+	if err != nil {
+		return fmt.Errorf("save anomaly %s: %w", anomaly.MMSI, err)
+	}
+
+	return nil
+}
+
+// SaveNoaaData writes a batch of NOAA readings to the database.
+// Readings already stored (same station, product and time) are skipped.
+func (s *sqlStore) SaveNoaaData(ctx context.Context, data []NoaaDatum) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	for start := 0; start < len(data); start += batchSize {
+		end := start + batchSize
+		if end > len(data) {
+			end = len(data)
+		}
+		batch := data[start:end]
+
+		sqlString := s.insertIgnore() + " INTO noaa_readings ( station, product, datum, value, s, flags, processing_level, timestamp ) VALUES "
+		args := make([]interface{}, 0, len(batch)*8)
+		for i, d := range batch {
+			if i > 0 {
+				sqlString += ", "
+			}
+			sqlString += "( ?, ?, ?, ?, ?, ?, ?, ? )"
+			args = append(args, d.Station, d.Product, d.Datum, d.Value, d.S, d.Flags, d.ProcessingLevel, d.Timestamp)
+		}
+
+		_, err := s.db.ExecContext(ctx, sqlString, args...)
+		if err != nil {
+			return fmt.Errorf("save noaa data: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// LookupNoaaData reads the readings of a product at a station between two Unix times (inclusive), oldest first.
+func (s *sqlStore) LookupNoaaData(ctx context.Context, station, product string, from, to int64) ([]NoaaDatum, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var data []NoaaDatum
+
+	sqlString := "SELECT station, product, datum, value, s, flags, processing_level, timestamp FROM noaa_readings WHERE station = ? AND product = ? AND timestamp BETWEEN ? AND ? ORDER BY timestamp"
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return nil, fmt.Errorf("lookup noaa data %s %s: %w", station, product, err)
+	}
+
+	rows, err := stmt.QueryContext(ctx, station, product, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("lookup noaa data %s %s: %w", station, product, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d NoaaDatum
+		err := rows.Scan(&d.Station, &d.Product, &d.Datum, &d.Value, &d.S, &d.Flags, &d.ProcessingLevel, &d.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("lookup noaa data %s %s: %w", station, product, err)
+		}
+		data = append(data, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("lookup noaa data %s %s: %w", station, product, err)
+	}
+
+	return data, nil
+}
+
+// LookupLatestNoaaDatum reads the most recent reading of a product at a station.
+func (s *sqlStore) LookupLatestNoaaDatum(ctx context.Context, station, product string) (NoaaDatum, bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var d NoaaDatum
+
+	sqlString := "SELECT station, product, datum, value, s, flags, processing_level, timestamp FROM noaa_readings WHERE station = ? AND product = ? ORDER BY timestamp DESC LIMIT 1"
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return d, false, fmt.Errorf("lookup latest noaa datum %s %s: %w", station, product, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, station, product)
+	err = row.Scan(&d.Station, &d.Product, &d.Datum, &d.Value, &d.S, &d.Flags, &d.ProcessingLevel, &d.Timestamp)
+	if err == sql.ErrNoRows {
+		return d, false, nil
+	}
+	if err != nil {
+		return d, false, fmt.Errorf("lookup latest noaa datum %s %s: %w", station, product, err)
+	}
+
+	return d, true, nil
+}
+
+// CountSightings counts the number of times we have seen this ship.
+func (s *sqlStore) CountSightings(ctx context.Context, mmsi string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var count int64
+
+	sqlString := "SELECT COUNT(*) FROM sightings WHERE mmsi = ?"
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return 0, fmt.Errorf("count sightings %s: %w", mmsi, err)
+	}
+
+	rows := stmt.QueryRowContext(ctx, mmsi)
+	err = rows.Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count sightings %s: %w", mmsi, err)
+	}
+
+	return count, nil
+}
+
+// CountSightingsBefore counts the number of times we saw this ship before a Unix time.
+func (s *sqlStore) CountSightingsBefore(ctx context.Context, mmsi string, before int64) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var count int64
+
+	sqlString := "SELECT COUNT(*) FROM sightings WHERE mmsi = ? AND timestamp < ?"
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return 0, fmt.Errorf("count sightings %s before %d: %w", mmsi, before, err)
+	}
+
+	rows := stmt.QueryRowContext(ctx, mmsi, before)
+	err = rows.Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count sightings %s before %d: %w", mmsi, before, err)
+	}
+
+	return count, nil
+}
+
+// CountRows returns the number of rows in the given table.
+func (s *sqlStore) CountRows(ctx context.Context, table string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var count int64
+
+	// Table names cannot be placeholders. Only count tables we know.
+	if !knownTable(table) {
+		return 0, fmt.Errorf("count rows: %w: %q", ErrUnknownTable, table)
+	}
+
+	sqlString := "SELECT COUNT(*) FROM " + table
+
+	stmt, err := s.prepare(ctx, sqlString)
+	if err != nil {
+		return 0, fmt.Errorf("count rows %s: %w", table, err)
+	}
+
+	row := stmt.QueryRowContext(ctx)
+	err = row.Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count rows %s: %w", table, err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,354 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// stores returns one of each kind of store that does not need a server.
+func stores(t *testing.T) map[string]Store {
+	sqlite, err := openSQLite(filepath.Join(t.TempDir(), "shipahoy.db"))
+	if err != nil {
+		t.Fatalf("ERROR: Unable to open SQLite: %v", err)
+	}
+
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"sqlite": sqlite,
+	}
+}
+
+func TestStoreShips(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range stores(t) {
+		exists, err := s.LookupShipExists(ctx, "538007561")
+		if err != nil || exists {
+			t.Errorf("ERROR: %s: ship exists before it was saved %v %v", name, exists, err)
+		}
+
+		err = s.SaveShip(ctx, Ship{MMSI: "538007561", IMO: "9074729", Name: "EVER GIVEN", Flag: "PA", Length: 400}, "test")
+		if err != nil {
+			t.Errorf("ERROR: %s: unable to save ship: %v", name, err)
+		}
+		err = s.SaveShip(ctx, Ship{MMSI: "538007561", IMO: "9074729", Name: "EVER GREEN", Flag: "PA"}, "test")
+		if err != nil {
+			t.Errorf("ERROR: %s: unable to save ship: %v", name, err)
+		}
+
+		details, ok, err := s.LookupShip(ctx, "538007561")
+		if err != nil || !ok || details.Name != "EVER GREEN" || details.Length != 400 {
+			t.Errorf("ERROR: %s: expected the renamed ship with its length, got %v %v %v", name, ok, details, err)
+		}
+		exists, err = s.LookupShipExists(ctx, "538007561")
+		if err != nil || !exists {
+			t.Errorf("ERROR: %s: ship does not exist after it was saved %v %v", name, exists, err)
+		}
+
+		history, err := s.LookupShipHistory(ctx, "538007561")
+		if err != nil || len(history) != 1 || history[0].Field != "name" || history[0].OldValue != "EVER GIVEN" || history[0].NewValue != "EVER GREEN" || history[0].Source != "test" {
+			t.Errorf("ERROR: %s: expected one name change, got %v %v", name, history, err)
+		}
+
+		s.Close()
+	}
+}
+
+func TestStoreSightings(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range stores(t) {
+		details := Ship{MMSI: "366990520", Course: 270, Lat: 37.82, Lon: -122.45, NavigationalStatus: 5}
+
+		if _, ok, _ := s.LookupSighting(ctx, details); ok {
+			t.Errorf("ERROR: %s: sighting found before it was saved", name)
+		}
+
+		s.SaveSighting(ctx, details, 37.8, -122.4)
+		s.SaveSighting(ctx, details, 37.8, -122.4)
+
+		sighting, ok, err := s.LookupSighting(ctx, details)
+		if err != nil || !ok || sighting.MMSI != "366990520" || sighting.ShipCourse != 270 || sighting.MyLat != 37.8 || sighting.NavigationalStatus != 5 {
+			t.Errorf("ERROR: %s: expected the saved sighting, got %v %v %v", name, ok, sighting, err)
+		}
+		last, err := s.LookupLastSighting(ctx, details)
+		if err != nil || last != sighting.Timestamp {
+			t.Errorf("ERROR: %s: expected last sighting at %d, got %d %v", name, sighting.Timestamp, last, err)
+		}
+		count, err := s.CountSightings(ctx, "366990520")
+		if err != nil || count != 2 {
+			t.Errorf("ERROR: %s: expected 2 sightings, got %d %v", name, count, err)
+		}
+		count, err = s.CountSightingsBefore(ctx, "366990520", sighting.Timestamp)
+		if err != nil || count != 0 {
+			t.Errorf("ERROR: %s: expected no sightings before the first, got %d %v", name, count, err)
+		}
+		count, err = s.CountSightingsBefore(ctx, "366990520", sighting.Timestamp+60)
+		if err != nil || count != 2 {
+			t.Errorf("ERROR: %s: expected 2 sightings before a minute later, got %d %v", name, count, err)
+		}
+		all, err := s.LookupSightings(ctx, sighting.Timestamp-60, sighting.Timestamp+60)
+		if err != nil || len(all) != 2 || all[0].MMSI != "366990520" {
+			t.Errorf("ERROR: %s: expected 2 sightings in range, got %v %v", name, all, err)
+		}
+		all, err = s.LookupSightings(ctx, 0, 60)
+		if err != nil || len(all) != 0 {
+			t.Errorf("ERROR: %s: expected no sightings out of range, got %v %v", name, all, err)
+		}
+
+		s.Close()
+	}
+}
+
+func TestStorePositions(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range stores(t) {
+		s.SavePositions(ctx, []Position{
+			{MMSI: "366990520", Timestamp: 300, Lat: 37.3, Source: "test"},
+			{MMSI: "366990520", Timestamp: 100, Lat: 37.1, Source: "test"},
+			{MMSI: "367123640", Timestamp: 200, Lat: 37.2, Source: "test"},
+		})
+		// A repeat of a report we already have.
+		err := s.SavePositions(ctx, []Position{
+			{MMSI: "366990520", Timestamp: 100, Lat: 37.1, Source: "test"},
+		})
+		if err != nil {
+			t.Errorf("ERROR: %s: a repeated report should be skipped, got %v", name, err)
+		}
+
+		track, err := s.LookupTrack(ctx, "366990520", 0, 1000)
+		if err != nil || len(track) != 2 || track[0].Timestamp != 100 || track[1].Timestamp != 300 {
+			t.Errorf("ERROR: %s: expected two positions oldest first, got %v %v", name, track, err)
+		}
+
+		track, err = s.LookupTrack(ctx, "366990520", 200, 1000)
+		if err != nil || len(track) != 1 || track[0].Lat != 37.3 {
+			t.Errorf("ERROR: %s: expected one position in window, got %v %v", name, track, err)
+		}
+
+		last, ok, err := s.LookupLastPosition(ctx, "366990520")
+		if err != nil || !ok || last.Timestamp != 300 {
+			t.Errorf("ERROR: %s: expected last position at 300, got %v %v %v", name, ok, last, err)
+		}
+
+		count, err := s.CountRows(ctx, "positions")
+		if err != nil || count != 3 {
+			t.Errorf("ERROR: %s: expected 3 positions, got %v %v", name, count, err)
+		}
+
+		s.Close()
+	}
+}
+
+func TestStoreNoaa(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range stores(t) {
+		s.SaveNoaaData(ctx, []NoaaDatum{{Station: "9414290", Product: "water_level", Datum: "mllw", Value: "1.458", Timestamp: 200}})
+		s.SaveNoaaData(ctx, []NoaaDatum{{Station: "9414290", Product: "water_level", Datum: "mllw", Value: "1.402", Timestamp: 100}})
+		s.SaveNoaaData(ctx, []NoaaDatum{{Station: "9414304", Product: "air_gap", Datum: "mllw", Value: "204.400", Timestamp: 100}})
+
+		data, err := s.LookupNoaaData(ctx, "9414290", "water_level", 0, 1000)
+		if err != nil || len(data) != 2 || data[0].Value != "1.402" || data[1].Value != "1.458" {
+			t.Errorf("ERROR: %s: expected two readings oldest first, got %v %v", name, data, err)
+		}
+
+		s.Close()
+	}
+}
+
+func TestStoreCountRows(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range stores(t) {
+		s.SaveAnomaly(ctx, Anomaly{MMSI: "319762000", Timestamp: 100, Kind: "impossible speed"})
+
+		for _, table := range tables {
+			count, err := s.CountRows(ctx, table)
+			expected := int64(0)
+			if table == "anomalies" {
+				expected = 1
+			}
+			if err != nil || count != expected {
+				t.Errorf("ERROR: %s: for %s expected %d rows, got %v %v", name, table, expected, count, err)
+			}
+		}
+
+		s.Close()
+	}
+}
+
+func TestStoreHostileMmsi(t *testing.T) {
+	ctx := context.Background()
+
+	hostile := []string{
+		"0 OR 1=1",
+		"366990520 OR 1=1",
+		"1; DROP TABLE ships; --",
+		"' OR ''='",
+		"\"); DROP TABLE sightings; --",
+		"366990520) UNION SELECT * FROM ships --",
+	}
+
+	for name, s := range stores(t) {
+		details := Ship{MMSI: "366990520", Name: "DEL NORTE", Course: 270, Lat: 37.82, Lon: -122.45}
+		s.SaveShip(ctx, details, "test")
+		s.SaveSighting(ctx, details, 37.8, -122.4)
+
+		for _, mmsi := range hostile {
+			if _, ok, err := s.LookupShip(ctx, mmsi); ok || err != nil {
+				t.Errorf("ERROR: %s: LookupShip(%q) found a ship %v", name, mmsi, err)
+			}
+			if exists, err := s.LookupShipExists(ctx, mmsi); exists || err != nil {
+				t.Errorf("ERROR: %s: LookupShipExists(%q) found a ship %v", name, mmsi, err)
+			}
+			if _, ok, err := s.LookupSighting(ctx, Ship{MMSI: mmsi}); ok || err != nil {
+				t.Errorf("ERROR: %s: LookupSighting(%q) found a sighting %v", name, mmsi, err)
+			}
+			if last, err := s.LookupLastSighting(ctx, Ship{MMSI: mmsi}); last != 0 || err != nil {
+				t.Errorf("ERROR: %s: LookupLastSighting(%q) found a sighting %v", name, mmsi, err)
+			}
+			if count, err := s.CountSightings(ctx, mmsi); count != 0 || err != nil {
+				t.Errorf("ERROR: %s: CountSightings(%q) counted sightings %v", name, mmsi, err)
+			}
+		}
+
+		for _, table := range []string{"ships", "sightings"} {
+			count, err := s.CountRows(ctx, table)
+			if err != nil || count != 1 {
+				t.Errorf("ERROR: %s: expected 1 row in %s, got %v %v", name, table, count, err)
+			}
+		}
+
+		for _, table := range []string{"ships; DROP TABLE ships", "sqlite_master", "users"} {
+			if _, err := s.CountRows(ctx, table); !errors.Is(err, ErrUnknownTable) {
+				t.Errorf("ERROR: %s: CountRows(%q) expected ErrUnknownTable, got %v", name, table, err)
+			}
+		}
+
+		s.Close()
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	s, err := openSQLite(filepath.Join(t.TempDir(), "shipahoy.db"))
+	if err != nil {
+		t.Fatalf("ERROR: Unable to open SQLite: %v", err)
+	}
+
+	// A caller that has given up gets an error, not silence.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = s.SaveShip(ctx, Ship{MMSI: "366990520"}, "test")
+	if err == nil {
+		t.Errorf("ERROR: Expected SaveShip with a cancelled context to fail")
+	}
+
+	s.Close()
+
+	err = s.SaveSighting(context.Background(), Ship{MMSI: "366990520"}, 37.8, -122.4)
+	if err == nil {
+		t.Errorf("ERROR: Expected SaveSighting on a closed store to fail")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := DefaultConfig()
+	cfg.Driver = "sqlite"
+	cfg.DSN = filepath.Join(t.TempDir(), "shipahoy.db")
+
+	err := Open(ctx, cfg)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to open SQLite: %v", err)
+	}
+
+	err = SaveShip(ctx, Ship{MMSI: "366990520", Name: "DEL NORTE"}, "test")
+	if err != nil {
+		t.Errorf("ERROR: Unable to save ship: %v", err)
+	}
+
+	stats, err := TableStats(ctx)
+	if err != nil || stats["ships"] != 1 {
+		t.Errorf("ERROR: Expected one ship, got %v %v", stats, err)
+	}
+
+	Close()
+
+	cfg.Driver = "oracle"
+	err = Open(ctx, cfg)
+	if err == nil {
+		t.Errorf("ERROR: Expected an unknown driver to fail")
+	}
+}
+
+func TestConfigUnmarshal(t *testing.T) {
+	testCases := []struct {
+		json     string
+		expected Config
+		ok       bool
+	}{
+		{`{}`, DefaultConfig(), true},
+		{`{"driver": "sqlite", "dsn": "ships.db"}`, Config{Driver: "sqlite", DSN: "ships.db", MaxOpenConns: 10, MaxIdleConns: 5, ConnMaxLifetime: 5 * time.Minute, Timeout: 10 * time.Second}, true},
+		{`{"maxOpenConns": 20, "timeout": "3s", "connMaxIdleTime": "1m"}`, Config{Driver: "mysql", DSN: DefaultConfig().DSN, MaxOpenConns: 20, MaxIdleConns: 5, ConnMaxLifetime: 5 * time.Minute, ConnMaxIdleTime: time.Minute, Timeout: 3 * time.Second}, true},
+		{`{"timeout": "soon"}`, Config{}, false},
+	}
+
+	for _, testCase := range testCases {
+		cfg := DefaultConfig()
+		err := cfg.UnmarshalJSON([]byte(testCase.json))
+		if (err == nil) != testCase.ok {
+			t.Errorf("ERROR: For %s expected ok %v, got %v", testCase.json, testCase.ok, err)
+			continue
+		}
+		if testCase.ok && cfg != testCase.expected {
+			t.Errorf("ERROR: For %s expected %+v, got %+v", testCase.json, testCase.expected, cfg)
+		}
+	}
+}
+
+func TestStoreNoaaDedupe(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range stores(t) {
+		err := s.SaveNoaaData(ctx, []NoaaDatum{
+			{Station: "9414290", Product: "water_level", Datum: "mllw", Value: "1.402", Timestamp: 100},
+			{Station: "9414290", Product: "water_level", Datum: "mllw", Value: "1.458", Timestamp: 200},
+		})
+		if err != nil {
+			t.Errorf("ERROR: %s: unable to save readings: %v", name, err)
+		}
+		// The same reading fetched again.
+		err = s.SaveNoaaData(ctx, []NoaaDatum{
+			{Station: "9414290", Product: "water_level", Datum: "mllw", Value: "1.458", Timestamp: 200},
+		})
+		if err != nil {
+			t.Errorf("ERROR: %s: a repeated reading should be skipped, got %v", name, err)
+		}
+
+		count, err := s.CountRows(ctx, "noaa_readings")
+		if err != nil || count != 2 {
+			t.Errorf("ERROR: %s: expected 2 readings, got %d %v", name, count, err)
+		}
+
+		latest, ok, err := s.LookupLatestNoaaDatum(ctx, "9414290", "water_level")
+		if err != nil || !ok || latest.Timestamp != 200 {
+			t.Errorf("ERROR: %s: expected latest reading at 200, got %v %v %v", name, latest, ok, err)
+		}
+
+		_, ok, err = s.LookupLatestNoaaDatum(ctx, "9414304", "air_gap")
+		if err != nil || ok {
+			t.Errorf("ERROR: %s: expected no air gap reading, got %v %v", name, ok, err)
+		}
+
+		s.Close()
+	}
+}
@@ -0,0 +1,57 @@
+package geo
+
+import (
+	"math"
+)
+
+// earthRadius is the mean radius of the Earth in nautical miles.
+const earthRadius = 3440.065
+
+// radians converts degrees to radians.
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// degrees converts radians to degrees.
+func degrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+// Distance returns the great circle distance in nautical miles between two lat/lon points.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	// https://www.movable-type.co.uk/scripts/latlong.html (haversine)
+	φ1 := radians(lat1)
+	φ2 := radians(lat2)
+	Δφ := radians(lat2 - lat1)
+	Δλ := radians(lon2 - lon1)
+
+	a := math.Sin(Δφ/2)*math.Sin(Δφ/2) + math.Cos(φ1)*math.Cos(φ2)*math.Sin(Δλ/2)*math.Sin(Δλ/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return earthRadius * c
+}
+
+// Bearing returns the initial true bearing in degrees [0, 360) from the first point to the second.
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	φ1 := radians(lat1)
+	φ2 := radians(lat2)
+	Δλ := radians(lon2 - lon1)
+
+	y := math.Sin(Δλ) * math.Cos(φ2)
+	x := math.Cos(φ1)*math.Sin(φ2) - math.Sin(φ1)*math.Cos(φ2)*math.Cos(Δλ)
+
+	return math.Mod(degrees(math.Atan2(y, x))+360, 360)
+}
+
+// Destination returns the point reached by travelling a distance in nautical miles from a lat/lon on an initial true bearing.
+func Destination(lat, lon, bearing, distance float64) (float64, float64) {
+	φ1 := radians(lat)
+	λ1 := radians(lon)
+	θ := radians(bearing)
+	δ := distance / earthRadius
+
+	φ2 := math.Asin(math.Sin(φ1)*math.Cos(δ) + math.Cos(φ1)*math.Sin(δ)*math.Cos(θ))
+	λ2 := λ1 + math.Atan2(math.Sin(θ)*math.Sin(δ)*math.Cos(φ1), math.Cos(δ)-math.Sin(φ1)*math.Sin(φ2))
+
+	return degrees(φ2), math.Mod(degrees(λ2)+540, 360) - 180
+}
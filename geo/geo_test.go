@@ -0,0 +1,84 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	testCases := []struct {
+		lat1     float64
+		lon1     float64
+		lat2     float64
+		lon2     float64
+		expected float64
+	}{
+		{37.8, -122.4, 37.8, -122.4, 0},
+		{0, 0, 1, 0, 60.04},
+		{0, 0, 0, 1, 60.04},
+		{37.8267, -122.4230, 37.8080, -122.4750, 2.71}, // Alcatraz to the Golden Gate Bridge
+	}
+
+	for _, testCase := range testCases {
+		answer := Distance(testCase.lat1, testCase.lon1, testCase.lat2, testCase.lon2)
+		if math.Abs(answer-testCase.expected) > 0.01 {
+			t.Errorf("ERROR: For %v, %v, %v, %v expected %v, got %v", testCase.lat1, testCase.lon1, testCase.lat2, testCase.lon2, testCase.expected, answer)
+		}
+	}
+}
+
+func TestBearing(t *testing.T) {
+	testCases := []struct {
+		lat1     float64
+		lon1     float64
+		lat2     float64
+		lon2     float64
+		expected float64
+	}{
+		{0, 0, 1, 0, 0},
+		{0, 0, 0, 1, 90},
+		{0, 0, -1, 0, 180},
+		{0, 0, 0, -1, 270},
+	}
+
+	for _, testCase := range testCases {
+		answer := Bearing(testCase.lat1, testCase.lon1, testCase.lat2, testCase.lon2)
+		if math.Abs(answer-testCase.expected) > 0.01 {
+			t.Errorf("ERROR: For %v, %v, %v, %v expected %v, got %v", testCase.lat1, testCase.lon1, testCase.lat2, testCase.lon2, testCase.expected, answer)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	testCases := []struct {
+		lat      float64
+		lon      float64
+		bearing  float64
+		distance float64
+	}{
+		{0, 0, 0, 60.04},
+		{0, 0, 90, 60.04},
+		{37.8267, -122.4230, 250, 2.71},
+		{37.8080, -122.4750, 45, 0},
+		{10, 179.9, 90, 30},
+	}
+
+	for _, testCase := range testCases {
+		lat, lon := Destination(testCase.lat, testCase.lon, testCase.bearing, testCase.distance)
+		distance := Distance(testCase.lat, testCase.lon, lat, lon)
+		if math.Abs(distance-testCase.distance) > 0.01 || lon < -180 || lon > 180 {
+			t.Errorf("ERROR: For %v, %v, %v, %v expected to travel %v, got %v, %v", testCase.lat, testCase.lon, testCase.bearing, testCase.distance, testCase.distance, lat, lon)
+		}
+		if testCase.distance > 0 {
+			bearing := Bearing(testCase.lat, testCase.lon, lat, lon)
+			if math.Abs(bearing-testCase.bearing) > 0.1 {
+				t.Errorf("ERROR: For %v, %v, %v, %v expected bearing %v, got %v", testCase.lat, testCase.lon, testCase.bearing, testCase.distance, testCase.bearing, bearing)
+			}
+		}
+	}
+
+	lat, lon := Destination(0, 0, 0, 60.04)
+	if math.Abs(lat-1) > 0.001 || math.Abs(lon) > 0.001 {
+		t.Errorf("ERROR: Expected 1 degree north, got %v, %v", lat, lon)
+	}
+}
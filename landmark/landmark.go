@@ -0,0 +1,118 @@
+// Package landmark says where a ship is in terms of the places around it,
+// so that people know where to look.
+package landmark
+
+import (
+	"math"
+
+	"github.com/erikbryant/shipahoy/database"
+	"github.com/erikbryant/shipahoy/geo"
+)
+
+// Landmark is a place people know, that ships pass.
+type Landmark struct {
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+}
+
+// Config holds the landmarks to describe ships by.
+type Config struct {
+	Landmarks []Landmark `json:"landmarks"`
+	Range     float64    `json:"range"` // describe ships only this close to a landmark, nautical miles
+}
+
+// DefaultConfig returns the landmarks of the central bay and the Golden Gate.
+func DefaultConfig() Config {
+	return Config{
+		Landmarks: []Landmark{
+			{Name: "Alcatraz", Lat: 37.8267, Lon: -122.4230},
+			{Name: "Angel Island", Lat: 37.8609, Lon: -122.4326},
+			{Name: "Golden Gate Bridge", Lat: 37.8199, Lon: -122.4783},
+			{Name: "Point Bonita", Lat: 37.8157, Lon: -122.5297},
+			{Name: "Point Diablo", Lat: 37.8200, Lon: -122.4994},
+			{Name: "Mile Rock", Lat: 37.7928, Lon: -122.5104},
+			{Name: "Fort Point", Lat: 37.8106, Lon: -122.4771},
+			{Name: "Point Blunt", Lat: 37.8533, Lon: -122.4192},
+			{Name: "Sausalito", Lat: 37.8591, Lon: -122.4853},
+			{Name: "Pier 39", Lat: 37.8087, Lon: -122.4098},
+			{Name: "Treasure Island", Lat: 37.8235, Lon: -122.3706},
+			{Name: "Yerba Buena Island", Lat: 37.8096, Lon: -122.3630},
+			{Name: "Bay Bridge", Lat: 37.7983, Lon: -122.3778},
+			{Name: "Richmond-San Rafael Bridge", Lat: 37.9359, Lon: -122.4458},
+		},
+		Range: 2,
+	}
+}
+
+// Compass points, in order clockwise from north.
+var points = []string{"north", "northeast", "east", "southeast", "south", "southwest", "west", "northwest"}
+
+// Point returns the nearest of the eight compass points to a bearing.
+func Point(bearing float64) string {
+	i := int(math.Round(math.Mod(bearing+360, 360)/45)) % len(points)
+	return points[i]
+}
+
+// Ships slower than this are taken to be stopped, knots.
+const underway = 0.5
+
+// Description is where a ship is: by the nearest landmark, and from the
+// observer.
+type Description struct {
+	Landmark    string  // empty if no landmark is in range
+	Side        string  // the compass point of the ship from the landmark
+	Underway    bool    // moving, rather than stopped or at anchor
+	Approaching bool    // the landmark is ahead of the ship
+	Heading     string  // the compass point the ship is going
+	Bearing     float64 // of the ship from the observer, degrees true
+	Distance    float64 // of the ship from the observer, nautical miles
+}
+
+// Gazetteer describes ships by the landmarks near them.
+type Gazetteer struct {
+	cfg Config
+}
+
+// New returns a gazetteer of the configured landmarks.
+func New(cfg Config) *Gazetteer {
+	return &Gazetteer{cfg: cfg}
+}
+
+// nearest returns the landmark nearest a point, and how far away it is.
+func (g *Gazetteer) nearest(lat, lon float64) (Landmark, float64, bool) {
+	var found Landmark
+	best := math.Inf(1)
+
+	for _, l := range g.cfg.Landmarks {
+		d := geo.Distance(lat, lon, l.Lat, l.Lon)
+		if d < best {
+			found, best = l, d
+		}
+	}
+
+	return found, best, best <= g.cfg.Range
+}
+
+// Describe says where a ship is, as seen by an observer at lat, lon.
+func (g *Gazetteer) Describe(ship database.Ship, lat, lon float64) Description {
+	d := Description{
+		Underway: ship.Speed >= underway,
+		Heading:  Point(ship.Course),
+		Bearing:  geo.Bearing(lat, lon, ship.Lat, ship.Lon),
+		Distance: geo.Distance(lat, lon, ship.Lat, ship.Lon),
+	}
+
+	l, _, ok := g.nearest(ship.Lat, ship.Lon)
+	if !ok {
+		return d
+	}
+	d.Landmark = l.Name
+	d.Side = Point(geo.Bearing(l.Lat, l.Lon, ship.Lat, ship.Lon))
+
+	// Ahead means within 45 degrees either side of the ship's course.
+	ahead := math.Abs(math.Mod(geo.Bearing(ship.Lat, ship.Lon, l.Lat, l.Lon)-ship.Course+540, 360) - 180)
+	d.Approaching = d.Underway && ahead < 45
+
+	return d
+}
@@ -0,0 +1,70 @@
+package landmark
+
+import (
+	"math"
+	"testing"
+
+	"github.com/erikbryant/shipahoy/database"
+)
+
+func TestPoint(t *testing.T) {
+	testCases := []struct {
+		bearing  float64
+		expected string
+	}{
+		{0, "north"},
+		{22, "north"},
+		{23, "northeast"},
+		{90, "east"},
+		{180, "south"},
+		{315, "northwest"},
+		{350, "north"},
+		{-45, "northwest"},
+	}
+
+	for _, testCase := range testCases {
+		answer := Point(testCase.bearing)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %v expected %q, got %q", testCase.bearing, testCase.expected, answer)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	g := New(DefaultConfig())
+	home := struct{ lat, lon float64 }{37.8007, -122.4097}
+
+	testCases := []struct {
+		ship     database.Ship
+		expected Description
+	}{
+		{
+			database.Ship{Lat: 37.8317, Lon: -122.4230, Course: 90, Speed: 10},
+			Description{Landmark: "Alcatraz", Side: "north", Underway: true, Heading: "east", Bearing: 341, Distance: 1.97},
+		},
+		{
+			database.Ship{Lat: 37.8267, Lon: -122.4310, Course: 92, Speed: 12},
+			Description{Landmark: "Alcatraz", Side: "west", Underway: true, Approaching: true, Heading: "east", Bearing: 327, Distance: 1.86},
+		},
+		{
+			database.Ship{Lat: 37.8549, Lon: -122.4326, Course: 200},
+			Description{Landmark: "Angel Island", Side: "south", Heading: "south", Bearing: 341, Distance: 3.44},
+		},
+		{
+			database.Ship{Lat: 37.7, Lon: -122.7, Course: 270, Speed: 15},
+			Description{Underway: true, Heading: "west", Bearing: 246, Distance: 15.1},
+		},
+	}
+
+	for _, testCase := range testCases {
+		answer := g.Describe(testCase.ship, home.lat, home.lon)
+		expected := testCase.expected
+		if answer.Landmark != expected.Landmark || answer.Side != expected.Side || answer.Underway != expected.Underway ||
+			answer.Approaching != expected.Approaching || answer.Heading != expected.Heading {
+			t.Errorf("ERROR: For %+v expected %+v, got %+v", testCase.ship, expected, answer)
+		}
+		if math.Abs(answer.Bearing-expected.Bearing) > 1 || math.Abs(answer.Distance-expected.Distance) > 0.1 {
+			t.Errorf("ERROR: For %+v expected bearing %v and distance %v, got %v and %v", testCase.ship, expected.Bearing, expected.Distance, answer.Bearing, answer.Distance)
+		}
+	}
+}
@@ -10,14 +10,51 @@ import (
 	"time"
 
 	"github.com/erikbryant/shipahoy"
+	"github.com/erikbryant/shipahoy/config"
 	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
 )
 
 var (
 	cpuprofile = flag.String("cpuprofile", "", "Enable profiling and write cpu profile to file")
 	passPhrase = flag.String("passPhrase", "", "Passphrase to unlock API key(s)")
+	configPath = flag.String("config", "", "Read settings from this JSON file")
+	dbDriver   = flag.String("dbDriver", "", "Database driver: mysql, sqlite or memory")
+	dsn        = flag.String("dsn", "", "Database data source name (for SQLite, the file name)")
+	dbTimeout  = flag.Duration("dbTimeout", 0, "Longest any one database call may take")
+	sqlitePath = flag.String("sqlite", "", "Store ships in this SQLite file; short for -dbDriver sqlite -dsn FILE")
 )
 
+// settings loads the configuration and applies any flags that were set.
+func settings() (config.Config, error) {
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return cfg, err
+	}
+
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if set["sqlite"] && (set["dbDriver"] || set["dsn"]) {
+		return cfg, fmt.Errorf("-sqlite cannot be used with -dbDriver or -dsn")
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "dbDriver":
+			cfg.Database.Driver = *dbDriver
+		case "dsn":
+			cfg.Database.DSN = *dsn
+		case "dbTimeout":
+			cfg.Database.Timeout = *dbTimeout
+		case "sqlite":
+			cfg.Database.Driver = "sqlite"
+			cfg.Database.DSN = *sqlitePath
+		}
+	})
+
+	return cfg, nil
+}
+
 func main() {
 	flag.Parse()
 
@@ -35,7 +72,13 @@ func main() {
 		os.Exit(1)
 	}
 
-	err := shipahoy.Start(*passPhrase)
+	cfg, err := settings()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	err = shipahoy.Start(*passPhrase, cfg)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -0,0 +1,99 @@
+package mqtt
+
+import (
+	"github.com/erikbryant/shipahoy/noaa"
+)
+
+// units are those of the NOAA products we read, in English units.
+var units = map[string]string{
+	noaa.ProductWaterLevel:       "ft",
+	noaa.ProductAirGap:           "ft",
+	noaa.ProductPredictions:      "ft",
+	noaa.ProductAirTemperature:   "°F",
+	noaa.ProductWaterTemperature: "°F",
+	noaa.ProductAirPressure:      "mbar",
+	noaa.ProductVisibility:       "nmi",
+}
+
+// device groups every sensor under one device in Home Assistant.
+type device struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer,omitempty"`
+	Model        string   `json:"model,omitempty"`
+}
+
+// sensor is a Home Assistant MQTT discovery payload for a sensor. See
+// https://www.home-assistant.io/integrations/sensor.mqtt/
+type sensor struct {
+	id string // object ID, unique among our sensors
+
+	Name                string `json:"name"`
+	UniqueID            string `json:"unique_id"`
+	StateTopic          string `json:"state_topic"`
+	ValueTemplate       string `json:"value_template"`
+	JSONAttributesTopic string `json:"json_attributes_topic,omitempty"`
+	UnitOfMeasurement   string `json:"unit_of_measurement,omitempty"`
+	AvailabilityTopic   string `json:"availability_topic"`
+	Icon                string `json:"icon,omitempty"`
+	Device              device `json:"device"`
+}
+
+// newSensor fills in what every sensor has.
+func newSensor(cfg Config, id, name, stateTopic, valueTemplate string) sensor {
+	return sensor{
+		id:                  id,
+		Name:                name,
+		UniqueID:            cfg.ClientID + "_" + id,
+		StateTopic:          stateTopic,
+		ValueTemplate:       valueTemplate,
+		JSONAttributesTopic: stateTopic,
+		AvailabilityTopic:   cfg.Prefix + "/status",
+		Device: device{
+			Identifiers: []string{cfg.ClientID},
+			Name:        "Ship Ahoy",
+			Model:       "shipahoy",
+		},
+	}
+}
+
+// sightingSensor shows the last ship seen.
+func sightingSensor(cfg Config) sensor {
+	s := newSensor(cfg, "last_sighting", "Last ship sighted", cfg.Prefix+"/sightings", "{{ value_json.vessel.name }}")
+	s.Icon = "mdi:ferry"
+	return s
+}
+
+// readingSensor shows the latest of a NOAA product at a station.
+func readingSensor(cfg Config, station, product string) sensor {
+	s := newSensor(cfg, "noaa_"+station+"_"+product, "NOAA "+station+" "+product, cfg.Prefix+"/noaa/"+station+"/"+product, "{{ value_json.value | float }}")
+	s.UnitOfMeasurement = units[product]
+	s.Icon = "mdi:waves"
+	return s
+}
+
+// discover announces a sensor to Home Assistant, once per run. The
+// announcement is retained, so Home Assistant finds it after a restart.
+func (p *Publisher) discover(s sensor) error {
+	if !p.cfg.Discovery {
+		return nil
+	}
+
+	p.mu.Lock()
+	done := p.discovered[s.id]
+	p.mu.Unlock()
+	if done {
+		return nil
+	}
+
+	err := p.publishJSON(p.cfg.DiscoveryPrefix+"/sensor/"+s.UniqueID+"/config", true, s)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.discovered[s.id] = true
+	p.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,327 @@
+// Package mqtt publishes ships, sightings, anomalies and NOAA readings to
+// an MQTT broker, so dashboards and lights can react to ships without
+// polling the database. It can announce its sensors to Home Assistant.
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/erikbryant/shipahoy/alert"
+	"github.com/erikbryant/shipahoy/database"
+)
+
+// Config says which broker to use and how to publish to it.
+type Config struct {
+	Broker          string `json:"broker"` // such as "tcp://localhost:1883"; empty means do not publish
+	ClientID        string `json:"clientID"`
+	Username        string `json:"username"`
+	Password        string `json:"password"`
+	Prefix          string `json:"prefix"`          // first level of every topic
+	QoS             byte   `json:"qos"`             // 0, 1 or 2
+	Discovery       bool   `json:"discovery"`       // announce sensors to Home Assistant
+	DiscoveryPrefix string `json:"discoveryPrefix"` // where Home Assistant listens for them
+}
+
+// DefaultConfig returns settings with no broker, so nothing is published.
+func DefaultConfig() Config {
+	return Config{
+		ClientID:        "shipahoy",
+		Prefix:          "shipahoy",
+		QoS:             1,
+		Discovery:       true,
+		DiscoveryPrefix: "homeassistant",
+	}
+}
+
+// Client is the part of an MQTT client the publisher needs.
+type Client interface {
+	Publish(topic string, qos byte, retained bool, payload []byte) error
+	Disconnect()
+}
+
+// How long to wait for the broker.
+const timeout = 10 * time.Second
+
+// pahoClient is a Client backed by a connection to a real broker.
+type pahoClient struct {
+	client paho.Client
+}
+
+// Publish sends a message and waits for the broker to take it.
+func (c pahoClient) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	token := c.client.Publish(topic, qos, retained, payload)
+	if !token.WaitTimeout(timeout) {
+		return fmt.Errorf("publish %s: timed out", topic)
+	}
+
+	return token.Error()
+}
+
+// Disconnect closes the connection, giving queued messages a moment to go.
+func (c pahoClient) Disconnect() {
+	c.client.Disconnect(250)
+}
+
+// Publisher publishes to topics under the configured prefix. The current
+// state of each ship, and the latest of each NOAA reading, are retained
+// so that new subscribers get them at once. A nil Publisher publishes
+// nothing.
+type Publisher struct {
+	cfg    Config
+	client Client
+
+	mu         sync.Mutex
+	discovered map[string]bool
+}
+
+// Connect connects to the configured broker. The broker marks us offline
+// if the connection drops.
+func Connect(cfg Config) (*Publisher, error) {
+	status := cfg.Prefix + "/status"
+
+	options := paho.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetWill(status, "offline", cfg.QoS, true)
+	client := paho.NewClient(options)
+
+	token := client.Connect()
+	if !token.WaitTimeout(timeout) {
+		return nil, fmt.Errorf("connect %s: timed out", cfg.Broker)
+	}
+	if token.Error() != nil {
+		return nil, fmt.Errorf("connect %s: %w", cfg.Broker, token.Error())
+	}
+
+	p := NewPublisher(cfg, pahoClient{client: client})
+	err := p.publish(status, true, []byte("online"))
+	if err != nil {
+		client.Disconnect(0)
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// NewPublisher returns a publisher that uses an existing client.
+func NewPublisher(cfg Config, client Client) *Publisher {
+	return &Publisher{
+		cfg:        cfg,
+		client:     client,
+		discovered: map[string]bool{},
+	}
+}
+
+// Close marks us offline and disconnects.
+func (p *Publisher) Close() {
+	if p == nil {
+		return
+	}
+
+	p.publish(p.cfg.Prefix+"/status", true, []byte("offline"))
+	p.client.Disconnect()
+}
+
+// publish sends a message at the configured QoS.
+func (p *Publisher) publish(topic string, retained bool, payload []byte) error {
+	return p.client.Publish(topic, p.cfg.QoS, retained, payload)
+}
+
+// publishJSON sends a value as JSON.
+func (p *Publisher) publishJSON(topic string, retained bool, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return p.publish(topic, retained, payload)
+}
+
+// topic returns a topic under the prefix.
+func (p *Publisher) topic(levels ...string) string {
+	return p.cfg.Prefix + "/" + strings.Join(levels, "/")
+}
+
+// Vessel is the state of a ship, as published.
+type Vessel struct {
+	MMSI               string  `json:"mmsi"`
+	IMO                string  `json:"imo,omitempty"`
+	Name               string  `json:"name"`
+	Type               string  `json:"type"`
+	Flag               string  `json:"flag,omitempty"`
+	Length             int     `json:"length,omitempty"`
+	Beam               int     `json:"beam,omitempty"`
+	Draught            float64 `json:"draught,omitempty"`
+	Lat                float64 `json:"latitude"`
+	Lon                float64 `json:"longitude"`
+	Course             float64 `json:"course"`
+	Heading            float64 `json:"heading"`
+	Speed              float64 `json:"speed"`
+	NavigationalStatus int     `json:"navStatus"`
+	Destination        string  `json:"destination,omitempty"`
+	LastPosUpdate      int     `json:"lastPosUpdate"`
+	DirectLink         string  `json:"directLink,omitempty"`
+}
+
+// vessel converts a ship to what is published.
+func vessel(details database.Ship) Vessel {
+	return Vessel{
+		MMSI:               details.MMSI,
+		IMO:                details.IMO,
+		Name:               details.Name,
+		Type:               details.Type,
+		Flag:               details.Flag,
+		Length:             details.Length,
+		Beam:               details.Beam,
+		Draught:            details.Draught,
+		Lat:                details.Lat,
+		Lon:                details.Lon,
+		Course:             details.Course,
+		Heading:            details.Heading,
+		Speed:              details.Speed,
+		NavigationalStatus: details.NavigationalStatus,
+		Destination:        details.Destination,
+		LastPosUpdate:      details.LastPosUpdate,
+		DirectLink:         details.DirectLink,
+	}
+}
+
+// Vessel publishes the current state of a ship to <prefix>/vessels/<mmsi>, retained.
+func (p *Publisher) Vessel(details database.Ship) error {
+	if p == nil {
+		return nil
+	}
+
+	return p.publishJSON(p.topic("vessels", details.MMSI), true, vessel(details))
+}
+
+// Sighting is a sighting, as published.
+type Sighting struct {
+	Time         time.Time `json:"time"`
+	Vessel       Vessel    `json:"vessel"`
+	Zone         string    `json:"zone,omitempty"`
+	DwellSeconds int64     `json:"dwellSeconds,omitempty"`
+	Score        int       `json:"score,omitempty"`
+	Warnings     []string  `json:"warnings,omitempty"`
+}
+
+// Anomaly is a suspicious ship report, as published.
+type Anomaly struct {
+	Time   time.Time `json:"time"`
+	MMSI   string    `json:"mmsi"`
+	Name   string    `json:"name"`
+	Kind   string    `json:"kind"`
+	Detail string    `json:"detail"`
+}
+
+// Name returns "mqtt".
+func (p *Publisher) Name() string {
+	return "mqtt"
+}
+
+// Notify publishes an alert event. Sightings go to <prefix>/sightings and
+// update the ship's state; anomalies go to <prefix>/anomalies. Whether the
+// database is up is retained at <prefix>/database.
+func (p *Publisher) Notify(e alert.Event) error {
+	if p == nil {
+		return nil
+	}
+
+	switch e.Kind {
+	case alert.Sighting:
+		err := p.discover(sightingSensor(p.cfg))
+		if err != nil {
+			return err
+		}
+		s := Sighting{
+			Time:         e.Time.UTC(),
+			Vessel:       vessel(e.Ship),
+			Zone:         e.Zone,
+			DwellSeconds: int64(e.Dwell.Seconds()),
+			Score:        e.Score,
+		}
+		for _, w := range e.Warnings {
+			s.Warnings = append(s.Warnings, w.String())
+		}
+		err = p.Vessel(e.Ship)
+		if err != nil {
+			return err
+		}
+		// Retained, so the sensor shows the last ship seen.
+		return p.publishJSON(p.topic("sightings"), true, s)
+	case alert.Suspicious:
+		return p.publishAnomaly(e.Time, e.Ship, e.Anomaly)
+	case alert.DatabaseDown:
+		return p.publish(p.topic("database"), true, []byte("down"))
+	}
+
+	return nil
+}
+
+// publishAnomaly publishes an anomaly to <prefix>/anomalies, not retained.
+func (p *Publisher) publishAnomaly(at time.Time, details database.Ship, a database.Anomaly) error {
+	return p.publishJSON(p.topic("anomalies"), false, Anomaly{
+		Time:   at.UTC(),
+		MMSI:   details.MMSI,
+		Name:   details.Name,
+		Kind:   a.Kind,
+		Detail: a.Detail,
+	})
+}
+
+// Anomaly publishes an anomaly found in a ship's reports, at the time it
+// was found.
+func (p *Publisher) Anomaly(details database.Ship, a database.Anomaly) error {
+	if p == nil {
+		return nil
+	}
+
+	return p.publishAnomaly(time.Unix(a.Timestamp, 0), details, a)
+}
+
+// DatabaseUp publishes that the database is saving ships again.
+func (p *Publisher) DatabaseUp() error {
+	if p == nil {
+		return nil
+	}
+
+	return p.publish(p.topic("database"), true, []byte("up"))
+}
+
+// Reading is a NOAA reading, as published.
+type Reading struct {
+	Station string    `json:"station"`
+	Product string    `json:"product"`
+	Datum   string    `json:"datum,omitempty"`
+	Value   string    `json:"value"`
+	Time    time.Time `json:"time"`
+}
+
+// Reading publishes a NOAA reading to <prefix>/noaa/<station>/<product>, retained.
+func (p *Publisher) Reading(d database.NoaaDatum) error {
+	if p == nil {
+		return nil
+	}
+
+	err := p.discover(readingSensor(p.cfg, d.Station, d.Product))
+	if err != nil {
+		return err
+	}
+
+	return p.publishJSON(p.topic("noaa", d.Station, d.Product), true, Reading{
+		Station: d.Station,
+		Product: d.Product,
+		Datum:   d.Datum,
+		Value:   d.Value,
+		Time:    time.Unix(d.Timestamp, 0).UTC(),
+	})
+}
@@ -0,0 +1,182 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erikbryant/shipahoy/alert"
+	"github.com/erikbryant/shipahoy/database"
+)
+
+// message is something published to the fake broker.
+type message struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  []byte
+}
+
+// fakeClient keeps what is published instead of sending it.
+type fakeClient struct {
+	messages     []message
+	err          error
+	disconnected bool
+}
+
+func (c *fakeClient) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.messages = append(c.messages, message{topic: topic, qos: qos, retained: retained, payload: payload})
+	return nil
+}
+
+func (c *fakeClient) Disconnect() {
+	c.disconnected = true
+}
+
+// find returns the messages published to a topic.
+func (c *fakeClient) find(topic string) []message {
+	var found []message
+	for _, m := range c.messages {
+		if m.topic == topic {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+var hawk = database.Ship{MMSI: "366999712", Name: "Hawk", Type: "Tug", Lat: 37.82, Lon: -122.46, Speed: 7.5, Course: 90}
+
+func TestVessel(t *testing.T) {
+	client := &fakeClient{}
+	p := NewPublisher(DefaultConfig(), client)
+
+	err := p.Vessel(hawk)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to publish: %v", err)
+	}
+
+	found := client.find("shipahoy/vessels/366999712")
+	if len(found) != 1 {
+		t.Fatalf("ERROR: Expected 1 message on the vessel topic, got %v", client.messages)
+	}
+	if !found[0].retained || found[0].qos != 1 {
+		t.Errorf("ERROR: Expected vessel state retained at QoS 1, got %v %v", found[0].retained, found[0].qos)
+	}
+	var v Vessel
+	err = json.Unmarshal(found[0].payload, &v)
+	if err != nil || v.Name != "Hawk" || v.Lat != 37.82 || v.Speed != 7.5 {
+		t.Errorf("ERROR: Unexpected vessel state %s: %v", found[0].payload, err)
+	}
+
+	// No discovery for every ship.
+	if len(client.messages) != 1 {
+		t.Errorf("ERROR: Expected only the vessel state, got %d messages", len(client.messages))
+	}
+}
+
+func TestNotify(t *testing.T) {
+	testCases := []struct {
+		event    alert.Event
+		topic    string
+		retained bool
+		contains string
+	}{
+		{alert.Event{Kind: alert.Sighting, Ship: hawk, Zone: "apartment view", Dwell: 5 * time.Minute, Score: 40}, "shipahoy/sightings", true, `"dwellSeconds":300`},
+		{alert.Event{Kind: alert.Suspicious, Ship: hawk, Anomaly: database.Anomaly{Kind: "teleport", Detail: "moved 40 nm in a minute"}}, "shipahoy/anomalies", false, `"kind":"teleport"`},
+		{alert.Event{Kind: alert.DatabaseDown, Err: errors.New("gone")}, "shipahoy/database", true, "down"},
+	}
+
+	for _, testCase := range testCases {
+		client := &fakeClient{}
+		err := NewPublisher(DefaultConfig(), client).Notify(testCase.event)
+		if err != nil {
+			t.Errorf("ERROR: For %s unable to notify: %v", testCase.event.Kind, err)
+			continue
+		}
+		found := client.find(testCase.topic)
+		if len(found) != 1 {
+			t.Errorf("ERROR: For %s expected 1 message on %s, got %v", testCase.event.Kind, testCase.topic, client.messages)
+			continue
+		}
+		if found[0].retained != testCase.retained {
+			t.Errorf("ERROR: For %s expected retained %v, got %v", testCase.event.Kind, testCase.retained, found[0].retained)
+		}
+		if !strings.Contains(string(found[0].payload), testCase.contains) {
+			t.Errorf("ERROR: For %s expected %s in %s", testCase.event.Kind, testCase.contains, found[0].payload)
+		}
+	}
+}
+
+func TestAnomaly(t *testing.T) {
+	client := &fakeClient{}
+	a := database.Anomaly{MMSI: hawk.MMSI, Timestamp: 1700000000, Kind: "teleport", Detail: "moved 40 nm in a minute"}
+
+	err := NewPublisher(DefaultConfig(), client).Anomaly(hawk, a)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to publish: %v", err)
+	}
+	found := client.find("shipahoy/anomalies")
+	if len(found) != 1 || found[0].retained {
+		t.Fatalf("ERROR: Expected 1 message, not retained, on the anomalies topic, got %v", client.messages)
+	}
+	var got Anomaly
+	err = json.Unmarshal(found[0].payload, &got)
+	if err != nil || got.Name != "Hawk" || got.Kind != "teleport" || !got.Time.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("ERROR: Unexpected anomaly %s: %v", found[0].payload, err)
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	client := &fakeClient{}
+	cfg := DefaultConfig()
+	cfg.QoS = 0
+	p := NewPublisher(cfg, client)
+
+	reading := database.NoaaDatum{Station: "9414290", Product: "water_level", Datum: "mllw", Value: "3.120", Timestamp: 1790000000}
+	for i := 0; i < 2; i++ {
+		err := p.Reading(reading)
+		if err != nil {
+			t.Fatalf("ERROR: Unable to publish: %v", err)
+		}
+	}
+
+	config := client.find("homeassistant/sensor/shipahoy_noaa_9414290_water_level/config")
+	if len(config) != 1 {
+		t.Fatalf("ERROR: Expected the sensor to be announced once, got %v", client.messages)
+	}
+	var s sensor
+	err := json.Unmarshal(config[0].payload, &s)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to decode %s: %v", config[0].payload, err)
+	}
+	if s.StateTopic != "shipahoy/noaa/9414290/water_level" || s.UnitOfMeasurement != "ft" || s.AvailabilityTopic != "shipahoy/status" || !config[0].retained {
+		t.Errorf("ERROR: Unexpected discovery payload %s", config[0].payload)
+	}
+
+	readings := client.find("shipahoy/noaa/9414290/water_level")
+	if len(readings) != 2 || !readings[0].retained || readings[0].qos != 0 {
+		t.Errorf("ERROR: Expected 2 retained readings at QoS 0, got %v", readings)
+	}
+
+	// Without discovery, only the readings.
+	client = &fakeClient{}
+	cfg.Discovery = false
+	NewPublisher(cfg, client).Reading(reading)
+	if len(client.messages) != 1 {
+		t.Errorf("ERROR: Expected no discovery, got %v", client.messages)
+	}
+}
+
+func TestNilPublisher(t *testing.T) {
+	var p *Publisher
+
+	if p.Vessel(hawk) != nil || p.Reading(database.NoaaDatum{}) != nil || p.Notify(alert.Event{Kind: alert.Sighting}) != nil || p.DatabaseUp() != nil || p.Anomaly(hawk, database.Anomaly{}) != nil {
+		t.Errorf("ERROR: Expected a nil publisher to do nothing")
+	}
+	p.Close()
+}
@@ -0,0 +1,588 @@
+package noaa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erikbryant/web"
+)
+
+// Products served by the CO-OPS Data API.
+const (
+	ProductWaterLevel         = "water_level"
+	ProductAirGap             = "air_gap"
+	ProductPredictions        = "predictions"
+	ProductCurrents           = "currents"
+	ProductCurrentPredictions = "currents_predictions"
+	ProductWind               = "wind"
+	ProductAirTemperature     = "air_temperature"
+	ProductWaterTemperature   = "water_temperature"
+	ProductAirPressure        = "air_pressure"
+	ProductVisibility         = "visibility"
+)
+
+// Datums that water levels can be measured from.
+const (
+	DatumMLLW = "mllw" // mean lower low water, what charts use
+	DatumMLW  = "mlw"
+	DatumMSL  = "msl"
+	DatumMTL  = "mtl"
+	DatumMHW  = "mhw"
+	DatumMHHW = "mhhw"
+	DatumNAVD = "navd"
+	DatumSTND = "stnd"
+)
+
+// Units.
+const (
+	English = "english" // feet, knots, degrees Fahrenheit, nautical miles
+	Metric  = "metric"  // meters, cm/s, degrees Celsius, kilometers
+)
+
+// Time zones that times are requested and returned in.
+const (
+	GMT    = "gmt"
+	LST    = "lst"     // local standard time
+	LSTLDT = "lst_ldt" // local standard or daylight time
+)
+
+// Intervals between readings or predictions.
+const (
+	IntervalSixMinutes = "6"
+	IntervalHourly     = "h"
+	IntervalHighLow    = "hilo"      // predictions only
+	IntervalMaxSlack   = "MAX_SLACK" // current predictions only
+)
+
+// Processing levels of observations.
+const (
+	Preliminary = "p" // checked automatically
+	Verified    = "v" // checked by a person
+)
+
+// Dates for requests with no begin time.
+const (
+	DateLatest = "latest" // the most recent observation
+	DateRecent = "recent" // the last 72 hours
+	DateToday  = "today"
+)
+
+var (
+	// The CO-OPS Data API.
+	apiURL = "https://api.tidesandcurrents.noaa.gov/api/prod/datagetter"
+
+	// How we fetch it. Tests replace this.
+	requestBody = web.RequestBody
+)
+
+// Options narrow down a request. The zero value asks for the latest
+// observation (or today's predictions) in English units and GMT, with water
+// levels measured from MLLW.
+type Options struct {
+	Begin    time.Time // if set, the first time wanted
+	End      time.Time // if set with Begin, the last time wanted
+	Date     string    // used when Begin is not set
+	Datum    string
+	Units    string
+	TimeZone string
+	Interval string
+	Bin      string   // the depth bin of a current meter
+	Station  *Station // the station's metadata, for its time zone; needed for LST and LST_LDT
+}
+
+// location is where times in the request and response are. NOAA gives
+// local times in the time zone of the station.
+func (o Options) location() *time.Location {
+	switch {
+	case o.TimeZone == LST && o.Station != nil:
+		return o.Station.StandardTime()
+	case o.TimeZone == LSTLDT && o.Station != nil:
+		return o.Station.LocalTime()
+	}
+
+	return time.UTC
+}
+
+// withDefaults fills in what was not set.
+func (o Options) withDefaults(date string) Options {
+	if o.Date == "" {
+		o.Date = date
+	}
+	if o.Datum == "" {
+		o.Datum = DatumMLLW
+	}
+	if o.Units == "" {
+		o.Units = English
+	}
+	if o.TimeZone == "" {
+		o.TimeZone = GMT
+	}
+
+	return o
+}
+
+// Client talks to the NOAA CO-OPS Data API.
+type Client struct {
+	Application string // who is asking, which NOAA likes to know
+}
+
+// NewClient returns a client that identifies as this application.
+func NewClient() *Client {
+	return &Client{
+		Application: "erikbryantology@gmail.com",
+	}
+}
+
+// query builds the request URL.
+func (c *Client) query(station, product string, opts Options) string {
+	v := url.Values{}
+	v.Set("station", station)
+	v.Set("product", product)
+
+	if opts.Begin.IsZero() {
+		v.Set("date", opts.Date)
+	} else {
+		end := opts.End
+		if end.IsZero() {
+			end = time.Now()
+		}
+		v.Set("begin_date", opts.Begin.In(opts.location()).Format("20060102 15:04"))
+		v.Set("end_date", end.In(opts.location()).Format("20060102 15:04"))
+	}
+
+	v.Set("datum", opts.Datum)
+	v.Set("units", opts.Units)
+	v.Set("time_zone", opts.TimeZone)
+	if opts.Interval != "" {
+		v.Set("interval", opts.Interval)
+	}
+	if opts.Bin != "" {
+		v.Set("bin", opts.Bin)
+	}
+	v.Set("application", c.Application)
+	v.Set("format", "json")
+
+	return apiURL + "?" + v.Encode()
+}
+
+// ErrNoData is returned when NOAA has nothing for the station, product and
+// time asked for.
+var ErrNoData = errors.New("no data")
+
+// APIError is an error reported by NOAA.
+type APIError struct {
+	Station string
+	Product string
+	Message string
+}
+
+// Error returns NOAA's message.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("noaa %s %s: %s", e.Station, e.Product, e.Message)
+}
+
+// Is matches ErrNoData when NOAA says it has no data.
+func (e *APIError) Is(target error) bool {
+	return target == ErrNoData && strings.HasPrefix(e.Message, "No data was found")
+}
+
+// get fetches a product and decodes the response into response.
+func (c *Client) get(station, product string, opts Options, response interface{}) error {
+	if opts.TimeZone != GMT && opts.Station == nil {
+		return fmt.Errorf("noaa %s %s: %s times need the station's time zone", station, product, opts.TimeZone)
+	}
+
+	body, err := requestBody(c.query(station, product, opts), map[string]string{})
+	if err != nil {
+		return fmt.Errorf("noaa %s %s: %w", station, product, err)
+	}
+
+	return decode(station, product, []byte(body), response)
+}
+
+// decode reads a response, returning NOAA's error if it sent one instead.
+func decode(station, product string, body []byte, response interface{}) error {
+	var failure struct {
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	err := json.Unmarshal(body, &failure)
+	if err != nil {
+		return fmt.Errorf("noaa %s %s: %w", station, product, err)
+	}
+	if failure.Error != nil {
+		return &APIError{Station: station, Product: product, Message: failure.Error.Message}
+	}
+
+	err = json.Unmarshal(body, response)
+	if err != nil {
+		return fmt.Errorf("noaa %s %s: %w", station, product, err)
+	}
+
+	return nil
+}
+
+// parseTime reads a time as NOAA writes it.
+func parseTime(t string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02 15:04", t, loc)
+}
+
+// Flags are NOAA's automatic quality checks on an observation.
+type Flags struct {
+	Outliers     int  // 1 second samples more than 3 sigma from the mean, water levels only
+	Flat         bool // the samples stayed flat for too long, water levels only
+	RateOfChange bool // the value changed faster than it should
+	Limits       bool // the value is above or below what the station expects
+}
+
+// Suspect returns whether any check failed.
+func (f Flags) Suspect() bool {
+	return f.Outliers > 0 || f.Flat || f.RateOfChange || f.Limits
+}
+
+// parseFlags reads the "f" field. Water levels have four flags (outliers,
+// flat, rate of change, limits), meteorological products three (maximum,
+// minimum, rate of change) and wind two (maximum, rate of change).
+func parseFlags(f string) (Flags, error) {
+	var flags Flags
+
+	if f == "" {
+		return flags, nil
+	}
+
+	fields := strings.Split(f, ",")
+	values := make([]int, len(fields))
+	for i, field := range fields {
+		value, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return flags, fmt.Errorf("bad flags %q: %w", f, err)
+		}
+		values[i] = value
+	}
+
+	switch len(values) {
+	case 4:
+		flags.Outliers = values[0]
+		flags.Flat = values[1] != 0
+		flags.RateOfChange = values[2] != 0
+		flags.Limits = values[3] != 0
+	case 3:
+		flags.Limits = values[0] != 0 || values[1] != 0
+		flags.RateOfChange = values[2] != 0
+	case 2:
+		flags.Limits = values[0] != 0
+		flags.RateOfChange = values[1] != 0
+	default:
+		return flags, fmt.Errorf("bad flags %q: expected 2 to 4 of them", f)
+	}
+
+	return flags, nil
+}
+
+// Observation is one measurement of water level, air gap, temperature,
+// pressure or visibility.
+type Observation struct {
+	Time     time.Time
+	Value    float64
+	Raw      string // the value as NOAA sent it
+	Sigma    string // standard deviation of the 1 second samples, water level only
+	Flags    Flags
+	RawFlags string // the flags as NOAA sent them
+	Quality  string // Preliminary or Verified
+}
+
+// observationResponse is what NOAA sends for a product made of observations.
+type observationResponse struct {
+	Data []struct {
+		T string `json:"t"`
+		V string `json:"v"`
+		S string `json:"s"`
+		F string `json:"f"`
+		Q string `json:"q"`
+	} `json:"data"`
+}
+
+// observations fetches a product made of observations.
+func (c *Client) observations(station, product string, opts Options) ([]Observation, error) {
+	opts = opts.withDefaults(DateLatest)
+
+	var response observationResponse
+	err := c.get(station, product, opts, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.observations(station, product, opts.location())
+}
+
+// observations converts the response. Times NOAA has no value for are left out.
+func (r observationResponse) observations(station, product string, loc *time.Location) ([]Observation, error) {
+	var found []Observation
+
+	for _, d := range r.Data {
+		if d.V == "" {
+			continue
+		}
+		t, err := parseTime(d.T, loc)
+		if err != nil {
+			return nil, fmt.Errorf("noaa %s %s: %w", station, product, err)
+		}
+		value, err := strconv.ParseFloat(d.V, 64)
+		if err != nil {
+			return nil, fmt.Errorf("noaa %s %s: %w", station, product, err)
+		}
+		flags, err := parseFlags(d.F)
+		if err != nil {
+			return nil, fmt.Errorf("noaa %s %s: %w", station, product, err)
+		}
+		found = append(found, Observation{Time: t, Value: value, Raw: d.V, Sigma: d.S, Flags: flags, RawFlags: d.F, Quality: d.Q})
+	}
+
+	return found, nil
+}
+
+// WaterLevel returns observed water levels.
+func (c *Client) WaterLevel(station string, opts Options) ([]Observation, error) {
+	return c.observations(station, ProductWaterLevel, opts)
+}
+
+// AirGap returns observed air gaps (distance from bottom of bridge to water).
+func (c *Client) AirGap(station string, opts Options) ([]Observation, error) {
+	return c.observations(station, ProductAirGap, opts)
+}
+
+// AirTemperature returns observed air temperatures.
+func (c *Client) AirTemperature(station string, opts Options) ([]Observation, error) {
+	return c.observations(station, ProductAirTemperature, opts)
+}
+
+// WaterTemperature returns observed water temperatures.
+func (c *Client) WaterTemperature(station string, opts Options) ([]Observation, error) {
+	return c.observations(station, ProductWaterTemperature, opts)
+}
+
+// AirPressure returns observed barometric pressures, in millibars.
+func (c *Client) AirPressure(station string, opts Options) ([]Observation, error) {
+	return c.observations(station, ProductAirPressure, opts)
+}
+
+// Visibility returns observed visibilities.
+func (c *Client) Visibility(station string, opts Options) ([]Observation, error) {
+	return c.observations(station, ProductVisibility, opts)
+}
+
+// Prediction is a predicted water level. Type is "H" or "L" for high and
+// low tide predictions and empty otherwise.
+type Prediction struct {
+	Time  time.Time
+	Value float64
+	Type  string
+}
+
+// Predictions returns predicted water levels.
+func (c *Client) Predictions(station string, opts Options) ([]Prediction, error) {
+	opts = opts.withDefaults(DateToday)
+
+	var response struct {
+		Predictions []struct {
+			T    string `json:"t"`
+			V    string `json:"v"`
+			Type string `json:"type"`
+		} `json:"predictions"`
+	}
+	err := c.get(station, ProductPredictions, opts, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Prediction
+	for _, p := range response.Predictions {
+		if p.V == "" {
+			continue
+		}
+		t, err := parseTime(p.T, opts.location())
+		if err != nil {
+			return nil, fmt.Errorf("noaa %s %s: %w", station, ProductPredictions, err)
+		}
+		value, err := strconv.ParseFloat(p.V, 64)
+		if err != nil {
+			return nil, fmt.Errorf("noaa %s %s: %w", station, ProductPredictions, err)
+		}
+		found = append(found, Prediction{Time: t, Value: value, Type: p.Type})
+	}
+
+	return found, nil
+}
+
+// HighLow returns the predicted high and low tides.
+func (c *Client) HighLow(station string, opts Options) ([]Prediction, error) {
+	opts.Interval = IntervalHighLow
+	return c.Predictions(station, opts)
+}
+
+// Wind is one wind observation. Speeds are in knots (English) or m/s
+// (Metric); the direction is where the wind blows from.
+type Wind struct {
+	Time      time.Time
+	Speed     float64
+	Direction float64 // degrees true
+	Compass   string  // "NW"
+	Gust      float64
+	Flags     Flags
+}
+
+// Wind returns observed winds.
+func (c *Client) Wind(station string, opts Options) ([]Wind, error) {
+	opts = opts.withDefaults(DateLatest)
+
+	var response struct {
+		Data []struct {
+			T  string `json:"t"`
+			S  string `json:"s"`
+			D  string `json:"d"`
+			Dr string `json:"dr"`
+			G  string `json:"g"`
+			F  string `json:"f"`
+		} `json:"data"`
+	}
+	err := c.get(station, ProductWind, opts, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Wind
+	for _, d := range response.Data {
+		if d.S == "" {
+			continue
+		}
+		w := Wind{Compass: d.Dr}
+		w.Time, err = parseTime(d.T, opts.location())
+		if err == nil {
+			w.Speed, err = strconv.ParseFloat(d.S, 64)
+		}
+		if err == nil {
+			w.Direction, err = strconv.ParseFloat(d.D, 64)
+		}
+		if err == nil && d.G != "" {
+			w.Gust, err = strconv.ParseFloat(d.G, 64)
+		}
+		if err == nil {
+			w.Flags, err = parseFlags(d.F)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("noaa %s %s: %w", station, ProductWind, err)
+		}
+		found = append(found, w)
+	}
+
+	return found, nil
+}
+
+// Current is one observed current. Speeds are in knots (English) or cm/s
+// (Metric); the direction is where the water flows to.
+type Current struct {
+	Time      time.Time
+	Speed     float64
+	Direction float64 // degrees true
+	Bin       string
+}
+
+// Currents returns observed currents.
+func (c *Client) Currents(station string, opts Options) ([]Current, error) {
+	opts = opts.withDefaults(DateLatest)
+
+	var response struct {
+		Data []struct {
+			T string `json:"t"`
+			S string `json:"s"`
+			D string `json:"d"`
+			B string `json:"b"`
+		} `json:"data"`
+	}
+	err := c.get(station, ProductCurrents, opts, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Current
+	for _, d := range response.Data {
+		if d.S == "" {
+			continue
+		}
+		current := Current{Bin: d.B}
+		current.Time, err = parseTime(d.T, opts.location())
+		if err == nil {
+			current.Speed, err = strconv.ParseFloat(d.S, 64)
+		}
+		if err == nil {
+			current.Direction, err = strconv.ParseFloat(d.D, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("noaa %s %s: %w", station, ProductCurrents, err)
+		}
+		found = append(found, current)
+	}
+
+	return found, nil
+}
+
+// CurrentPrediction is a predicted current along the channel. Velocity is
+// positive on the flood and negative on the ebb. With IntervalMaxSlack,
+// Type says whether this is the peak "flood", peak "ebb" or "slack".
+type CurrentPrediction struct {
+	Time      time.Time
+	Velocity  float64
+	FloodDir  float64 // degrees true
+	EbbDir    float64 // degrees true
+	Direction float64 // degrees true, set instead of FloodDir and EbbDir at some stations
+	Type      string
+	Bin       string
+}
+
+// CurrentPredictions returns predicted currents.
+func (c *Client) CurrentPredictions(station string, opts Options) ([]CurrentPrediction, error) {
+	opts = opts.withDefaults(DateToday)
+
+	var response struct {
+		CurrentPredictions struct {
+			Cp []struct {
+				Time          string  `json:"Time"`
+				VelocityMajor float64 `json:"Velocity_Major"`
+				MeanFloodDir  float64 `json:"meanFloodDir"`
+				MeanEbbDir    float64 `json:"meanEbbDir"`
+				Direction     float64 `json:"Direction"`
+				Type          string  `json:"Type"`
+				Bin           string  `json:"Bin"`
+			} `json:"cp"`
+		} `json:"current_predictions"`
+	}
+	err := c.get(station, ProductCurrentPredictions, opts, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []CurrentPrediction
+	for _, p := range response.CurrentPredictions.Cp {
+		t, err := parseTime(p.Time, opts.location())
+		if err != nil {
+			return nil, fmt.Errorf("noaa %s %s: %w", station, ProductCurrentPredictions, err)
+		}
+		found = append(found, CurrentPrediction{
+			Time:      t,
+			Velocity:  p.VelocityMajor,
+			FloodDir:  p.MeanFloodDir,
+			EbbDir:    p.MeanEbbDir,
+			Direction: p.Direction,
+			Type:      p.Type,
+			Bin:       p.Bin,
+		})
+	}
+
+	return found, nil
+}
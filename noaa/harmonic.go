@@ -0,0 +1,365 @@
+package noaa
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The Metadata API page for one station.
+var stationURL = "https://api.tidesandcurrents.noaa.gov/mdapi/prod/webapi/stations/"
+
+// Constituent is one of the tidal harmonics published for a station.
+type Constituent struct {
+	Name      string  `json:"name"`
+	Amplitude float64 `json:"amplitude"` // feet
+	Phase     float64 `json:"phase_GMT"` // degrees, Greenwich epoch
+	Speed     float64 `json:"speed"`     // degrees per hour
+}
+
+// Harmonics are what is needed to predict the tide at a station.
+type Harmonics struct {
+	Station      string        `json:"station"`
+	MSL          float64       `json:"msl"` // height of mean sea level above MLLW, feet
+	Constituents []Constituent `json:"constituents"`
+}
+
+// doodson gives the equilibrium argument of a constituent as multiples of
+// the mean lunar time τ and the mean longitudes of the moon (s), sun (h),
+// lunar perigee (p), negated lunar node (N') and solar perigee (p1), plus a
+// phase in degrees. nodal returns its node factor f and correction u
+// (degrees) for a longitude of the moon's node N.
+type doodson struct {
+	τ, s, h, p, n, p1 float64
+	phase             float64
+	nodal             func(N float64) (f, u float64)
+}
+
+// Node factors and corrections, after Schureman (1958).
+func nodalNone(N float64) (float64, float64) { return 1, 0 }
+
+func nodalM2(N float64) (float64, float64) {
+	return 1.0004 - 0.0373*cosd(N) + 0.0002*cosd(2*N), -2.14 * sind(N)
+}
+
+func nodalK1(N float64) (float64, float64) {
+	return 1.0060 + 0.1150*cosd(N) - 0.0088*cosd(2*N) + 0.0006*cosd(3*N),
+		-8.86*sind(N) + 0.68*sind(2*N) - 0.07*sind(3*N)
+}
+
+func nodalO1(N float64) (float64, float64) {
+	return 1.0089 + 0.1871*cosd(N) - 0.0147*cosd(2*N) + 0.0014*cosd(3*N),
+		10.80*sind(N) - 1.34*sind(2*N) + 0.19*sind(3*N)
+}
+
+func nodalJ1(N float64) (float64, float64) {
+	return 1.0129 + 0.1676*cosd(N) - 0.0170*cosd(2*N) + 0.0016*cosd(3*N),
+		-12.94*sind(N) + 1.34*sind(2*N) - 0.19*sind(3*N)
+}
+
+func nodalOO1(N float64) (float64, float64) {
+	return 1.1027 + 0.6504*cosd(N) + 0.0317*cosd(2*N) - 0.0014*cosd(3*N),
+		-36.68*sind(N) + 4.02*sind(2*N) - 0.57*sind(3*N)
+}
+
+func nodalK2(N float64) (float64, float64) {
+	return 1.0241 + 0.2863*cosd(N) + 0.0083*cosd(2*N) - 0.0015*cosd(3*N),
+		-17.74*sind(N) + 0.68*sind(2*N) - 0.04*sind(3*N)
+}
+
+func nodalMm(N float64) (float64, float64) {
+	return 1.0000 - 0.1300*cosd(N) + 0.0013*cosd(2*N), 0
+}
+
+func nodalMf(N float64) (float64, float64) {
+	return 1.0429 + 0.4135*cosd(N) - 0.0040*cosd(2*N),
+		-23.74*sind(N) + 2.68*sind(2*N) - 0.38*sind(3*N)
+}
+
+// nodalPower is for compound tides: M2 raised to a power, times K1 raised to another.
+func nodalPower(m2, k1 float64) func(N float64) (float64, float64) {
+	return func(N float64) (float64, float64) {
+		fM, uM := nodalM2(N)
+		fK, uK := nodalK1(N)
+		return math.Pow(fM, math.Abs(m2)) * math.Pow(fK, math.Abs(k1)), m2*uM + k1*uK
+	}
+}
+
+// The constituents NOAA publishes, by name.
+var constituents = map[string]doodson{
+	"M2":   {2, 0, 0, 0, 0, 0, 0, nodalM2},
+	"S2":   {2, 2, -2, 0, 0, 0, 0, nodalNone},
+	"N2":   {2, -1, 0, 1, 0, 0, 0, nodalM2},
+	"K1":   {1, 1, 0, 0, 0, 0, -90, nodalK1},
+	"M4":   {4, 0, 0, 0, 0, 0, 0, nodalPower(2, 0)},
+	"O1":   {1, -1, 0, 0, 0, 0, 90, nodalO1},
+	"M6":   {6, 0, 0, 0, 0, 0, 0, nodalPower(3, 0)},
+	"MK3":  {3, 1, 0, 0, 0, 0, -90, nodalPower(1, 1)},
+	"S4":   {4, 4, -4, 0, 0, 0, 0, nodalNone},
+	"MN4":  {4, -1, 0, 1, 0, 0, 0, nodalPower(2, 0)},
+	"NU2":  {2, -1, 2, -1, 0, 0, 0, nodalM2},
+	"S6":   {6, 6, -6, 0, 0, 0, 0, nodalNone},
+	"MU2":  {2, -2, 2, 0, 0, 0, 0, nodalM2},
+	"2N2":  {2, -2, 0, 2, 0, 0, 0, nodalM2},
+	"OO1":  {1, 3, 0, 0, 0, 0, -90, nodalOO1},
+	"LAM2": {2, 1, -2, 1, 0, 0, 180, nodalM2},
+	"S1":   {1, 1, -1, 0, 0, 0, 180, nodalNone},
+	"M1":   {1, 0, 0, 0, 0, 0, -90, nodalO1},
+	"J1":   {1, 2, 0, -1, 0, 0, -90, nodalJ1},
+	"MM":   {0, 1, 0, -1, 0, 0, 0, nodalMm},
+	"SSA":  {0, 0, 2, 0, 0, 0, 0, nodalNone},
+	"SA":   {0, 0, 1, 0, 0, 0, 0, nodalNone},
+	"MSF":  {0, 2, -2, 0, 0, 0, 0, nodalPower(-1, 0)},
+	"MF":   {0, 2, 0, 0, 0, 0, 0, nodalMf},
+	"RHO":  {1, -2, 2, -1, 0, 0, 90, nodalO1},
+	"Q1":   {1, -2, 0, 1, 0, 0, 90, nodalO1},
+	"T2":   {2, 2, -3, 0, 0, 1, 0, nodalNone},
+	"R2":   {2, 2, -1, 0, 0, -1, 180, nodalNone},
+	"2Q1":  {1, -3, 0, 2, 0, 0, 90, nodalO1},
+	"P1":   {1, 1, -2, 0, 0, 0, 90, nodalNone},
+	"2SM2": {2, 4, -4, 0, 0, 0, 0, nodalPower(-1, 0)},
+	"M3":   {3, 0, 0, 0, 0, 0, 180, nodalPower(1.5, 0)},
+	"L2":   {2, 1, 0, -1, 0, 0, 180, nodalM2},
+	"2MK3": {3, -1, 0, 0, 0, 0, 90, nodalPower(2, -1)},
+	"K2":   {2, 2, 0, 0, 0, 0, 0, nodalK2},
+	"M8":   {8, 0, 0, 0, 0, 0, 0, nodalPower(4, 0)},
+	"MS4":  {4, 2, -2, 0, 0, 0, 0, nodalM2},
+}
+
+func sind(degrees float64) float64 { return math.Sin(degrees * math.Pi / 180) }
+func cosd(degrees float64) float64 { return math.Cos(degrees * math.Pi / 180) }
+
+// astronomy returns the mean lunar time and the mean longitudes (degrees)
+// of the moon, sun, lunar perigee, lunar node and solar perigee at a time.
+func astronomy(t time.Time) (τ, s, h, p, N, p1 float64) {
+	j2000 := time.Date(2000, time.January, 1, 12, 0, 0, 0, time.UTC)
+	days := t.Sub(j2000).Hours() / 24
+	T := days / 36525
+
+	s = 218.3164477 + 481267.88123421*T
+	h = 280.46646 + 36000.76983*T
+	p = 83.3532465 + 4069.0137287*T
+	N = 125.04452 - 1934.136261*T
+	p1 = 282.93735 + 1.71946*T
+
+	// The hour angle of the mean sun is 180° at midnight UTC.
+	utc := t.UTC()
+	hours := utc.Sub(time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.UTC)).Hours()
+	τ = 180 + 15*hours + h - s
+
+	return τ, s, h, p, N, p1
+}
+
+// argument returns the equilibrium argument V of a constituent at a time.
+func (d doodson) argument(t time.Time) float64 {
+	τ, s, h, p, N, p1 := astronomy(t)
+	return d.τ*τ + d.s*s + d.h*h + d.p*p - d.n*N + d.p1*p1 + d.phase
+}
+
+// term is a constituent ready to be summed: amplitude × cos(speed × hours + phase).
+type term struct {
+	amplitude float64
+	phase     float64
+	speed     float64
+}
+
+// terms works out the constituents for a year, taking their arguments
+// from the start of the year and their nodal corrections from the middle
+// of it, as NOAA does. Constituents we do not know are left out.
+func (h *Harmonics) terms(year int) []term {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	_, _, _, _, N, _ := astronomy(time.Date(year, time.July, 2, 12, 0, 0, 0, time.UTC))
+
+	var found []term
+	for _, c := range h.Constituents {
+		d, ok := constituents[strings.ToUpper(c.Name)]
+		if !ok || c.Amplitude == 0 {
+			continue
+		}
+		f, u := d.nodal(N)
+		found = append(found, term{
+			amplitude: f * c.Amplitude,
+			phase:     d.argument(start) + u - c.Phase,
+			speed:     c.Speed,
+		})
+	}
+
+	return found
+}
+
+// level sums the terms at a time given as hours since the start of their year.
+func level(msl float64, terms []term, hours float64) float64 {
+	height := msl
+	for _, t := range terms {
+		height += t.amplitude * cosd(t.speed*hours+t.phase)
+	}
+
+	return height
+}
+
+// hoursIntoYear returns how far into its UTC year a time is.
+func hoursIntoYear(t time.Time) float64 {
+	return t.Sub(time.Date(t.UTC().Year(), time.January, 1, 0, 0, 0, 0, time.UTC)).Hours()
+}
+
+// Predict returns the predicted water level above MLLW, in feet, at a time.
+func (h *Harmonics) Predict(t time.Time) float64 {
+	return level(h.MSL, h.terms(t.UTC().Year()), hoursIntoYear(t))
+}
+
+// Predictions returns the predicted water levels from one time to another, a step apart.
+func (h *Harmonics) Predictions(from, to time.Time, step time.Duration) []Prediction {
+	var found []Prediction
+
+	year := 0
+	var terms []term
+	for t := from; !t.After(to); t = t.Add(step) {
+		if t.UTC().Year() != year {
+			year = t.UTC().Year()
+			terms = h.terms(year)
+		}
+		found = append(found, Prediction{Time: t, Value: level(h.MSL, terms, hoursIntoYear(t))})
+	}
+
+	return found
+}
+
+// HighLow returns the predicted high ("H") and low ("L") tides between two times, to the minute.
+func (h *Harmonics) HighLow(from, to time.Time) []Prediction {
+	var found []Prediction
+
+	levels := h.Predictions(from.Add(-time.Minute), to.Add(time.Minute), time.Minute)
+	for i := 1; i < len(levels)-1; i++ {
+		before, now, after := levels[i-1].Value, levels[i].Value, levels[i+1].Value
+		switch {
+		case now > before && now >= after:
+			found = append(found, Prediction{Time: levels[i].Time, Value: now, Type: "H"})
+		case now < before && now <= after:
+			found = append(found, Prediction{Time: levels[i].Time, Value: now, Type: "L"})
+		}
+	}
+
+	return found
+}
+
+// Compare returns how far the harmonic predictions are from another set of
+// predictions (NOAA's, say): the root mean square and the largest
+// difference, in feet.
+func (h *Harmonics) Compare(predictions []Prediction) (rms, worst float64) {
+	if len(predictions) == 0 {
+		return 0, 0
+	}
+
+	sum := 0.0
+	for _, p := range predictions {
+		diff := math.Abs(h.Predict(p.Time) - p.Value)
+		sum += diff * diff
+		worst = math.Max(worst, diff)
+	}
+
+	return math.Sqrt(sum / float64(len(predictions))), worst
+}
+
+// harmonicsFile is where a station's harmonics are kept on disk.
+func (s *Stations) harmonicsFile(station string) string {
+	return filepath.Join(s.dir, "noaa-harmonics-"+station+".json")
+}
+
+// fetchHarmonics gets a station's harmonic constituents and datums from NOAA.
+func fetchHarmonics(station string) (*Harmonics, error) {
+	h := &Harmonics{Station: station}
+
+	body, err := requestBody(stationURL+station+"/harcon.json?units=english", map[string]string{})
+	if err != nil {
+		return nil, fmt.Errorf("noaa harmonics %s: %w", station, err)
+	}
+	var harcon struct {
+		HarmonicConstituents []Constituent `json:"HarmonicConstituents"`
+	}
+	err = decode(station, "harcon", []byte(body), &harcon)
+	if err != nil {
+		return nil, err
+	}
+	h.Constituents = harcon.HarmonicConstituents
+
+	body, err = requestBody(stationURL+station+"/datums.json?units=english", map[string]string{})
+	if err != nil {
+		return nil, fmt.Errorf("noaa datums %s: %w", station, err)
+	}
+	var datums struct {
+		Datums []struct {
+			Name  string  `json:"name"`
+			Value float64 `json:"value"`
+		} `json:"datums"`
+	}
+	err = decode(station, "datums", []byte(body), &datums)
+	if err != nil {
+		return nil, err
+	}
+	levels := map[string]float64{}
+	for _, d := range datums.Datums {
+		levels[d.Name] = d.Value
+	}
+	msl, okMSL := levels["MSL"]
+	mllw, okMLLW := levels["MLLW"]
+	if !okMSL || !okMLLW {
+		return nil, fmt.Errorf("noaa datums %s: no MSL or MLLW", station)
+	}
+	h.MSL = msl - mllw
+
+	if len(h.Constituents) == 0 {
+		return nil, fmt.Errorf("noaa harmonics %s: %w", station, ErrNoData)
+	}
+
+	return h, nil
+}
+
+// Harmonics returns the harmonic constituents of a station. Once fetched
+// they are kept in memory and on disk (if there is a cache directory) and
+// never fetched again, so predictions work without NOAA.
+func (s *Stations) Harmonics(station string) (*Harmonics, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if h, ok := s.harmonics[station]; ok {
+		return h, nil
+	}
+
+	if s.dir != "" {
+		contents, err := os.ReadFile(s.harmonicsFile(station))
+		if err == nil {
+			var h Harmonics
+			err = json.Unmarshal(contents, &h)
+			if err == nil {
+				s.harmonics[station] = &h
+				return &h, nil
+			}
+			fmt.Println("Ignoring cached harmonics:", err)
+		}
+	}
+
+	h, err := fetchHarmonics(station)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.dir != "" {
+		contents, err := json.Marshal(h)
+		if err == nil {
+			err = os.MkdirAll(s.dir, 0755)
+		}
+		if err == nil {
+			err = os.WriteFile(s.harmonicsFile(station), contents, 0644)
+		}
+		if err != nil {
+			fmt.Println("Unable to cache NOAA harmonics:", err)
+		}
+	}
+	s.harmonics[station] = h
+
+	return h, nil
+}
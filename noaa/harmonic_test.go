@@ -0,0 +1,216 @@
+package noaa
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeHarmonics serves the recorded constituents and datums and counts the requests.
+func fakeHarmonics(t *testing.T, up *bool) *int {
+	requests := new(int)
+
+	saved := requestBody
+	requestBody = func(u string, headers map[string]string) (string, error) {
+		*requests++
+		switch {
+		case !*up:
+			return "", fmt.Errorf("no response")
+		case strings.Contains(u, "/harcon.json"):
+			return recorded(t, "harcon.json"), nil
+		case strings.Contains(u, "/datums.json"):
+			return recorded(t, "datums.json"), nil
+		}
+		return "", fmt.Errorf("unexpected request %s", u)
+	}
+	t.Cleanup(func() { requestBody = saved })
+
+	return requests
+}
+
+func TestHarmonicsCache(t *testing.T) {
+	up := true
+	requests := fakeHarmonics(t, &up)
+	dir := t.TempDir()
+
+	h, err := NewStations(dir).Harmonics("9414290")
+	if err != nil || len(h.Constituents) == 0 || math.Abs(h.MSL-3.30) > 0.001 {
+		t.Fatalf("ERROR: Expected constituents and MSL 3.30 ft above MLLW, got %v %v", h, err)
+	}
+
+	// Once cached, NOAA is not needed.
+	up = false
+	cached, err := NewStations(dir).Harmonics("9414290")
+	if err != nil || len(cached.Constituents) != len(h.Constituents) || *requests != 2 {
+		t.Errorf("ERROR: Expected the cached harmonics, got %v %v after %d requests", cached, err, *requests)
+	}
+
+	_, err = NewStations(dir).Harmonics("9414304")
+	if err == nil {
+		t.Errorf("ERROR: Expected an error with nothing cached and NOAA down")
+	}
+}
+
+// reference predicts the tide from the full equilibrium argument at each
+// time, rather than from the argument at the start of the year plus the
+// constituent speeds as Predict does. It shares the constituent table
+// with Predict, so it checks the arithmetic, not the table; that is
+// TestPredictNOAA's job.
+func reference(h *Harmonics, at time.Time) float64 {
+	_, _, _, _, N, _ := astronomy(time.Date(at.UTC().Year(), time.July, 2, 12, 0, 0, 0, time.UTC))
+
+	height := h.MSL
+	for _, c := range h.Constituents {
+		d, ok := constituents[c.Name]
+		if !ok {
+			continue
+		}
+		f, u := d.nodal(N)
+		height += f * c.Amplitude * cosd(d.argument(at)+u-c.Phase)
+	}
+
+	return height
+}
+
+func TestPredict(t *testing.T) {
+	up := true
+	fakeHarmonics(t, &up)
+
+	h, err := NewStations("").Harmonics("9414290")
+	if err != nil {
+		t.Fatalf("ERROR: %v", err)
+	}
+
+	// A month either side of a new year, when the terms are worked out afresh.
+	from := time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC)
+
+	var expected []Prediction
+	for at := from; at.Before(to); at = at.Add(time.Hour) {
+		expected = append(expected, Prediction{Time: at, Value: reference(h, at)})
+	}
+
+	rms, worst := h.Compare(expected)
+	if rms > 0.005 || worst > 0.02 {
+		t.Errorf("ERROR: Expected predictions within 0.02 ft of the reference, got rms %.4f worst %.4f", rms, worst)
+	}
+
+	predictions := h.Predictions(from, to, 6*time.Minute)
+	for _, p := range predictions {
+		if p.Value < -3 || p.Value > 9 {
+			t.Errorf("ERROR: Expected tides between -3 and 9 ft, got %v", p)
+			break
+		}
+	}
+
+	// The bay has mixed semidiurnal tides; most days have two highs and two lows.
+	tides := h.HighLow(from, from.Add(7*24*time.Hour))
+	if len(tides) < 22 || len(tides) > 28 {
+		t.Errorf("ERROR: Expected about 4 tides a day for a week, got %d", len(tides))
+	}
+	for i := 1; i < len(tides); i++ {
+		if tides[i].Type == tides[i-1].Type {
+			t.Errorf("ERROR: Expected highs and lows to alternate, got %v then %v", tides[i-1], tides[i])
+		}
+	}
+}
+
+// TestPredictNOAA checks Predict, from the station's full set of
+// constituents, against the heights NOAA publishes for it. Both are
+// recorded with "make noaa-testdata".
+func TestPredictNOAA(t *testing.T) {
+	name := "predictions_9414290.json"
+	_, err := os.Stat(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("ERROR: %s is not recorded; run make noaa-testdata", name)
+	}
+
+	up := true
+	fakeHarmonics(t, &up)
+	h, err := NewStations("").Harmonics("9414290")
+	if err != nil {
+		t.Fatalf("ERROR: %v", err)
+	}
+
+	fake(t, recorded(t, name))
+	from := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	expected, err := NewClient().Predictions("9414290", Options{Begin: from, End: from.Add(72 * time.Hour), Interval: IntervalHourly})
+	if err != nil || len(expected) == 0 {
+		t.Fatalf("ERROR: Expected NOAA's predictions, got %v %v", expected, err)
+	}
+
+	if len(h.Constituents) < len(constituents) {
+		t.Errorf("ERROR: Expected all %d of NOAA's constituents in harcon.json, got %d; run make noaa-testdata", len(constituents), len(h.Constituents))
+	}
+
+	// Every height within 0.3 ft of NOAA's.
+	rms, worst := h.Compare(expected)
+	if worst > 0.3 {
+		t.Errorf("ERROR: Expected predictions within 0.3 ft of NOAA's, got rms %.3f worst %.3f", rms, worst)
+	}
+}
+
+func TestArguments(t *testing.T) {
+	at := time.Date(2025, time.March, 14, 7, 30, 0, 0, time.UTC)
+	_, s, h, p, _, _ := astronomy(at)
+	// Schureman's arguments are in terms of the hour angle of the mean sun
+	// T, rather than mean lunar time.
+	T := 180 + 15*(7.5)
+
+	testCases := []struct {
+		name     string
+		expected float64
+	}{
+		{"M2", 2*T - 2*s + 2*h},
+		{"S2", 2 * T},
+		{"N2", 2*T - 3*s + 2*h + p},
+		{"K2", 2*T + 2*h},
+		{"K1", T + h - 90},
+		{"O1", T - 2*s + h + 90},
+		{"P1", T - h + 90},
+		{"Q1", T - 3*s + h + p + 90},
+		{"2Q1", T - 4*s + h + 2*p + 90},
+		{"RHO", T - 3*s + 3*h - p + 90},
+		{"J1", T + s + h - p - 90},
+		{"OO1", T + 2*s + h - 90},
+		{"MK3", 3*T - 2*s + 3*h - 90},
+		{"2MK3", 3*T - 4*s + 3*h + 90},
+	}
+
+	for _, testCase := range testCases {
+		answer := constituents[testCase.name].argument(at)
+		diff := math.Mod(math.Mod(answer-testCase.expected, 360)+540, 360) - 180
+		if math.Abs(diff) > 0.001 {
+			t.Errorf("ERROR: For %s expected V=%.3f, got %.3f", testCase.name, math.Mod(testCase.expected, 360), math.Mod(answer, 360))
+		}
+	}
+}
+
+func TestNodal(t *testing.T) {
+	testCases := []struct {
+		name string
+		N    float64
+		f    float64
+		u    float64
+	}{
+		{"M2", 0, 0.9633, 0},
+		{"M2", 180, 1.0379, 0},
+		{"M2", 90, 1.0002, -2.14},
+		{"K1", 0, 1.1128, 0},
+		{"O1", 0, 1.1827, 0},
+		{"S2", 90, 1, 0},
+		{"M4", 0, 0.9279, 0},
+		{"MSF", 90, 1.0002, 2.14},
+	}
+
+	for _, testCase := range testCases {
+		f, u := constituents[testCase.name].nodal(testCase.N)
+		if math.Abs(f-testCase.f) > 0.0001 || math.Abs(u-testCase.u) > 0.01 {
+			t.Errorf("ERROR: For %s at N=%.0f expected f=%.4f u=%.2f, got %.4f %.2f", testCase.name, testCase.N, testCase.f, testCase.u, f, u)
+		}
+	}
+}
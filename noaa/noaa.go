@@ -4,43 +4,44 @@ import (
 	"fmt"
 
 	"github.com/erikbryant/shipahoy/database"
-	"github.com/erikbryant/web"
 )
 
-// noaaReading reads one datum from a given NOAA station.
-func noaaReading(station, product, datum string) (database.NoaaDatum, bool) {
+// latest reads the most recent observation of a product from a given NOAA station.
+func latest(station, product string) (database.NoaaDatum, error) {
 	reading := database.NoaaDatum{
 		Station: station,
 		Product: product,
-		Datum:   datum,
+		Datum:   DatumMLLW,
 	}
-	url := "https://tidesandcurrents.noaa.gov/api/datagetter?date=latest&station=" + reading.Station + "&product=" + reading.Product + "&datum=" + reading.Datum + "&units=english&time_zone=lst_ldt&application=erikbryantology@gmail.com&format=json"
 
-	response, err := web.RequestJSON(url, map[string]string{})
+	found, err := NewClient().observations(station, product, Options{Datum: reading.Datum})
 	if err != nil {
-		fmt.Println("Error getting", reading, err, response)
-		return reading, false
+		return reading, err
 	}
-
-	if response["error"] != nil {
-		fmt.Println("Error reading", reading, response["error"])
-		return reading, false
+	if len(found) == 0 {
+		return reading, fmt.Errorf("noaa %s %s: %w", station, product, ErrNoData)
 	}
 
-	data := response["data"].([]interface{})[0].(map[string]interface{})
-	reading.Value = data["v"].(string)
-	reading.S = data["s"].(string)
-	reading.Flags = data["f"].(string)
+	return datum(reading, found[len(found)-1]), nil
+}
+
+// datum fills in a reading from an observation.
+func datum(reading database.NoaaDatum, o Observation) database.NoaaDatum {
+	reading.Value = o.Raw
+	reading.S = o.Sigma
+	reading.Flags = o.RawFlags
+	reading.ProcessingLevel = o.Quality
+	reading.Timestamp = o.Time.Unix()
 
-	return reading, true
+	return reading
 }
 
 // Tides looks up instantaneous tide data for a given NOAA station.
-func Tides(station string) (database.NoaaDatum, bool) {
-	return noaaReading(station, "water_level", "mllw")
+func Tides(station string) (database.NoaaDatum, error) {
+	return latest(station, ProductWaterLevel)
 }
 
 // AirGap looks up instantaneous air gap (distance from bottom of bridge to water) for a given NOAA station.
-func AirGap(station string) (database.NoaaDatum, bool) {
-	return noaaReading(station, "air_gap", "mllw")
+func AirGap(station string) (database.NoaaDatum, error) {
+	return latest(station, ProductAirGap)
 }
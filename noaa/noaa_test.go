@@ -1,3 +1,254 @@
 package noaa
 
-// TODO: write tests
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	_ "time/tzdata" // for station time zones wherever the tests run
+)
+
+// fake serves canned responses in place of the NOAA API and remembers what was asked for.
+func fake(t *testing.T, body string) *url.Values {
+	asked := &url.Values{}
+
+	saved := requestBody
+	requestBody = func(u string, headers map[string]string) (string, error) {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return "", err
+		}
+		*asked = parsed.Query()
+		if body == "" {
+			return "", fmt.Errorf("no response")
+		}
+		return body, nil
+	}
+	t.Cleanup(func() { requestBody = saved })
+
+	return asked
+}
+
+func TestQuery(t *testing.T) {
+	c := NewClient()
+	begin := time.Date(2024, time.March, 1, 8, 0, 0, 0, time.UTC)
+	end := begin.Add(24 * time.Hour)
+
+	testCases := []struct {
+		product  string
+		opts     Options
+		expected map[string]string
+	}{
+		{ProductWaterLevel, Options{}.withDefaults(DateLatest), map[string]string{"date": "latest", "datum": "mllw", "units": "english", "time_zone": "gmt", "interval": ""}},
+		{ProductPredictions, Options{Begin: begin, End: end, Interval: IntervalHighLow}.withDefaults(DateToday), map[string]string{"begin_date": "20240301 08:00", "end_date": "20240302 08:00", "date": "", "interval": "hilo"}},
+		{ProductCurrentPredictions, Options{Units: Metric, Bin: "13"}.withDefaults(DateToday), map[string]string{"date": "today", "units": "metric", "bin": "13"}},
+	}
+
+	for _, testCase := range testCases {
+		u, err := url.Parse(c.query("9414290", testCase.product, testCase.opts))
+		if err != nil {
+			t.Fatalf("ERROR: %v", err)
+		}
+		q := u.Query()
+		if q.Get("station") != "9414290" || q.Get("product") != testCase.product || q.Get("format") != "json" {
+			t.Errorf("ERROR: Expected station, product and format in %s", u)
+		}
+		for key, value := range testCase.expected {
+			if q.Get(key) != value {
+				t.Errorf("ERROR: For %s expected %s=%q, got %q", testCase.product, key, value, q.Get(key))
+			}
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	c := NewClient()
+
+	fake(t, `{"metadata":{"id":"9414290","name":"San Francisco","lat":"37.8063","lon":"-122.4659"},
+		"data":[{"t":"2024-03-01 08:00","v":"3.281","s":"0.066","f":"0,0,0,0","q":"p"}]}`)
+	levels, err := c.WaterLevel("9414290", Options{})
+	if err != nil || len(levels) != 1 || levels[0].Value != 3.281 || levels[0].Quality != "p" || !levels[0].Time.Equal(time.Date(2024, time.March, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("ERROR: Expected one water level, got %v %v", levels, err)
+	}
+
+	asked := fake(t, `{"predictions":[{"t":"2024-03-01 03:12","v":"5.811","type":"H"},{"t":"2024-03-01 09:40","v":"0.402","type":"L"}]}`)
+	tides, err := c.HighLow("9414290", Options{})
+	if err != nil || len(tides) != 2 || tides[0].Type != "H" || tides[1].Value != 0.402 || asked.Get("interval") != IntervalHighLow {
+		t.Errorf("ERROR: Expected a high and a low tide, got %v %v", tides, err)
+	}
+
+	fake(t, `{"data":[{"t":"2024-03-01 08:00","s":"12.44","d":"284.00","dr":"WNW","g":"17.30","f":"0,0"}]}`)
+	winds, err := c.Wind("9414290", Options{})
+	if err != nil || len(winds) != 1 || winds[0].Speed != 12.44 || winds[0].Direction != 284 || winds[0].Compass != "WNW" || winds[0].Gust != 17.3 {
+		t.Errorf("ERROR: Expected one wind, got %v %v", winds, err)
+	}
+
+	fake(t, `{"current_predictions":{"cp":[{"Type":"flood","meanFloodDir":70,"Bin":"1","meanEbbDir":250,"Time":"2024-03-01 05:47","Depth":"22","Velocity_Major":3.12}]}}`)
+	currents, err := c.CurrentPredictions("SFB1201", Options{Interval: IntervalMaxSlack})
+	if err != nil || len(currents) != 1 || currents[0].Velocity != 3.12 || currents[0].FloodDir != 70 || currents[0].Type != "flood" {
+		t.Errorf("ERROR: Expected one current prediction, got %v %v", currents, err)
+	}
+
+	fake(t, `{"error":{"message":"No data was found. This product may not be offered at this station at the requested time."}}`)
+	_, err = c.Visibility("9414290", Options{})
+	if !errors.Is(err, ErrNoData) {
+		t.Errorf("ERROR: Expected NOAA's error, got %v", err)
+	}
+
+	fake(t, "")
+	_, err = c.Currents("SFB1201", Options{})
+	if err == nil {
+		t.Errorf("ERROR: Expected an error when NOAA cannot be reached")
+	}
+}
+
+// recorded returns a response saved from NOAA.
+func recorded(t *testing.T, name string) string {
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("ERROR: Unable to read %s: %v", name, err)
+	}
+
+	return string(body)
+}
+
+func TestStationTime(t *testing.T) {
+	sf := &Station{ID: "9414290", TimeZone: "PST", UTCOffset: -8, ObservesDST: true}
+	honolulu := &Station{ID: "1612340", TimeZone: "HST", UTCOffset: -10}
+
+	testCases := []struct {
+		station  *Station
+		timeZone string
+		expected time.Time
+	}{
+		{sf, GMT, time.Date(2025, time.July, 4, 12, 0, 0, 0, time.UTC)},
+		{sf, LST, time.Date(2025, time.July, 4, 20, 0, 0, 0, time.UTC)},
+		{sf, LSTLDT, time.Date(2025, time.July, 4, 19, 0, 0, 0, time.UTC)},
+		{honolulu, LSTLDT, time.Date(2025, time.July, 4, 22, 0, 0, 0, time.UTC)},
+	}
+
+	for _, testCase := range testCases {
+		fake(t, `{"predictions":[{"t":"2025-07-04 12:00","v":"3.1","type":"H"}]}`)
+		opts := Options{TimeZone: testCase.timeZone, Station: testCase.station}
+		found, err := NewClient().Predictions(testCase.station.ID, opts)
+		if err != nil || len(found) != 1 || !found[0].Time.Equal(testCase.expected) {
+			t.Errorf("ERROR: For %s in %s expected %v, got %v %v", testCase.station.ID, testCase.timeZone, testCase.expected, found, err)
+		}
+	}
+
+	// Without the station, local times cannot be read.
+	fake(t, `{"predictions":[]}`)
+	_, err := NewClient().Predictions("9414290", Options{TimeZone: LST})
+	if err == nil {
+		t.Errorf("ERROR: Expected LST without the station to fail")
+	}
+}
+
+func TestTides(t *testing.T) {
+	testCases := []struct {
+		payload   string
+		value     string
+		level     string
+		flags     string
+		timestamp int64
+		noData    bool
+		fails     bool
+	}{
+		{"water_level.json", "4.117", Preliminary, "1,0,0,0", 1709312040, false, false},
+		{"water_level_verified.json", "2.951", Verified, "0,0,1,0", 1685578320, false, false},
+		{"empty.json", "", "", "", 0, true, true},
+		{"no_data.json", "", "", "", 0, true, true},
+		{"wrong_station.json", "", "", "", 0, false, true},
+		{"changed_type.json", "", "", "", 0, false, true},
+		{"bad_time.json", "", "", "", 0, false, true},
+		{"not_json.html", "", "", "", 0, false, true},
+	}
+
+	for _, testCase := range testCases {
+		fake(t, recorded(t, testCase.payload))
+
+		reading, err := Tides("9414290")
+		if (err != nil) != testCase.fails {
+			t.Errorf("ERROR: For %s expected failure %v, got %v", testCase.payload, testCase.fails, err)
+			continue
+		}
+		if errors.Is(err, ErrNoData) != testCase.noData {
+			t.Errorf("ERROR: For %s expected no data %v, got %v", testCase.payload, testCase.noData, err)
+		}
+		if err != nil {
+			continue
+		}
+		if reading.Station != "9414290" || reading.Product != ProductWaterLevel || reading.Datum != DatumMLLW {
+			t.Errorf("ERROR: For %s expected the station, product and datum, got %v", testCase.payload, reading)
+		}
+		if reading.Value != testCase.value || reading.ProcessingLevel != testCase.level || reading.Flags != testCase.flags || reading.Timestamp != testCase.timestamp {
+			t.Errorf("ERROR: For %s expected %s %s %s %d, got %v", testCase.payload, testCase.value, testCase.level, testCase.flags, testCase.timestamp, reading)
+		}
+	}
+
+	var apiErr *APIError
+	fake(t, recorded(t, "wrong_station.json"))
+	_, err := AirGap("9414304")
+	if !errors.As(err, &apiErr) || apiErr.Station != "9414304" || apiErr.Product != ProductAirGap {
+		t.Errorf("ERROR: Expected NOAA's error for the station, got %v", err)
+	}
+
+	fake(t, recorded(t, "air_gap.json"))
+	reading, err := AirGap("9414304")
+	if err != nil || reading.Value != "204.400" {
+		t.Errorf("ERROR: Expected an air gap of 204.400, got %v %v", reading, err)
+	}
+}
+
+func TestObservationFlags(t *testing.T) {
+	c := NewClient()
+
+	fake(t, recorded(t, "water_level_verified.json"))
+	levels, err := c.WaterLevel("9414290", Options{})
+	if err != nil || len(levels) != 2 {
+		t.Fatalf("ERROR: Expected the missing reading to be skipped, got %v %v", levels, err)
+	}
+	if levels[0].Flags.Suspect() || !levels[1].Flags.RateOfChange {
+		t.Errorf("ERROR: Expected only the second reading to be suspect, got %v", levels)
+	}
+
+	fake(t, recorded(t, "air_temperature.json"))
+	temperatures, err := c.AirTemperature("9414290", Options{})
+	if err != nil || len(temperatures) != 2 || temperatures[0].Flags.Suspect() || !temperatures[1].Flags.Limits || !temperatures[1].Flags.RateOfChange {
+		t.Errorf("ERROR: Expected the second temperature to be over the limit, got %v %v", temperatures, err)
+	}
+
+	fake(t, recorded(t, "wind.json"))
+	winds, err := c.Wind("9414290", Options{})
+	if err != nil || len(winds) != 1 || winds[0].Speed != 15.36 || winds[0].Flags.Suspect() {
+		t.Errorf("ERROR: Expected one wind, got %v %v", winds, err)
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	testCases := []struct {
+		f        string
+		expected Flags
+		fails    bool
+	}{
+		{"", Flags{}, false},
+		{"0,0,0,0", Flags{}, false},
+		{"3,0,0,0", Flags{Outliers: 3}, false},
+		{"0,1,0,1", Flags{Flat: true, Limits: true}, false},
+		{"0,0,1", Flags{RateOfChange: true}, false},
+		{"0,1,0", Flags{Limits: true}, false},
+		{"1,0", Flags{Limits: true}, false},
+		{"0", Flags{}, true},
+		{"0,x,0,0", Flags{}, true},
+	}
+
+	for _, testCase := range testCases {
+		flags, err := parseFlags(testCase.f)
+		if (err != nil) != testCase.fails || flags != testCase.expected {
+			t.Errorf("ERROR: For %q expected %v %v, got %v %v", testCase.f, testCase.expected, testCase.fails, flags, err)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package noaa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erikbryant/shipahoy/geo"
+)
+
+var (
+	// The CO-OPS Metadata API station list.
+	stationsURL = "https://api.tidesandcurrents.noaa.gov/mdapi/prod/webapi/stations.json"
+
+	// The Metadata API's name for the stations offering each product.
+	stationTypes = map[string]string{
+		ProductWaterLevel:         "waterlevels",
+		ProductAirGap:             "airgap",
+		ProductPredictions:        "tidepredictions",
+		ProductCurrents:           "currents",
+		ProductCurrentPredictions: "currentpredictions",
+		ProductWind:               "wind",
+		ProductAirTemperature:     "airtemp",
+		ProductWaterTemperature:   "watertemp",
+		ProductAirPressure:        "airpressure",
+		ProductVisibility:         "visibility",
+	}
+
+	// How long a station list is good for. Stations rarely come or go.
+	stationsMaxAge = 7 * 24 * time.Hour
+)
+
+// ErrNoStation is returned when no station offers a product.
+var ErrNoStation = errors.New("no station")
+
+// Station is a NOAA CO-OPS station.
+type Station struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	State       string   `json:"state"`
+	Lat         float64  `json:"lat"`
+	Lon         float64  `json:"lng"`
+	TimeZone    string   `json:"timezone"`     // of its standard time, such as "PST"
+	UTCOffset   float64  `json:"timezonecorr"` // of its standard time, hours
+	ObservesDST bool     `json:"observedst"`
+	Products    []string `json:"-"` // the products we know it offers
+}
+
+// Time zones with daylight saving time, by the abbreviation of their
+// standard time, as NOAA gives it.
+var zones = map[string]string{
+	"AST":  "America/Puerto_Rico",
+	"EST":  "America/New_York",
+	"CST":  "America/Chicago",
+	"MST":  "America/Denver",
+	"PST":  "America/Los_Angeles",
+	"AKST": "America/Anchorage",
+	"HST":  "Pacific/Honolulu",
+}
+
+// StandardTime returns the station's local standard time, which NOAA's
+// LST times are in all year.
+func (s Station) StandardTime() *time.Location {
+	return time.FixedZone(s.TimeZone, int(s.UTCOffset*60*60))
+}
+
+// LocalTime returns the station's local time, standard or daylight, which
+// NOAA's LST_LDT times are in. Stations that do not observe daylight
+// saving time, or whose zone is not known, keep standard time.
+func (s Station) LocalTime() *time.Location {
+	name, ok := zones[s.TimeZone]
+	if !s.ObservesDST || !ok {
+		return s.StandardTime()
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return s.StandardTime()
+	}
+
+	return loc
+}
+
+// Stations finds stations, caching the lists it fetches in memory and, if
+// given a directory, on disk.
+type Stations struct {
+	mu        sync.Mutex
+	dir       string
+	lists     map[string][]Station
+	fetched   map[string]time.Time
+	harmonics map[string]*Harmonics
+}
+
+// NewStations returns a station finder that caches in dir. An empty dir
+// caches in memory only.
+func NewStations(dir string) *Stations {
+	return &Stations{
+		dir:       dir,
+		lists:     map[string][]Station{},
+		fetched:   map[string]time.Time{},
+		harmonics: map[string]*Harmonics{},
+	}
+}
+
+// cacheFile is where the list of stations offering a product is kept on disk.
+func (s *Stations) cacheFile(product string) string {
+	return filepath.Join(s.dir, "noaa-stations-"+product+".json")
+}
+
+// decodeStations reads a Metadata API station list.
+func decodeStations(product, body string) ([]Station, error) {
+	var response struct {
+		Stations []Station `json:"stations"`
+	}
+	err := json.Unmarshal([]byte(body), &response)
+	if err != nil {
+		return nil, fmt.Errorf("noaa stations %s: %w", product, err)
+	}
+
+	for i := range response.Stations {
+		response.Stations[i].Products = []string{product}
+	}
+
+	return response.Stations, nil
+}
+
+// load reads the list of stations offering a product from the disk cache.
+func (s *Stations) load(product string) ([]Station, time.Time, error) {
+	if s.dir == "" {
+		return nil, time.Time{}, os.ErrNotExist
+	}
+
+	info, err := os.Stat(s.cacheFile(product))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	body, err := os.ReadFile(s.cacheFile(product))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	stations, err := decodeStations(product, string(body))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return stations, info.ModTime(), nil
+}
+
+// fetch gets the list of stations offering a product from NOAA and saves it to the disk cache.
+func (s *Stations) fetch(product string) ([]Station, error) {
+	body, err := requestBody(stationsURL+"?type="+stationTypes[product], map[string]string{})
+	if err != nil {
+		return nil, fmt.Errorf("noaa stations %s: %w", product, err)
+	}
+	stations, err := decodeStations(product, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.dir != "" {
+		err = os.MkdirAll(s.dir, 0755)
+		if err == nil {
+			err = os.WriteFile(s.cacheFile(product), []byte(body), 0644)
+		}
+		if err != nil {
+			fmt.Println("Unable to cache NOAA stations:", err)
+		}
+	}
+
+	return stations, nil
+}
+
+// List returns the stations offering a product. A list older than
+// stationsMaxAge is fetched again; if that fails the old one is used.
+func (s *Stations) List(product string) ([]Station, error) {
+	if _, ok := stationTypes[product]; !ok {
+		return nil, fmt.Errorf("noaa stations: unknown product %q", product)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stations, fetched := s.lists[product], s.fetched[product]
+	if stations == nil {
+		// Nothing cached on disk is the same as an empty cache.
+		stations, fetched, _ = s.load(product)
+	}
+
+	if stations == nil || time.Since(fetched) > stationsMaxAge {
+		fresh, err := s.fetch(product)
+		switch {
+		case err == nil:
+			stations, fetched = fresh, time.Now()
+		case stations == nil:
+			return nil, err
+		default:
+			fmt.Println("Using old list of NOAA stations:", err)
+		}
+	}
+
+	s.lists[product] = stations
+	s.fetched[product] = fetched
+
+	return stations, nil
+}
+
+// All returns every station offering any product, each with the products
+// it offers, sorted by ID.
+func (s *Stations) All() ([]Station, error) {
+	byID := map[string]*Station{}
+
+	for product := range stationTypes {
+		stations, err := s.List(product)
+		if err != nil {
+			return nil, err
+		}
+		for _, station := range stations {
+			found, ok := byID[station.ID]
+			if !ok {
+				found = &station
+				found.Products = nil
+				byID[station.ID] = found
+			}
+			found.Products = append(found.Products, product)
+		}
+	}
+
+	all := make([]Station, 0, len(byID))
+	for _, station := range byID {
+		sort.Strings(station.Products)
+		all = append(all, *station)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+
+	return all, nil
+}
+
+// Nearest returns the station offering a product that is closest to a
+// lat/lon, and how far away it is in nautical miles.
+func (s *Stations) Nearest(product string, lat, lon float64) (Station, float64, error) {
+	stations, err := s.List(product)
+	if err != nil {
+		return Station{}, 0, err
+	}
+	if len(stations) == 0 {
+		return Station{}, 0, fmt.Errorf("noaa stations %s: %w", product, ErrNoStation)
+	}
+
+	nearest := stations[0]
+	distance := geo.Distance(lat, lon, nearest.Lat, nearest.Lon)
+	for _, station := range stations[1:] {
+		d := geo.Distance(lat, lon, station.Lat, station.Lon)
+		if d < distance {
+			nearest, distance = station, d
+		}
+	}
+
+	return nearest, distance, nil
+}
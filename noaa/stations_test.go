@@ -0,0 +1,116 @@
+package noaa
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeStations serves the recorded station lists and counts the requests.
+func fakeStations(t *testing.T, up *bool) *int {
+	requests := new(int)
+
+	saved := requestBody
+	requestBody = func(u string, headers map[string]string) (string, error) {
+		*requests++
+		if !*up {
+			return "", fmt.Errorf("no response")
+		}
+		for product, kind := range stationTypes {
+			if strings.HasSuffix(u, "?type="+kind) {
+				if product == ProductWaterLevel || product == ProductAirGap {
+					return recorded(t, "stations_"+kind+".json"), nil
+				}
+				return `{"count":0,"units":null,"stations":[]}`, nil
+			}
+		}
+		return "", fmt.Errorf("unexpected request %s", u)
+	}
+	t.Cleanup(func() { requestBody = saved })
+
+	return requests
+}
+
+func TestNearest(t *testing.T) {
+	up := true
+	fakeStations(t, &up)
+	s := NewStations("")
+
+	testCases := []struct {
+		product  string
+		lat      float64
+		lon      float64
+		expected string
+		distance float64
+	}{
+		{ProductWaterLevel, 37.8007, -122.4097, "9414290", 2.67},
+		{ProductWaterLevel, 37.9, -122.4, "9414863", 1.44},
+		{ProductAirGap, 37.8007, -122.4097, "9414304", 1.76},
+		{ProductAirGap, 37.95, -122.45, "9414334", 1.14},
+		{ProductAirGap, 40.7, -74.0, "8519483", 5.88},
+	}
+
+	for _, testCase := range testCases {
+		station, distance, err := s.Nearest(testCase.product, testCase.lat, testCase.lon)
+		if err != nil || station.ID != testCase.expected || math.Abs(distance-testCase.distance) > 0.01 {
+			t.Errorf("ERROR: For %s at %f, %f expected %s %.2f nm, got %v %.2f %v", testCase.product, testCase.lat, testCase.lon, testCase.expected, testCase.distance, station, distance, err)
+		}
+	}
+
+	_, _, err := s.Nearest(ProductVisibility, 37.8, -122.4)
+	if !errors.Is(err, ErrNoStation) {
+		t.Errorf("ERROR: Expected no visibility station, got %v", err)
+	}
+
+	_, _, err = s.Nearest("tsunami", 37.8, -122.4)
+	if err == nil {
+		t.Errorf("ERROR: Expected an error for an unknown product")
+	}
+
+	all, err := s.All()
+	if err != nil || len(all) != 6 || all[2].ID != "9414290" || len(all[2].Products) != 1 || all[2].Products[0] != ProductWaterLevel {
+		t.Errorf("ERROR: Expected 6 stations, got %v %v", all, err)
+	}
+	if err == nil && (all[2].TimeZone != "PST" || all[2].UTCOffset != -8 || !all[2].ObservesDST) {
+		t.Errorf("ERROR: Expected San Francisco on Pacific time, got %+v", all[2])
+	}
+}
+
+func TestStationsCache(t *testing.T) {
+	up := true
+	requests := fakeStations(t, &up)
+	dir := t.TempDir()
+
+	s := NewStations(dir)
+	s.List(ProductAirGap)
+	s.List(ProductAirGap)
+	if *requests != 1 {
+		t.Errorf("ERROR: Expected the list to be fetched once, got %d requests", *requests)
+	}
+
+	// A new run reads the list from disk.
+	s = NewStations(dir)
+	stations, err := s.List(ProductAirGap)
+	if err != nil || len(stations) != 3 || *requests != 1 {
+		t.Errorf("ERROR: Expected the cached list, got %d stations %v after %d requests", len(stations), err, *requests)
+	}
+
+	// An old list is fetched again, but still used if NOAA is down.
+	old := time.Now().Add(-2 * stationsMaxAge)
+	os.Chtimes(s.cacheFile(ProductAirGap), old, old)
+	up = false
+	s = NewStations(dir)
+	stations, err = s.List(ProductAirGap)
+	if err != nil || len(stations) != 3 || *requests != 2 {
+		t.Errorf("ERROR: Expected the old list, got %d stations %v after %d requests", len(stations), err, *requests)
+	}
+
+	_, err = NewStations(dir).List(ProductWaterLevel)
+	if err == nil {
+		t.Errorf("ERROR: Expected an error with nothing cached and NOAA down")
+	}
+}
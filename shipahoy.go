@@ -1,8 +1,11 @@
 package shipahoy
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -10,9 +13,17 @@ import (
 	"github.com/erikbryant/aes"
 	"github.com/erikbryant/beepspeak"
 	"github.com/erikbryant/shipahoy/alert"
+	"github.com/erikbryant/shipahoy/anomaly"
+	"github.com/erikbryant/shipahoy/clearance"
+	"github.com/erikbryant/shipahoy/config"
 	"github.com/erikbryant/shipahoy/database"
+	"github.com/erikbryant/shipahoy/geo"
+	"github.com/erikbryant/shipahoy/landmark"
 	"github.com/erikbryant/shipahoy/marinetraffic"
+	"github.com/erikbryant/shipahoy/mqtt"
 	"github.com/erikbryant/shipahoy/noaa"
+	"github.com/erikbryant/shipahoy/shipid"
+	"github.com/erikbryant/shipahoy/speech"
 	"github.com/erikbryant/shipahoy/vesselfinder"
 	"github.com/erikbryant/web"
 )
@@ -39,6 +50,20 @@ var (
 		// "Unknown":        true,
 	}
 
+	// Watches every ship report for spoofing and bad data.
+	detector = anomaly.NewDetector(visibleFromApt, lastPosition)
+
+	// Set up in Start, once we know where the tide is measured, and before
+	// any scanner starts.
+	clearances *clearance.Checker
+	tideNow    = func(at time.Time) (float64, bool) { return 0, false }
+
+	// Says where to look for a ship.
+	landmarks = landmark.New(landmark.DefaultConfig())
+
+	// Publishes ships and readings to MQTT, if a broker is configured.
+	publisher *mqtt.Publisher
+
 	uninterestingMMSI = map[string]bool{
 		"367123640": true, // Hawk
 		"367389640": true, // Oski
@@ -129,10 +154,60 @@ func visibleFromApt(lat, lon float64) bool {
 	return true
 }
 
+// aptZone names the area visibleFromApt covers, for alerts.
+const aptZone = "apartment view"
+
+// dwell returns how long a ship is expected to stay visible from our
+// apartment if it holds its course and speed, up to two hours.
+func dwell(details database.Ship) time.Duration {
+	const (
+		step    = time.Minute
+		longest = 2 * time.Hour
+	)
+
+	if details.Speed < 1 {
+		return longest
+	}
+
+	for elapsed := step; elapsed < longest; elapsed += step {
+		lat, lon := geo.Destination(details.Lat, details.Lon, details.Course, details.Speed*elapsed.Hours())
+		if !visibleFromApt(lat, lon) {
+			return elapsed
+		}
+	}
+
+	return longest
+}
+
+// score returns how interesting a sighting is, from 0 to 100. Big ships,
+// ships we have not seen before, ones we watch for, ones doing something
+// unusual, and ones with clearance warnings score higher.
+func score(details database.Ship, warnings []clearance.Warning) int {
+	points := 10
+
+	points += min(details.Length/10, 30)
+	if details.Sightings == 0 {
+		points += 20
+	}
+	if interestingMMSI[details.MMSI] {
+		points += 20
+	}
+	switch details.NavigationalStatus {
+	case 2, 3, 4, 6, 14: // not under command, restricted, constrained by draught, aground, AIS-SART
+		points += 15
+	}
+	points += 15 * len(warnings)
+
+	return min(points, 100)
+}
+
 // hydrate consolidates ship information from multiple sources
-func hydrate(vfDetails map[string]interface{}, mtDetails []map[string]string) database.Ship {
+func hydrate(ctx context.Context, vfDetails map[string]interface{}, mtDetails []map[string]string) database.Ship {
 	// Load any details we might already have.
-	details, _ := database.LookupShip(web.ToString(vfDetails["mmsi"]))
+	details, _, err := database.LookupShip(ctx, web.ToString(vfDetails["mmsi"]))
+	if err != nil {
+		fmt.Println("Database error:", err)
+	}
 
 	// Add fields from VesselFinder.
 	details.Beam = web.ToInt(vfDetails["aw"])
@@ -159,6 +234,12 @@ func hydrate(vfDetails map[string]interface{}, mtDetails []map[string]string) da
 	if vfDetails["lc."] != nil {
 		details.LoadCondition = web.ToInt(vfDetails["lc."])
 	}
+	if vfDetails["callsign"] != nil {
+		details.CallSign = web.ToString(vfDetails["callsign"])
+	}
+	if vfDetails["eni"] != nil {
+		details.ENI = web.ToString(vfDetails["eni"])
+	}
 	// details.InvalidDimensions
 	// details.MarineTrafficID
 	// details.RateOfTurn
@@ -180,6 +261,10 @@ func hydrate(vfDetails map[string]interface{}, mtDetails []map[string]string) da
 
 // lookAtShips looks for interesting ships in a given lat/lon region.
 func lookAtShips(latA, lonA, latB, lonB float64) {
+	ctx := context.Background()
+
+	detector.Forget(time.Now().Unix())
+
 	// Open channel
 	c := make(chan map[string]interface{}, 10)
 
@@ -191,6 +276,12 @@ func lookAtShips(latA, lonA, latB, lonB float64) {
 
 	go vesselfinder.ShipsInRegion(latA, lonA, latB, lonB, c)
 
+	// Every position we see is saved, whether or not it leads to an alert.
+	var positions []database.Position
+	defer func() {
+		db.savePositions(ctx, positions)
+	}()
+
 	for {
 		// Read 'response' from channel.
 		vfResponse, ok := <-c
@@ -198,13 +289,44 @@ func lookAtShips(latA, lonA, latB, lonB float64) {
 			break
 		}
 
-		details := hydrate(vfResponse, mtResponse)
-		database.SaveShip(details)
+		details := hydrate(ctx, vfResponse, mtResponse)
+		details.DataQuality = shipid.Assess(details)
 
-		// Ignore ships that are not visible from our apartment.
+		anomalies := detector.Check(details, web.ToString(vfResponse["mapName"]), time.Now().Unix())
+		for _, a := range detector.Unreported(anomalies) {
+			db.saveAnomaly(ctx, a)
+			err := publisher.Anomaly(details, a)
+			if err != nil {
+				fmt.Println("MQTT error:", err)
+			}
+		}
+		if anomaly.Disqualified(anomalies) {
+			continue
+		}
+
+		db.saveShip(ctx, details, "vesselfinder")
+		positions = append(positions, database.Position{
+			MMSI:               details.MMSI,
+			Timestamp:          int64(details.LastPosUpdate),
+			Lat:                details.Lat,
+			Lon:                details.Lon,
+			Speed:              details.Speed,
+			Course:             details.Course,
+			Heading:            details.Heading,
+			NavigationalStatus: details.NavigationalStatus,
+			Source:             "vesselfinder",
+		})
+
+		// Ignore ships that are not visible from our apartment. Only those
+		// that are get a retained state on MQTT; the planet-wide scan would
+		// otherwise leave one on the broker for every ship in the world.
 		if !visibleFromApt(details.Lat, details.Lon) {
 			continue
 		}
+		err := publisher.Vessel(details)
+		if err != nil {
+			fmt.Println("MQTT error:", err)
+		}
 
 		// Ignore ships that have stale position data.
 		posAge := time.Now().Unix() - int64(details.LastPosUpdate)
@@ -228,8 +350,8 @@ func lookAtShips(latA, lonA, latB, lonB float64) {
 			}
 		}
 
-		if !interestingMMSI[details.MMSI] {
-			// Skip uninteresting ships.
+		// Skip uninteresting ships, unless they are in distress.
+		if !interestingMMSI[details.MMSI] && details.NavigationalStatus != 14 {
 			if uninterestingAIS[details.Type] || uninterestingMMSI[details.MMSI] {
 				continue
 			}
@@ -249,22 +371,42 @@ func lookAtShips(latA, lonA, latB, lonB float64) {
 			}
 		}
 
+		// Ignore ships whose identity looks like a placeholder or spoof.
+		if shipid.Suspect(details.DataQuality) {
+			fmt.Println("Suspect identity:", details.MMSI, details.Name, shipid.Describe(details.DataQuality))
+			continue
+		}
+
 		// If we have recently seen this ship, skip it.
 		now := time.Now().Unix()
-		elapsed := now - database.LookupLastSighting(details)
+		elapsed := now - db.lastSighting(ctx, details)
 		if elapsed < 30*60 {
 			// The ship is still crossing the visible area.
 			// No need to alert a second time.
 			continue
 		}
 
-		// We have passed all the tests! Save and alert.
+		// We have passed all the tests! Save and alert. If the sighting
+		// cannot be saved it is held for later; alert regardless.
 		myLat, myLon := myGeo()
-		database.SaveSighting(details, myLat, myLon)
-		err := alert.Alert(details)
+		db.saveSighting(ctx, details, myLat, myLon)
+		warnings := clearances.Warnings(details, time.Now())
+		level, known := tideNow(time.Now())
+		where := landmarks.Describe(details, myLat, myLon)
+		err = alert.Notify(alert.Event{
+			Kind:     alert.Sighting,
+			Time:     time.Now(),
+			Ship:     details,
+			Warnings: warnings,
+			Zone:     aptZone,
+			Dwell:    dwell(details),
+			Score:    score(details, warnings),
+			Watched:  interestingMMSI[details.MMSI],
+			Tide:     alert.Tide{Level: level, Known: known},
+			Where:    &where,
+		})
 		if err != nil {
 			fmt.Println(err)
-			return
 		}
 	}
 }
@@ -322,23 +464,210 @@ func scanPlanet(sleepDuration time.Duration) {
 	}
 }
 
-// tides looks up instantaneous tide data for a given NOAA station.
-func tides(sleepDuration time.Duration, station string) {
+// tides looks up and saves instantaneous tide data for a given NOAA
+// station. When NOAA cannot be reached it predicts the tide instead.
+func tides(sleepDuration time.Duration, station string, stations *noaa.Stations) {
+	var harmonics *noaa.Harmonics
+
 	for {
-		reading, ok := noaa.Tides(station)
-		if ok {
+		if harmonics == nil {
+			var err error
+			harmonics, err = stations.Harmonics(station)
+			if err != nil {
+				fmt.Println("No tide predictions for", station, err)
+			}
+		}
+
+		reading, err := noaa.Tides(station)
+		if err != nil {
+			fmt.Println("Error reading", station, err)
+			if harmonics != nil {
+				fmt.Printf("Predicted tide: %.2f ft\n", harmonics.Predict(time.Now()))
+			}
+		} else {
 			fmt.Println("Reading:", reading)
+			db.saveNoaaDatum(context.Background(), reading)
+			err = publisher.Reading(reading)
+			if err != nil {
+				fmt.Println("MQTT error:", err)
+			}
+		}
+		time.Sleep(sleepDuration)
+	}
+}
+
+// checkTidePredictions saves NOAA's tide predictions for the day ahead and
+// reports how far our own predictions are from those saved in the last week.
+func checkTidePredictions(sleepDuration time.Duration, station string, stations *noaa.Stations) {
+	ctx := context.Background()
+
+	for {
+		now := time.Now()
+
+		predictions, err := noaa.NewClient().Predictions(station, noaa.Options{Begin: now, End: now.Add(24 * time.Hour), Interval: noaa.IntervalHourly})
+		if err != nil {
+			fmt.Println("Error reading predictions", station, err)
 		}
+		var data []database.NoaaDatum
+		for _, p := range predictions {
+			data = append(data, database.NoaaDatum{
+				Station:   station,
+				Product:   noaa.ProductPredictions,
+				Datum:     noaa.DatumMLLW,
+				Value:     strconv.FormatFloat(p.Value, 'f', 3, 64),
+				Timestamp: p.Time.Unix(),
+			})
+		}
+		db.saveNoaaData(ctx, data)
+
+		harmonics, err := stations.Harmonics(station)
+		if err != nil {
+			fmt.Println("No tide predictions for", station, err)
+		} else {
+			saved, err := database.LookupNoaaData(ctx, station, noaa.ProductPredictions, now.Add(-7*24*time.Hour).Unix(), now.Unix())
+			if err != nil {
+				fmt.Println("Database error:", err)
+			}
+			var known []noaa.Prediction
+			for _, d := range saved {
+				value, err := strconv.ParseFloat(d.Value, 64)
+				if err == nil {
+					known = append(known, noaa.Prediction{Time: time.Unix(d.Timestamp, 0), Value: value})
+				}
+			}
+			if len(known) > 0 {
+				rms, worst := harmonics.Compare(known)
+				fmt.Printf("Tide predictions for %s are within %.2f ft of NOAA's (rms %.2f ft, %d hours)\n", station, worst, rms, len(known))
+			}
+		}
+
 		time.Sleep(sleepDuration)
 	}
 }
 
-// airGap looks up instantaneous air gap (distance from bottom of bridge to water) for a given NOAA station.
+// airGap looks up and saves instantaneous air gap (distance from bottom of bridge to water) for a given NOAA station.
 func airGap(sleepDuration time.Duration, station string) {
 	for {
-		reading, ok := noaa.AirGap(station)
-		if ok {
+		reading, err := noaa.AirGap(station)
+		if err != nil {
+			fmt.Println("Error reading", station, err)
+		} else {
 			fmt.Println("Reading:", reading)
+			db.saveNoaaDatum(context.Background(), reading)
+			err = publisher.Reading(reading)
+			if err != nil {
+				fmt.Println("MQTT error:", err)
+			}
+		}
+		time.Sleep(sleepDuration)
+	}
+}
+
+// How old a NOAA reading can be and still describe the water now (seconds).
+const freshReading = 30 * 60
+
+// latestReading returns the most recent saved NOAA reading of a product at a station, if it is fresh.
+func latestReading(station, product string) (float64, bool) {
+	reading, ok, err := database.LookupLatestNoaaDatum(context.Background(), station, product)
+	if err != nil {
+		fmt.Println("Database error:", err)
+	}
+	if !ok || time.Now().Unix()-reading.Timestamp > freshReading {
+		return 0, false
+	}
+
+	value, err := strconv.ParseFloat(reading.Value, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return value, true
+}
+
+// bridgeAirGap returns how the clearance checker gets the air gap under a
+// bridge: from the bridge's own NOAA air gap station if it has a fresh
+// reading, otherwise from the tide, measured or predicted.
+func bridgeAirGap(stations *noaa.Stations, tideStation string) func(b clearance.Bridge) (float64, string, bool) {
+	return func(b clearance.Bridge) (float64, string, bool) {
+		if b.Station != "" {
+			gap, ok := latestReading(b.Station, noaa.ProductAirGap)
+			if ok {
+				return gap, "measured", true
+			}
+		}
+
+		tide, ok := latestReading(tideStation, noaa.ProductWaterLevel)
+		if ok {
+			return b.FromTide(tide), "from the tide", true
+		}
+
+		harmonics, err := stations.Harmonics(tideStation)
+		if err != nil {
+			return 0, "", false
+		}
+
+		return b.FromTide(harmonics.Predict(time.Now())), "from the predicted tide", true
+	}
+}
+
+// tideAt returns how the clearance checker gets the water level at a
+// time: the predicted tide, shifted by how far the latest measurement is
+// from the prediction (wind and river flow move the water too). Without
+// predictions the latest measurement is used for any time.
+func tideAt(stations *noaa.Stations, tideStation string) func(at time.Time) (float64, bool) {
+	return func(at time.Time) (float64, bool) {
+		measured, fresh := latestReading(tideStation, noaa.ProductWaterLevel)
+
+		harmonics, err := stations.Harmonics(tideStation)
+		if err != nil {
+			return measured, fresh
+		}
+
+		offset := 0.0
+		if fresh {
+			offset = measured - harmonics.Predict(time.Now())
+		}
+
+		return harmonics.Predict(at) + offset, true
+	}
+}
+
+// stationCache returns the directory NOAA station lists are cached in.
+func stationCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		fmt.Println("No cache directory, NOAA stations will be fetched each run:", err)
+		return ""
+	}
+
+	return filepath.Join(dir, "shipahoy")
+}
+
+// nearestStation returns the ID of the NOAA station offering a product
+// nearest to a lat/lon, or fallback if there is none.
+func nearestStation(stations *noaa.Stations, product string, lat, lon float64, fallback string) string {
+	station, distance, err := stations.Nearest(product, lat, lon)
+	if err != nil {
+		fmt.Println("Unable to find a NOAA station for", product, err)
+		return fallback
+	}
+
+	fmt.Printf("NOAA %s station: %s %s (%.1f nm)\n", product, station.ID, station.Name, distance)
+
+	return station.ID
+}
+
+// redeliverWebhooks tries the webhook's dead letters again, now and
+// periodically after. With no period it tries them once.
+func redeliverWebhooks(webhook *alert.Webhook, sleepDuration time.Duration) {
+	for {
+		err := webhook.Redeliver()
+		if err != nil {
+			fmt.Println("ERROR: Unable to redeliver webhooks:", err)
+		}
+
+		if sleepDuration <= 0 {
+			return
 		}
 		time.Sleep(sleepDuration)
 	}
@@ -349,7 +678,12 @@ func dbStats(sleepDuration time.Duration) {
 	for {
 		msg := ""
 
-		for table, count := range database.TableStats() {
+		stats, err := database.TableStats(context.Background())
+		if err != nil {
+			fmt.Println("Database error:", err)
+		}
+
+		for table, count := range stats {
 			msg += table + ": " + strconv.FormatInt(count, 10) + " "
 		}
 
@@ -361,9 +695,89 @@ func dbStats(sleepDuration time.Duration) {
 	}
 }
 
+// timeInView returns how long a ship stayed visible from our apartment
+// around a sighting, from its track.
+func timeInView(track []database.Position, at int64) time.Duration {
+	var first, last int64
+
+	for _, p := range track {
+		if !visibleFromApt(p.Lat, p.Lon) {
+			if p.Timestamp > at {
+				break
+			}
+			// Out of view before the sighting; start again.
+			first, last = 0, 0
+			continue
+		}
+		if first == 0 {
+			first = p.Timestamp
+		}
+		last = p.Timestamp
+	}
+
+	return time.Duration(last-first) * time.Second
+}
+
+// buildDigest returns the sightings on the day that starts at day.
+func buildDigest(ctx context.Context, day time.Time) (alert.Digest, error) {
+	digest := alert.Digest{Day: day}
+
+	end := day.AddDate(0, 0, 1).Unix() - 1
+	sightings, err := database.LookupSightings(ctx, day.Unix(), end)
+	if err != nil {
+		return digest, err
+	}
+
+	for _, sighting := range sightings {
+		details, _, err := database.LookupShip(ctx, sighting.MMSI)
+		if err != nil {
+			return digest, err
+		}
+		details.MMSI = sighting.MMSI
+		earlier, err := database.CountSightingsBefore(ctx, sighting.MMSI, day.Unix())
+		if err != nil {
+			return digest, err
+		}
+		// Ships are in view for a while before and after we alert.
+		track, err := database.LookupTrack(ctx, sighting.MMSI, sighting.Timestamp-60*60, sighting.Timestamp+2*60*60)
+		if err != nil {
+			return digest, err
+		}
+
+		digest.Entries = append(digest.Entries, alert.DigestEntry{
+			Ship:   details,
+			Time:   time.Unix(sighting.Timestamp, 0).In(day.Location()),
+			InView: timeInView(track, sighting.Timestamp),
+			// Never seen before the day began.
+			First: earlier == 0,
+		})
+	}
+
+	return digest, nil
+}
+
+// emailDigests emails the previous day's sightings every day at the configured hour.
+func emailDigests(email *alert.Email) {
+	for {
+		next := email.NextDigest(time.Now())
+		time.Sleep(time.Until(next))
+
+		today := time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, next.Location())
+		digest, err := buildDigest(context.Background(), today.AddDate(0, 0, -1))
+		if err != nil {
+			fmt.Println("Database error:", err)
+			continue
+		}
+		err = email.SendDigest(digest)
+		if err != nil {
+			fmt.Println("Unable to email digest:", err)
+		}
+	}
+}
+
 // Start is the entry point for the shipahoy module. It starts each of the scanners.
-func Start(passPhrase string) error {
-	err := database.Open()
+func Start(passPhrase string, cfg config.Config) error {
+	err := database.Open(context.Background(), cfg.Database)
 	if err != nil {
 		return err
 	}
@@ -373,16 +787,69 @@ func Start(passPhrase string) error {
 		return err
 	}
 
+	// Without Google's credentials we can still speak offline.
 	err = beepspeak.NewCredentials(gcpAuthCrypt, passPhrase)
+	if err != nil {
+		fmt.Println("ERROR: Unable to load Google credentials. Speaking offline. Message:", err)
+	}
+	voice, err := speech.New(cfg.Speech, err == nil)
 	if err != nil {
 		return err
 	}
+	alert.SetVoice(voice)
+	pronunciations, err := speech.LoadDictionary(cfg.Speech.Dictionary)
+	if err != nil {
+		return err
+	}
+	alert.SetPronunciations(pronunciations)
+	messages, err := alert.NewMessages(cfg.Observers)
+	if err != nil {
+		return err
+	}
+	alert.SetMessages(messages)
+	landmarks = landmark.New(cfg.Landmarks)
+	sound, err := alert.NewSound(cfg.Sound)
+	if err != nil {
+		return err
+	}
+	alert.SetSound(sound)
+
+	// Everything the scanners read is set up before any of them start;
+	// finding the nearest station can mean fetching NOAA's station list.
+	stations := noaa.NewStations(stationCache())
+	lat, lon := myGeo()
+	tideStation := nearestStation(stations, noaa.ProductWaterLevel, lat, lon, "9414290")
+	tideNow = tideAt(stations, tideStation)
+	clearances = clearance.NewChecker(cfg.Clearance, bridgeAirGap(stations, tideStation), tideNow)
+
+	if len(cfg.Webhook.URLs) > 0 {
+		webhook := alert.NewWebhook(cfg.Webhook)
+		alert.Register(webhook)
+		go redeliverWebhooks(webhook, cfg.Webhook.RedeliverEvery)
+	}
+
+	if cfg.MQTT.Broker != "" {
+		publisher, err = mqtt.Connect(cfg.MQTT)
+		if err != nil {
+			return err
+		}
+		alert.Register(publisher)
+	}
+
+	if cfg.Email.Host != "" {
+		email := alert.NewEmail(cfg.Email)
+		alert.Register(email)
+		if cfg.Email.DigestHour >= 0 {
+			go emailDigests(email)
+		}
+	}
 
 	// go scanNearby(5 * 60 * time.Second)
 	go scanAptVisible(1 * 60 * time.Second)
 	go scanPlanet(2 * 60 * time.Second)
-	go tides(10*60*time.Second, "9414290")
-	go airGap(10*60*time.Second, "9414304")
+	go tides(10*60*time.Second, tideStation, stations)
+	go checkTidePredictions(24*60*60*time.Second, tideStation, stations)
+	go airGap(10*60*time.Second, nearestStation(stations, noaa.ProductAirGap, lat, lon, "9414304"))
 	go dbStats(10 * 60 * time.Second)
 
 	return nil
@@ -390,5 +857,6 @@ func Start(passPhrase string) error {
 
 // Stop performs any needed shutdown.
 func Stop() {
+	publisher.Close()
 	database.Close()
 }
@@ -1,7 +1,11 @@
 package shipahoy
 
 import (
+	"context"
 	"testing"
+	"time"
+
+	"github.com/erikbryant/shipahoy/database"
 )
 
 // TODO:
@@ -56,3 +60,96 @@ func TestBox(t *testing.T) {
 		}
 	}
 }
+
+func TestDwell(t *testing.T) {
+	testCases := []struct {
+		lat      float64
+		lon      float64
+		course   float64
+		speed    float64
+		expected time.Duration
+	}{
+		{37.82, -122.46, 0, 0, 2 * time.Hour},      // not moving
+		{37.82, -122.46, 270, 60, time.Minute},     // a mile a minute, 0.95 nm from the west edge
+		{37.82, -122.46, 270, 6, 10 * time.Minute}, // a tenth of that
+		{37.8061, -122.46, 180, 12, time.Minute},   // about to leave by the south edge
+	}
+
+	for _, testCase := range testCases {
+		details := database.Ship{Lat: testCase.lat, Lon: testCase.lon, Course: testCase.course, Speed: testCase.speed}
+		answer := dwell(details)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %v, %v heading %v at %v expected %v, got %v", testCase.lat, testCase.lon, testCase.course, testCase.speed, testCase.expected, answer)
+		}
+	}
+}
+
+func TestTimeInView(t *testing.T) {
+	// In view between lon -122.48 and about -122.44 at this latitude.
+	track := []database.Position{
+		{Timestamp: 100, Lat: 37.82, Lon: -122.47},
+		{Timestamp: 200, Lat: 37.82, Lon: -122.46},
+		{Timestamp: 300, Lat: 37.82, Lon: -122.45},
+		{Timestamp: 400, Lat: 37.82, Lon: -122.40},
+		{Timestamp: 500, Lat: 37.82, Lon: -122.46},
+	}
+
+	testCases := []struct {
+		track    []database.Position
+		at       int64
+		expected time.Duration
+	}{
+		{track, 200, 200 * time.Second},
+		{track, 500, 0},
+		{track[1:2], 200, 0},
+		{nil, 200, 0},
+	}
+
+	for _, testCase := range testCases {
+		answer := timeInView(testCase.track, testCase.at)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %v at %d expected %v, got %v", testCase.track, testCase.at, testCase.expected, answer)
+		}
+	}
+}
+
+func TestBuildDigest(t *testing.T) {
+	ctx := context.Background()
+	database.Use(database.NewMemoryStore())
+
+	now := time.Now()
+	hawk := database.Ship{MMSI: "366999712", Name: "HAWK", Type: "Tug", Lat: 37.82, Lon: -122.46}
+	ever := database.Ship{MMSI: "353136000", Name: "EVER GIVEN", Type: "Cargo", Lat: 37.82, Lon: -122.46}
+	database.SaveShip(ctx, hawk, "test")
+	database.SaveShip(ctx, ever, "test")
+	database.SaveSighting(ctx, hawk, 37.8, -122.4)
+	database.SaveSighting(ctx, hawk, 37.8, -122.4)
+	database.SaveSighting(ctx, ever, 37.8, -122.4)
+	database.SavePositions(ctx, []database.Position{
+		{MMSI: ever.MMSI, Timestamp: now.Unix() - 300, Lat: 37.82, Lon: -122.47, Source: "test"},
+		{MMSI: ever.MMSI, Timestamp: now.Unix() + 300, Lat: 37.82, Lon: -122.45, Source: "test"},
+	})
+
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	digest, err := buildDigest(ctx, day)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to build digest: %v", err)
+	}
+
+	if len(digest.Entries) != 3 {
+		t.Fatalf("ERROR: Expected 3 sightings, got %v", digest.Entries)
+	}
+	for _, e := range digest.Entries {
+		if !e.First {
+			t.Errorf("ERROR: Expected %s, only seen today, to be a first", e.Ship.Name)
+		}
+		if e.Ship.MMSI == ever.MMSI && (e.Ship.Type != "Cargo" || e.InView != 10*time.Minute) {
+			t.Errorf("ERROR: Expected the cargo ship 10 minutes in view, got %v", e)
+		}
+	}
+
+	digest, _ = buildDigest(ctx, day.AddDate(0, 0, -1))
+	if len(digest.Entries) != 0 {
+		t.Errorf("ERROR: Expected no sightings yesterday, got %v", digest.Entries)
+	}
+}
@@ -0,0 +1,132 @@
+package shipid
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/erikbryant/shipahoy/database"
+)
+
+// Data quality flags. A ship's DataQuality is the bitwise OR of every
+// problem found with its identity. Zero means nothing looked wrong.
+const (
+	ImoInvalid           = 1 << iota // IMO number fails its check digit
+	ImoPlaceholder                   // IMO number is a well known dummy value
+	CallSignInvalid                  // call sign is not in any ITU series
+	CallSignFlagMismatch             // call sign series belongs to a different flag
+	EniInvalid                       // ENI number is malformed or unallocated
+	NamePlaceholder                  // name is missing or is just the MMSI
+)
+
+var (
+	// Descriptions of each quality flag, in bit order.
+	qualityText = []string{
+		"IMO check digit invalid",
+		"IMO is a placeholder",
+		"call sign not allocated",
+		"call sign does not match flag",
+		"ENI invalid",
+		"name is a placeholder",
+	}
+
+	// IMO numbers commonly typed in as filler: 1234567, 7654321, 1111111
+	// and 9999999, matched exactly after trimming spaces. Only 1234567
+	// passes the checksum; the others are also flagged as invalid.
+	placeholderImo = map[string]bool{
+		"1234567": true,
+		"7654321": true,
+		"1111111": true,
+		"9999999": true,
+	}
+
+	// Flags that mean the identity is probably not a real ship.
+	suspect = ImoInvalid | ImoPlaceholder | NamePlaceholder
+)
+
+// ValidateImo tests whether an IMO number is well formed and has a valid check digit.
+func ValidateImo(imo string) error {
+	imo = strings.TrimSpace(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(imo)), "IMO"))
+
+	matched, err := regexp.MatchString("^[0-9]{7}$", imo)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return fmt.Errorf("IMO is not 7 digits: %s", imo)
+	}
+
+	// The check digit is the last digit of the sum of the first six
+	// digits, each multiplied by its position counted from the right.
+	// For IMO 9074729: 9×7 + 0×6 + 7×5 + 4×4 + 7×3 + 2×2 = 139.
+	sum := 0
+	for i := 0; i < 6; i++ {
+		sum += int(imo[i]-'0') * (7 - i)
+	}
+	if sum%10 != int(imo[6]-'0') {
+		return fmt.Errorf("IMO check digit mismatch: %s", imo)
+	}
+
+	return nil
+}
+
+// hasImo returns whether the ship claims to have an IMO number at all.
+// Small craft are not assigned one and VesselFinder reports them as 0.
+func hasImo(imo string) bool {
+	imo = strings.TrimSpace(imo)
+	return imo != "" && strings.Trim(imo, "0") != ""
+}
+
+// Assess checks the identity fields of a ship and returns its data quality flags.
+func Assess(details database.Ship) int {
+	quality := 0
+
+	if hasImo(details.IMO) {
+		if ValidateImo(details.IMO) != nil {
+			quality |= ImoInvalid
+		}
+		if placeholderImo[strings.TrimSpace(details.IMO)] {
+			quality |= ImoPlaceholder
+		}
+	}
+
+	if details.CallSign != "" {
+		country, err := CallSignCountry(details.CallSign)
+		if err != nil {
+			quality |= CallSignInvalid
+		} else if country != "" && details.Flag != "" && !SameAdministration(country, details.Flag) {
+			quality |= CallSignFlagMismatch
+		}
+	}
+
+	if details.ENI != "" {
+		if _, err := EniCountry(details.ENI); err != nil {
+			quality |= EniInvalid
+		}
+	}
+
+	name := strings.TrimSpace(details.Name)
+	if name == "" || name == details.MMSI {
+		quality |= NamePlaceholder
+	}
+
+	return quality
+}
+
+// Suspect returns whether the quality flags indicate a placeholder or spoofed identity.
+func Suspect(quality int) bool {
+	return quality&suspect != 0
+}
+
+// Describe returns a human readable list of the problems in the quality flags.
+func Describe(quality int) []string {
+	var problems []string
+
+	for i, text := range qualityText {
+		if quality&(1<<i) != 0 {
+			problems = append(problems, text)
+		}
+	}
+
+	return problems
+}
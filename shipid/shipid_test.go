@@ -0,0 +1,127 @@
+package shipid
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/erikbryant/shipahoy/database"
+)
+
+func TestValidateImo(t *testing.T) {
+	testCases := []struct {
+		imo   string
+		valid bool
+	}{
+		{"9074729", true},
+		{"IMO 9074729", true},
+		{"1008918", true},
+		{"9074728", false},
+		{"907472", false},
+		{"90747290", false},
+		{"90747a9", false},
+		{"", false},
+	}
+
+	for _, testCase := range testCases {
+		err := ValidateImo(testCase.imo)
+		isValid := err == nil
+		if isValid != testCase.valid {
+			t.Errorf("ERROR: For %v expected %v, got %v", testCase.imo, testCase.valid, isValid)
+		}
+	}
+}
+
+func TestCallSignCountry(t *testing.T) {
+	testCases := []struct {
+		callSign string
+		expected string
+		valid    bool
+	}{
+		{"WDC1234", "US", true},
+		{"KRAY", "US", true},
+		{"9HA2345", "MT", true},
+		{"3DNA", "FJ", true},
+		{"3DMA", "SZ", true},
+		{"SSMA", "EG", true},
+		{"SSNA", "SD", true},
+		{"VRBC7", "HK", true},
+		{"A8QX9", "LR", true},
+		{"v7 ab1", "MH", true},
+		{"D5AB2", "LR", true},
+		{"QAAA", "", false},
+		{"1ABC", "", false},
+		{"AB", "", false},
+		{"A@BC", "", false},
+	}
+
+	for _, testCase := range testCases {
+		answer, err := CallSignCountry(testCase.callSign)
+		isValid := err == nil
+		if isValid != testCase.valid || answer != testCase.expected {
+			t.Errorf("ERROR: For %v expected %v %v, got %v %v", testCase.callSign, testCase.expected, testCase.valid, answer, isValid)
+		}
+	}
+}
+
+func TestEniCountry(t *testing.T) {
+	testCases := []struct {
+		eni      string
+		expected string
+		valid    bool
+	}{
+		{"02326543", "NL", true},
+		{"04801234", "DE", true},
+		{"74000001", "US", true},
+		{"09000001", "", false},
+		{"0232654", "", false},
+		{"0232654x", "", false},
+	}
+
+	for _, testCase := range testCases {
+		answer, err := EniCountry(testCase.eni)
+		isValid := err == nil
+		if isValid != testCase.valid || answer != testCase.expected {
+			t.Errorf("ERROR: For %v expected %v %v, got %v %v", testCase.eni, testCase.expected, testCase.valid, answer, isValid)
+		}
+	}
+}
+
+func TestAssess(t *testing.T) {
+	testCases := []struct {
+		details  database.Ship
+		expected int
+	}{
+		{database.Ship{MMSI: "319762000", IMO: "1008918", Name: "SAINT NICOLAS", CallSign: "ZCEO3", Flag: "PA"}, CallSignFlagMismatch},
+		{database.Ship{MMSI: "319762000", IMO: "1008918", Name: "SAINT NICOLAS", CallSign: "ZCEO3", Flag: "KY"}, 0},
+		{database.Ship{MMSI: "319762000", IMO: "1008918", Name: "SAINT NICOLAS", CallSign: "ZCEO3", Flag: "GB"}, 0},
+		{database.Ship{MMSI: "338926430", IMO: "0", Name: "CG ROBERT WARD"}, 0},
+		{database.Ship{MMSI: "338926430", IMO: "1234567", Name: "338926430"}, ImoPlaceholder | NamePlaceholder},
+		{database.Ship{MMSI: "538007561", IMO: "9074728", Name: "X", ENI: "99999999"}, ImoInvalid | EniInvalid},
+		{database.Ship{MMSI: "538007561", IMO: "9074729", Name: "X", CallSign: "Q1"}, CallSignInvalid},
+	}
+
+	for _, testCase := range testCases {
+		answer := Assess(testCase.details)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %v expected %v, got %v", testCase.details, testCase.expected, answer)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	testCases := []struct {
+		quality  int
+		expected []string
+	}{
+		{0, nil},
+		{ImoInvalid, []string{"IMO check digit invalid"}},
+		{CallSignFlagMismatch | NamePlaceholder, []string{"call sign does not match flag", "name is a placeholder"}},
+	}
+
+	for _, testCase := range testCases {
+		answer := Describe(testCase.quality)
+		if !reflect.DeepEqual(answer, testCase.expected) {
+			t.Errorf("ERROR: For %v expected %v, got %v", testCase.quality, testCase.expected, answer)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package speech
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Rule says how to pronounce some text. It matches either a literal,
+// ignoring case, or a regular expression. Plain text engines are given
+// Say in its place; for a regex rule, Say may refer to groups as $1 and
+// so on. SSML engines are given the phoneme, if there is one, and Say
+// otherwise.
+type Rule struct {
+	Match    string `json:"match,omitempty"`
+	Regex    string `json:"regex,omitempty"`
+	Say      string `json:"say,omitempty"`
+	Phoneme  string `json:"phoneme,omitempty"`
+	Alphabet string `json:"alphabet,omitempty"` // of the phoneme: "ipa" (the default) or "x-sampa"
+
+	re *regexp.Regexp
+}
+
+// compile checks a rule and prepares it for use.
+func (r *Rule) compile() error {
+	if (r.Match == "") == (r.Regex == "") {
+		return errors.New("needs one of match or regex")
+	}
+	if r.Say == "" && r.Phoneme == "" {
+		return errors.New("needs say or phoneme")
+	}
+	if r.Alphabet == "" {
+		r.Alphabet = "ipa"
+	}
+
+	var err error
+	if r.Match != "" {
+		r.re, err = regexp.Compile("(?i)" + regexp.QuoteMeta(r.Match))
+	} else {
+		r.re, err = regexp.Compile(r.Regex)
+	}
+
+	return err
+}
+
+// Dictionary holds the rules for pronouncing ship names, applied in
+// order. Each rule sees the text as the rules before it left it. A nil
+// Dictionary changes nothing.
+type Dictionary struct {
+	rules []Rule
+}
+
+//go:embed pronunciations.json
+var defaultRules []byte
+
+// DefaultDictionary returns the pronunciations of the ships we have heard
+// said badly.
+func DefaultDictionary() *Dictionary {
+	d, err := ParseDictionary(defaultRules)
+	if err != nil {
+		panic(fmt.Sprintf("default pronunciations: %v", err))
+	}
+
+	return d
+}
+
+// ParseDictionary reads rules from a JSON list.
+func ParseDictionary(data []byte) (*Dictionary, error) {
+	var rules []Rule
+
+	err := json.Unmarshal(data, &rules)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		err := rules[i].compile()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+
+	return &Dictionary{rules: rules}, nil
+}
+
+// LoadDictionary reads the rules in the file at path, then adds the
+// default rules after them. An empty path gives just the defaults.
+func LoadDictionary(path string) (*Dictionary, error) {
+	d := DefaultDictionary()
+	if path == "" {
+		return d, nil
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	user, err := ParseDictionary(contents)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &Dictionary{rules: append(user.rules, d.rules...)}, nil
+}
+
+// segment is a piece of text that is either still open to the rules, or
+// is finished SSML.
+type segment struct {
+	text   string
+	markup bool
+}
+
+// apply runs the rules over text. For SSML, phonemes become finished
+// markup that later rules leave alone.
+func (d *Dictionary) apply(text string, ssml bool) []segment {
+	segments := []segment{{text: text}}
+	if d == nil {
+		return segments
+	}
+
+	for _, r := range d.rules {
+		var next []segment
+		for _, s := range segments {
+			if s.markup {
+				next = append(next, s)
+				continue
+			}
+
+			last := 0
+			for _, m := range r.re.FindAllStringSubmatchIndex(s.text, -1) {
+				if m[0] == m[1] {
+					continue
+				}
+				next = append(next, segment{text: s.text[last:m[0]]})
+				matched := s.text[m[0]:m[1]]
+				switch {
+				case ssml && r.Phoneme != "":
+					next = append(next, segment{
+						text:   fmt.Sprintf(`<phoneme alphabet="%s" ph="%s">%s</phoneme>`, escape(r.Alphabet), escape(r.Phoneme), escape(matched)),
+						markup: true,
+					})
+				case r.Say != "":
+					next = append(next, segment{text: string(r.re.ExpandString(nil, r.Say, s.text, m))})
+				default:
+					next = append(next, segment{text: matched})
+				}
+				last = m[1]
+			}
+			next = append(next, segment{text: s.text[last:]})
+		}
+		segments = next
+	}
+
+	return segments
+}
+
+// Plain returns text as plain text engines should be given it.
+func (d *Dictionary) Plain(text string) string {
+	var b strings.Builder
+	for _, s := range d.apply(text, false) {
+		b.WriteString(s.text)
+	}
+
+	return b.String()
+}
+
+// SSML returns text as SSML, ready to go inside a <speak> element.
+func (d *Dictionary) SSML(text string) string {
+	var b strings.Builder
+	for _, s := range d.apply(text, true) {
+		if s.markup {
+			b.WriteString(s.text)
+		} else {
+			b.WriteString(escape(s.text))
+		}
+	}
+
+	return b.String()
+}
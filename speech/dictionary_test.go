@@ -0,0 +1,145 @@
+package speech
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDefaultDictionary(t *testing.T) {
+	d := DefaultDictionary()
+
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"SEASPAN HAMBURG", "SEA SPAN HAMBURG"},
+		{"RO-ROZAFER", "ROW ROW ZAFER"},
+		{"RO-RO CARRIER", "ROW ROW CARRIER"},
+		{"ERISORT", "AIRY SORT"},
+		{"T0WBOATUS ALAMEDA", "TOW BOAT U S ALAMEDA"},
+		{"Saildrone 1053", "SAIL DRONE 1053"},
+		{"JOHNZIPSER", "JOHN ZIPSER"},
+		{"EKSPRES 1", "EXPRESS 1"},
+		{"ASSATEAGUE", "ASSA TEAG"},
+		{"HOEGH TRACER", "HOEGH TRACER"},
+	}
+
+	for _, testCase := range testCases {
+		answer := d.Plain(testCase.name)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %q expected %q, got %q", testCase.name, testCase.expected, answer)
+		}
+	}
+}
+
+func TestDictionarySSML(t *testing.T) {
+	d, err := ParseDictionary([]byte(`[
+		{"regex": "^MV (.*)$", "say": "MOTOR VESSEL $1"},
+		{"match": "ASSATEAGUE", "phoneme": "ˈæsəˌtiːɡ"},
+		{"match": "tiːɡ", "say": "should not reach inside a phoneme"}
+	]`))
+	if err != nil {
+		t.Fatalf("ERROR: Unable to parse dictionary: %v", err)
+	}
+
+	testCases := []struct {
+		name  string
+		plain string
+		ssml  string
+	}{
+		{"MV ASSATEAGUE", "MOTOR VESSEL ASSATEAGUE", `MOTOR VESSEL <phoneme alphabet="ipa" ph="ˈæsəˌtiːɡ">ASSATEAGUE</phoneme>`},
+		{"A & B <1>", "A & B <1>", "A &amp; B &lt;1&gt;"},
+	}
+
+	for _, testCase := range testCases {
+		if answer := d.Plain(testCase.name); answer != testCase.plain {
+			t.Errorf("ERROR: For %q expected plain %q, got %q", testCase.name, testCase.plain, answer)
+		}
+		if answer := d.SSML(testCase.name); answer != testCase.ssml {
+			t.Errorf("ERROR: For %q expected SSML %q, got %q", testCase.name, testCase.ssml, answer)
+		}
+	}
+
+	var none *Dictionary
+	if none.Plain("A & B") != "A & B" || none.SSML("A & B") != "A &amp; B" {
+		t.Errorf("ERROR: Expected a nil dictionary to change nothing")
+	}
+}
+
+func TestParseDictionaryErrors(t *testing.T) {
+	testCases := []string{
+		`{"match": "X"}`,
+		`[{"say": "X"}]`,
+		`[{"match": "X", "regex": "X", "say": "Y"}]`,
+		`[{"match": "X"}]`,
+		`[{"regex": "(", "say": "Y"}]`,
+	}
+
+	for _, testCase := range testCases {
+		_, err := ParseDictionary([]byte(testCase))
+		if err == nil {
+			t.Errorf("ERROR: Expected %s to fail", testCase)
+		}
+	}
+}
+
+func TestLoadDictionary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pronunciations.json")
+	err := os.WriteFile(path, []byte(`[{"match": "SEASPAN HAMBURG", "say": "SEE SPAN HAMBURG"}, {"match": "GRANDE", "say": "GRAN DAY"}]`), 0644)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to write dictionary: %v", err)
+	}
+
+	d, err := LoadDictionary(path)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to load dictionary: %v", err)
+	}
+	// The user's rules come first; the defaults still apply.
+	if answer := d.Plain("SEASPAN HAMBURG GRANDE SAILDRONE"); answer != "SEE SPAN HAMBURG GRAN DAY SAIL DRONE" {
+		t.Errorf("ERROR: Expected the user's rules before the defaults, got %q", answer)
+	}
+
+	_, err = LoadDictionary(filepath.Join(t.TempDir(), "missing.json"))
+	if err == nil {
+		t.Errorf("ERROR: Expected a missing file to fail")
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder(DefaultDictionary())
+	b.Text("Ship ahoy! ").Pause(300*time.Millisecond).Name("SAILDRONE").Text(". Course ").SayAs("digits", "270", "2 70")
+	b.Text(". Call sign ").Characters("WDC1234").Text(". ").Number(12).Text(" previous sightings.")
+
+	u := b.Utterance()
+	expectedText := "Ship ahoy! SAIL DRONE. Course 2 70. Call sign W D C 1 2 3 4. 12 previous sightings."
+	expectedSSML := `<speak>Ship ahoy! <break time="300ms"/>SAIL DRONE. Course <say-as interpret-as="digits">270</say-as>. ` +
+		`Call sign <say-as interpret-as="characters">WDC1234</say-as>. <say-as interpret-as="cardinal">12</say-as> previous sightings.</speak>`
+
+	if u.Text != expectedText {
+		t.Errorf("ERROR: Expected text %q, got %q", expectedText, u.Text)
+	}
+	if u.SSML != expectedSSML {
+		t.Errorf("ERROR: Expected SSML %q, got %q", expectedSSML, u.SSML)
+	}
+}
+
+func TestSpeak(t *testing.T) {
+	ran := fakeEngines(t, true)
+	plain := &fakeProvider{name: "plain"}
+	u := Utterance{Text: "Ship ahoy!", SSML: "<speak>Ship ahoy!</speak>"}
+
+	err := Speak(NewFallback(&Espeak{Path: "espeak-ng"}), u)
+	if err != nil {
+		t.Errorf("ERROR: Unable to speak: %v", err)
+	}
+	Speak(plain, u)
+
+	if len(*ran) != 1 || (*ran)[0][1] != "-m" || (*ran)[0][3] != u.SSML {
+		t.Errorf("ERROR: Expected espeak-ng to be given SSML, got %v", *ran)
+	}
+	if len(plain.said) != 1 || plain.said[0] != u.Text {
+		t.Errorf("ERROR: Expected a plain provider to be given text, got %v", plain.said)
+	}
+}
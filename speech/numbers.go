@@ -0,0 +1,162 @@
+package speech
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Numbers are spelled out the way they are said on the radio, so that
+// engines do not have to guess: "270" is read as "two hundred seventy" by
+// some and "two seven zero" by others, and "2O5" by neither.
+
+var (
+	ones = []string{
+		"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen",
+	}
+	tens = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+)
+
+// Words spells out a whole number, such as "six hundred fifty six".
+func Words(n int64) string {
+	if n < 0 {
+		return "minus " + Words(-n)
+	}
+	if n < 20 {
+		return ones[n]
+	}
+	if n < 100 {
+		if n%10 == 0 {
+			return tens[n/10]
+		}
+		return tens[n/10] + " " + ones[n%10]
+	}
+
+	for _, scale := range []struct {
+		size int64
+		name string
+	}{
+		{1000000000, "billion"},
+		{1000000, "million"},
+		{1000, "thousand"},
+		{100, "hundred"},
+	} {
+		if n >= scale.size {
+			words := Words(n/scale.size) + " " + scale.name
+			if n%scale.size != 0 {
+				words += " " + Words(n%scale.size)
+			}
+			return words
+		}
+	}
+
+	return ""
+}
+
+// Digits spells out each digit of a string of them, such as "zero four
+// five". Anything else is left as it is.
+func Digits(s string) string {
+	var words []string
+
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			words = append(words, ones[r-'0'])
+		} else {
+			words = append(words, string(r))
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+// Decimal spells out a number to one decimal place, such as "nine point
+// five". Whole numbers have no "point zero".
+func Decimal(f float64) string {
+	f = math.Round(f*10) / 10
+	whole := Words(int64(f))
+	if f < 0 && f > -1 {
+		whole = "minus " + whole
+	}
+
+	tenths := int64(math.Round(math.Abs(f)*10)) % 10
+	if tenths == 0 {
+		return whole
+	}
+
+	return whole + " point " + ones[tenths]
+}
+
+// plural adds an s to a unit unless there is exactly one of it.
+func plural(amount, unit string) string {
+	if amount == "one" {
+		return amount + " " + unit
+	}
+	return amount + " " + unit + "s"
+}
+
+// Bearing spells out a compass bearing digit by digit, always three of
+// them, such as "two seven zero" or "zero zero five".
+func Bearing(degrees float64) string {
+	d := int(math.Round(degrees)) % 360
+	if d < 0 {
+		d += 360
+	}
+
+	return Digits(strconv.Itoa(1000 + d)[1:])
+}
+
+// Speed spells out a speed through the water, such as "nine point five
+// knots".
+func Speed(knots float64) string {
+	return plural(Decimal(knots), "knot")
+}
+
+// Distance spells out a distance at sea. Under a mile it is in cables, a
+// tenth of a nautical mile; beyond that in nautical miles.
+func Distance(nauticalMiles float64) string {
+	if nauticalMiles < 0.05 {
+		return "less than a cable"
+	}
+	if nauticalMiles < 0.95 {
+		return plural(Words(int64(math.Round(nauticalMiles*10))), "cable")
+	}
+
+	return plural(Decimal(nauticalMiles), "nautical mile")
+}
+
+// Length spells out a length, such as a ship's, to the nearest meter or
+// foot.
+func Length(meters float64, imperial bool) string {
+	if imperial {
+		feet := Words(int64(math.Round(meters / 0.3048)))
+		if feet == "one" {
+			return "one foot"
+		}
+		return feet + " feet"
+	}
+
+	return plural(Words(int64(math.Round(meters))), "meter")
+}
+
+// ClockHour returns where something is as a clock bearing: twelve is
+// straight ahead of the way the observer faces, three to their right.
+// Bearings are in degrees true.
+func ClockHour(bearing, facing float64) int64 {
+	relative := math.Mod(bearing-facing, 360)
+	if relative < 0 {
+		relative += 360
+	}
+
+	hour := int64(math.Round(relative/30)) % 12
+	if hour == 0 {
+		hour = 12
+	}
+
+	return hour
+}
+
+// Clock spells out a clock bearing, such as "two o'clock".
+func Clock(bearing, facing float64) string {
+	return Words(ClockHour(bearing, facing)) + " o'clock"
+}
@@ -0,0 +1,158 @@
+package speech
+
+import (
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	testCases := []struct {
+		n        int64
+		expected string
+	}{
+		{0, "zero"},
+		{7, "seven"},
+		{13, "thirteen"},
+		{40, "forty"},
+		{45, "forty five"},
+		{100, "one hundred"},
+		{656, "six hundred fifty six"},
+		{1053, "one thousand fifty three"},
+		{2500000, "two million five hundred thousand"},
+		{-3, "minus three"},
+	}
+
+	for _, testCase := range testCases {
+		answer := Words(testCase.n)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %d expected %q, got %q", testCase.n, testCase.expected, answer)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	testCases := []struct {
+		f        float64
+		expected string
+	}{
+		{11, "eleven"},
+		{11.0001, "eleven"},
+		{9.5, "nine point five"},
+		{0.3, "zero point three"},
+		{2.96, "three"},
+		{-0.5, "minus zero point five"},
+		{-1.2, "minus one point two"},
+	}
+
+	for _, testCase := range testCases {
+		answer := Decimal(testCase.f)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %v expected %q, got %q", testCase.f, testCase.expected, answer)
+		}
+	}
+}
+
+func TestBearing(t *testing.T) {
+	testCases := []struct {
+		degrees  float64
+		expected string
+	}{
+		{270, "two seven zero"},
+		{205, "two zero five"},
+		{45, "zero four five"},
+		{5, "zero zero five"},
+		{0, "zero zero zero"},
+		{359.6, "zero zero zero"},
+		{-90, "two seven zero"},
+	}
+
+	for _, testCase := range testCases {
+		answer := Bearing(testCase.degrees)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %v expected %q, got %q", testCase.degrees, testCase.expected, answer)
+		}
+	}
+}
+
+func TestSpeed(t *testing.T) {
+	testCases := []struct {
+		knots    float64
+		expected string
+	}{
+		{11, "eleven knots"},
+		{9.5, "nine point five knots"},
+		{1, "one knot"},
+		{0, "zero knots"},
+	}
+
+	for _, testCase := range testCases {
+		answer := Speed(testCase.knots)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %v expected %q, got %q", testCase.knots, testCase.expected, answer)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	testCases := []struct {
+		nauticalMiles float64
+		expected      string
+	}{
+		{0.02, "less than a cable"},
+		{0.1, "one cable"},
+		{0.34, "three cables"},
+		{0.9, "nine cables"},
+		{1, "one nautical mile"},
+		{2.1, "two point one nautical miles"},
+		{12, "twelve nautical miles"},
+	}
+
+	for _, testCase := range testCases {
+		answer := Distance(testCase.nauticalMiles)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %v expected %q, got %q", testCase.nauticalMiles, testCase.expected, answer)
+		}
+	}
+}
+
+func TestLength(t *testing.T) {
+	testCases := []struct {
+		meters   float64
+		imperial bool
+		expected string
+	}{
+		{200, false, "two hundred meters"},
+		{1, false, "one meter"},
+		{200, true, "six hundred fifty six feet"},
+		{0.3, true, "one foot"},
+	}
+
+	for _, testCase := range testCases {
+		answer := Length(testCase.meters, testCase.imperial)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For %v (imperial %t) expected %q, got %q", testCase.meters, testCase.imperial, testCase.expected, answer)
+		}
+	}
+}
+
+func TestClock(t *testing.T) {
+	testCases := []struct {
+		bearing  float64
+		facing   float64
+		expected string
+	}{
+		{0, 0, "twelve o'clock"},
+		{60, 0, "two o'clock"},
+		{90, 0, "three o'clock"},
+		{270, 0, "nine o'clock"},
+		{10, 350, "one o'clock"},
+		{340, 20, "eleven o'clock"},
+		{355, 0, "twelve o'clock"},
+	}
+
+	for _, testCase := range testCases {
+		answer := Clock(testCase.bearing, testCase.facing)
+		if answer != testCase.expected {
+			t.Errorf("ERROR: For bearing %v facing %v expected %q, got %q", testCase.bearing, testCase.facing, testCase.expected, answer)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Package speech reads text out loud. Google Cloud text-to-speech sounds
+// best but needs credentials and the internet; local engines such as
+// espeak-ng and piper work without either. A Fallback tries each in turn
+// so announcements are still made during an outage.
+package speech
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erikbryant/beepspeak"
+)
+
+// Provider reads text out loud.
+type Provider interface {
+	Name() string
+	Say(text string) error
+}
+
+// ErrUnavailable is returned by a provider that cannot be used here, such
+// as an engine that is not installed.
+var ErrUnavailable = errors.New("unavailable")
+
+// run runs an engine; swapped out in tests.
+var run = func(cmd *exec.Cmd) error {
+	out, err := cmd.CombinedOutput()
+	if err != nil && len(out) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return err
+}
+
+// lookPath finds an engine; swapped out in tests.
+var lookPath = exec.LookPath
+
+// Cloud speaks through Google Cloud text-to-speech.
+type Cloud struct {
+	ready bool
+	say   func(text string) error
+}
+
+// NewCloud returns a provider that speaks through beepspeak. Pass whether
+// its credentials loaded; without them it is unavailable.
+func NewCloud(ready bool) *Cloud {
+	return &Cloud{ready: ready, say: beepspeak.Say}
+}
+
+// Name returns "google".
+func (c *Cloud) Name() string {
+	return "google"
+}
+
+// Say reads the text out loud.
+func (c *Cloud) Say(text string) error {
+	if !c.ready {
+		return fmt.Errorf("%w: no credentials", ErrUnavailable)
+	}
+
+	return c.say(text)
+}
+
+// Espeak speaks through a local espeak-ng. It plays the sound itself.
+type Espeak struct {
+	Path  string
+	Voice string
+	Speed int // words per minute
+}
+
+// Name returns "espeak-ng".
+func (e *Espeak) Name() string {
+	return "espeak-ng"
+}
+
+// Say reads the text out loud.
+func (e *Espeak) Say(text string) error {
+	return e.speak(text, false)
+}
+
+// SaySSML reads SSML out loud.
+func (e *Espeak) SaySSML(ssml string) error {
+	return e.speak(ssml, true)
+}
+
+// speak runs espeak-ng, telling it whether the input is markup.
+func (e *Espeak) speak(input string, ssml bool) error {
+	path, err := lookPath(e.Path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	args := []string{}
+	if ssml {
+		args = append(args, "-m")
+	}
+	if e.Voice != "" {
+		args = append(args, "-v", e.Voice)
+	}
+	if e.Speed > 0 {
+		args = append(args, "-s", strconv.Itoa(e.Speed))
+	}
+	args = append(args, "--", input)
+
+	return run(exec.Command(path, args...))
+}
+
+// Piper speaks through a local piper, a neural engine that sounds closer
+// to the cloud voices. It writes a WAV file, which is then played.
+type Piper struct {
+	Path  string
+	Model string // the .onnx voice to use
+	play  func(file string) error
+}
+
+// NewPiper returns a provider that runs piper with a voice model.
+func NewPiper(path, model string) *Piper {
+	return &Piper{Path: path, Model: model, play: beepspeak.Play}
+}
+
+// Name returns "piper".
+func (p *Piper) Name() string {
+	return "piper"
+}
+
+// Say reads the text out loud.
+func (p *Piper) Say(text string) error {
+	path, err := lookPath(p.Path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if p.Model == "" {
+		return fmt.Errorf("%w: no voice model", ErrUnavailable)
+	}
+
+	f, err := os.CreateTemp("", "shipahoy-*.wav")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	cmd := exec.Command(path, "--model", p.Model, "--output_file", f.Name())
+	cmd.Stdin = strings.NewReader(text)
+	err = run(cmd)
+	if err != nil {
+		return err
+	}
+
+	return p.play(f.Name())
+}
+
+// How long to leave a provider alone after it fails, so that every
+// announcement during an outage does not wait for it to fail again.
+const cooldown = time.Minute
+
+// Fallback tries its providers in order until one speaks.
+type Fallback struct {
+	providers []Provider
+
+	mu     sync.Mutex
+	failed map[string]time.Time
+	now    func() time.Time
+}
+
+// NewFallback returns a provider that tries each of providers in turn.
+func NewFallback(providers ...Provider) *Fallback {
+	return &Fallback{
+		providers: providers,
+		failed:    map[string]time.Time{},
+		now:       time.Now,
+	}
+}
+
+// Name lists the providers, in order.
+func (f *Fallback) Name() string {
+	var names []string
+	for _, p := range f.providers {
+		names = append(names, p.Name())
+	}
+
+	return strings.Join(names, ", then ")
+}
+
+// resting returns whether a provider failed too recently to try again.
+// The last provider is always tried; silence helps no one.
+func (f *Fallback) resting(i int) bool {
+	if i == len(f.providers)-1 {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	failed, ok := f.failed[f.providers[i].Name()]
+	return ok && f.now().Sub(failed) < cooldown
+}
+
+// Say reads the text out loud with the first provider that can.
+func (f *Fallback) Say(text string) error {
+	return f.SayUtterance(Utterance{Text: text})
+}
+
+// SayUtterance reads an utterance out loud with the first provider that can.
+func (f *Fallback) SayUtterance(u Utterance) error {
+	var errs []error
+
+	for i, p := range f.providers {
+		if f.resting(i) {
+			continue
+		}
+
+		err := Speak(p, u)
+
+		f.mu.Lock()
+		if err != nil {
+			f.failed[p.Name()] = f.now()
+		} else {
+			delete(f.failed, p.Name())
+		}
+		f.mu.Unlock()
+
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("%w: no speech providers", ErrUnavailable)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Config says which providers to use, in the order to try them.
+type Config struct {
+	Providers   []string `json:"providers"` // any of "google", "piper", "espeak-ng"
+	EspeakPath  string   `json:"espeakPath"`
+	EspeakVoice string   `json:"espeakVoice"`
+	EspeakSpeed int      `json:"espeakSpeed"`
+	PiperPath   string   `json:"piperPath"`
+	PiperModel  string   `json:"piperModel"`
+	Dictionary  string   `json:"dictionary"` // file of pronunciations to use before the defaults
+}
+
+// DefaultConfig returns Google's voices, falling back to piper (if it has
+// a voice model) and then espeak-ng.
+func DefaultConfig() Config {
+	return Config{
+		Providers:   []string{"google", "piper", "espeak-ng"},
+		EspeakPath:  "espeak-ng",
+		EspeakVoice: "en-us",
+		EspeakSpeed: 160,
+		PiperPath:   "piper",
+	}
+}
+
+// New returns the configured providers, tried in order. Pass whether the
+// Google credentials loaded.
+func New(cfg Config, cloudReady bool) (*Fallback, error) {
+	var providers []Provider
+
+	for _, name := range cfg.Providers {
+		switch name {
+		case "google":
+			providers = append(providers, NewCloud(cloudReady))
+		case "piper":
+			providers = append(providers, NewPiper(cfg.PiperPath, cfg.PiperModel))
+		case "espeak-ng":
+			providers = append(providers, &Espeak{Path: cfg.EspeakPath, Voice: cfg.EspeakVoice, Speed: cfg.EspeakSpeed})
+		default:
+			return nil, fmt.Errorf("unknown speech provider %q", name)
+		}
+	}
+
+	return NewFallback(providers...), nil
+}
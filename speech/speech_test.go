@@ -0,0 +1,147 @@
+package speech
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeProvider says everything it is asked to, unless it is broken.
+type fakeProvider struct {
+	name   string
+	broken bool
+	said   []string
+}
+
+func (f *fakeProvider) Name() string {
+	return f.name
+}
+
+func (f *fakeProvider) Say(text string) error {
+	if f.broken {
+		return errors.New("no network")
+	}
+	f.said = append(f.said, text)
+	return nil
+}
+
+func TestFallback(t *testing.T) {
+	cloud := &fakeProvider{name: "google", broken: true}
+	local := &fakeProvider{name: "espeak-ng"}
+
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	f := NewFallback(cloud, local)
+	f.now = func() time.Time { return now }
+
+	// The cloud is down; the local engine speaks.
+	err := f.Say("Ship ahoy!")
+	if err != nil || !reflect.DeepEqual(local.said, []string{"Ship ahoy!"}) {
+		t.Errorf("ERROR: Expected the fallback to speak, got %v %v", local.said, err)
+	}
+
+	// The cloud comes back, but is left alone for a while.
+	cloud.broken = false
+	now = now.Add(cooldown / 2)
+	f.Say("Again")
+	if len(cloud.said) != 0 || len(local.said) != 2 {
+		t.Errorf("ERROR: Expected a failed provider to rest, got %v %v", cloud.said, local.said)
+	}
+
+	now = now.Add(cooldown)
+	f.Say("Once more")
+	if !reflect.DeepEqual(cloud.said, []string{"Once more"}) || len(local.said) != 2 {
+		t.Errorf("ERROR: Expected the first provider once rested, got %v %v", cloud.said, local.said)
+	}
+
+	// Everything is down.
+	cloud.broken, local.broken = true, true
+	err = f.Say("Anyone?")
+	if err == nil {
+		t.Errorf("ERROR: Expected an error when no provider can speak")
+	}
+
+	err = NewFallback().Say("Hello")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("ERROR: Expected no providers to be unavailable, got %v", err)
+	}
+}
+
+// fakeEngines pretends the engines are installed and records what is run.
+func fakeEngines(t *testing.T, installed bool) *[][]string {
+	var ran [][]string
+
+	oldRun, oldLookPath := run, lookPath
+	t.Cleanup(func() { run, lookPath = oldRun, oldLookPath })
+
+	lookPath = func(file string) (string, error) {
+		if !installed {
+			return "", exec.ErrNotFound
+		}
+		return "/usr/bin/" + file, nil
+	}
+	run = func(cmd *exec.Cmd) error {
+		ran = append(ran, cmd.Args)
+		return nil
+	}
+
+	return &ran
+}
+
+func TestEngines(t *testing.T) {
+	ran := fakeEngines(t, true)
+
+	err := (&Espeak{Path: "espeak-ng", Voice: "en-us", Speed: 160}).Say("Ship ahoy!")
+	if err != nil {
+		t.Errorf("ERROR: Unable to speak: %v", err)
+	}
+
+	var played string
+	piper := NewPiper("piper", "en_US-lessac-medium.onnx")
+	piper.play = func(file string) error { played = file; return nil }
+	err = piper.Say("Ship ahoy!")
+	if err != nil {
+		t.Errorf("ERROR: Unable to speak: %v", err)
+	}
+
+	expected := [][]string{
+		{"/usr/bin/espeak-ng", "-v", "en-us", "-s", "160", "--", "Ship ahoy!"},
+		{"/usr/bin/piper", "--model", "en_US-lessac-medium.onnx", "--output_file", played},
+	}
+	if !reflect.DeepEqual(*ran, expected) {
+		t.Errorf("ERROR: Expected %v, got %v", expected, *ran)
+	}
+
+	err = NewPiper("piper", "").Say("Ship ahoy!")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("ERROR: Expected piper without a model to be unavailable, got %v", err)
+	}
+}
+
+func TestEnginesMissing(t *testing.T) {
+	fakeEngines(t, false)
+
+	providers := []Provider{&Espeak{Path: "espeak-ng"}, NewPiper("piper", "voice.onnx"), NewCloud(false)}
+	for _, p := range providers {
+		err := p.Say("Ship ahoy!")
+		if !errors.Is(err, ErrUnavailable) {
+			t.Errorf("ERROR: For %s expected unavailable, got %v", p.Name(), err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	f, err := New(DefaultConfig(), false)
+	if err != nil {
+		t.Fatalf("ERROR: Unable to make providers: %v", err)
+	}
+	if f.Name() != "google, then piper, then espeak-ng" {
+		t.Errorf("ERROR: Expected the configured order, got %s", f.Name())
+	}
+
+	_, err = New(Config{Providers: []string{"google", "festival"}}, true)
+	if err == nil {
+		t.Errorf("ERROR: Expected an unknown provider to fail")
+	}
+}
@@ -0,0 +1,108 @@
+package speech
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Utterance is something to say, both as plain text and as SSML, so that
+// each engine can be given what it understands.
+type Utterance struct {
+	Text string
+	SSML string // a complete <speak> document; empty if there is none
+}
+
+// SSMLProvider is a provider that understands SSML.
+type SSMLProvider interface {
+	Provider
+	SaySSML(ssml string) error
+}
+
+// Speak says an utterance with a provider, as SSML if it understands it.
+func Speak(p Provider, u Utterance) error {
+	switch p := p.(type) {
+	case *Fallback:
+		return p.SayUtterance(u)
+	case SSMLProvider:
+		if u.SSML != "" {
+			return p.SaySSML(u.SSML)
+		}
+	}
+
+	return p.Say(u.Text)
+}
+
+// escape makes text safe to put in SSML.
+var escape = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+	"'", "&apos;",
+).Replace
+
+// Builder puts an utterance together a piece at a time.
+type Builder struct {
+	dict *Dictionary
+	text strings.Builder
+	ssml strings.Builder
+}
+
+// NewBuilder returns a builder that pronounces names with a dictionary.
+func NewBuilder(dict *Dictionary) *Builder {
+	return &Builder{dict: dict}
+}
+
+// Text adds text to be read as it is.
+func (b *Builder) Text(text string) *Builder {
+	b.text.WriteString(text)
+	b.ssml.WriteString(escape(text))
+	return b
+}
+
+// Textf adds formatted text to be read as it is.
+func (b *Builder) Textf(format string, a ...interface{}) *Builder {
+	return b.Text(fmt.Sprintf(format, a...))
+}
+
+// Name adds a name, pronounced by the dictionary.
+func (b *Builder) Name(name string) *Builder {
+	b.text.WriteString(b.dict.Plain(name))
+	b.ssml.WriteString(b.dict.SSML(name))
+	return b
+}
+
+// SayAs adds a value that SSML engines are told how to read, such as
+// "cardinal", "digits" or "characters". Plain text engines are given
+// plain instead.
+func (b *Builder) SayAs(interpretAs, value, plain string) *Builder {
+	b.text.WriteString(plain)
+	fmt.Fprintf(&b.ssml, `<say-as interpret-as="%s">%s</say-as>`, escape(interpretAs), escape(value))
+	return b
+}
+
+// Number adds a number to be read as a quantity.
+func (b *Builder) Number(n int64) *Builder {
+	s := fmt.Sprintf("%d", n)
+	return b.SayAs("cardinal", s, s)
+}
+
+// Characters adds text, such as a call sign, to be spelled out.
+func (b *Builder) Characters(text string) *Builder {
+	return b.SayAs("characters", text, strings.Join(strings.Split(text, ""), " "))
+}
+
+// Pause adds a pause. Plain text engines pause at the punctuation instead.
+func (b *Builder) Pause(d time.Duration) *Builder {
+	fmt.Fprintf(&b.ssml, `<break time="%dms"/>`, d.Milliseconds())
+	return b
+}
+
+// Utterance returns what has been built.
+func (b *Builder) Utterance() Utterance {
+	return Utterance{
+		Text: b.text.String(),
+		SSML: "<speak>" + b.ssml.String() + "</speak>",
+	}
+}
@@ -0,0 +1,308 @@
+package shipahoy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/erikbryant/shipahoy/alert"
+	"github.com/erikbryant/shipahoy/database"
+)
+
+var (
+	// How many times to try a write before giving up on it for now.
+	storageAttempts = 3
+
+	// How long to wait between tries.
+	storageRetryDelay = 2 * time.Second
+
+	// The most position reports to hold on to while the database is down.
+	maxBufferedPositions = 10000
+
+	// Say something once this many writes in a row have failed.
+	storageAlertAfter = 5
+
+	// How to say it.
+	storageAlert = alert.StorageFailure
+
+	// How to say it is working again.
+	storageRecovered = func() error { return publisher.DatabaseUp() }
+
+	// Everything lookAtShips writes goes through here.
+	db = newStorage()
+
+	// errStorageDown is returned for writes not tried because the last one failed.
+	errStorageDown = errors.New("database is down; not tried")
+)
+
+// pendingSighting is a sighting that could not be written yet.
+type pendingSighting struct {
+	details database.Ship
+	myLat   float64
+	myLon   float64
+}
+
+// storage decides what to do when the database fails. Writes are retried
+// a few times. Once one has failed, the others are not: positions,
+// sightings and anomalies are held, and ship details dropped, until the
+// positions written at the end of a scan get through. If it stays down,
+// we say so.
+type storage struct {
+	mu        sync.Mutex
+	positions []database.Position
+	sightings []pendingSighting
+	anomalies []database.Anomaly
+	failures  int
+	alerted   bool
+
+	// When we last alerted for each ship, in case the database cannot tell us.
+	lastAlert map[string]int64
+}
+
+// newStorage returns a storage with nothing pending.
+func newStorage() *storage {
+	return &storage{
+		lastAlert: map[string]int64{},
+	}
+}
+
+// down returns whether the last write failed.
+func (s *storage) down() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.failures > 0
+}
+
+// retry calls write until it succeeds or we run out of attempts. While
+// the database is down it is called only once.
+func (s *storage) retry(ctx context.Context, write func(context.Context) error) error {
+	attempts := storageAttempts
+	if s.down() {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = write(ctx)
+		if err == nil || attempt == attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(storageRetryDelay):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	s.result(err)
+
+	return err
+}
+
+// result keeps count of consecutive failures and alerts when there are too many.
+func (s *storage) result(err error) {
+	s.mu.Lock()
+
+	if err == nil {
+		recovered := s.alerted
+		s.failures = 0
+		s.alerted = false
+		s.mu.Unlock()
+		if recovered {
+			fmt.Println("Database is saving ships again")
+			err := storageRecovered()
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+		return
+	}
+
+	fmt.Println("Database error:", err)
+	s.failures++
+	raise := s.failures >= storageAlertAfter && !s.alerted
+	if raise {
+		s.alerted = true
+	}
+
+	s.mu.Unlock()
+
+	if raise {
+		err := storageAlert(err)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// saveShip writes ship details. They are not held if the write fails, or
+// written while the database is down; the ship will be reported again on
+// the next scan.
+func (s *storage) saveShip(ctx context.Context, details database.Ship, source string) error {
+	if s.down() {
+		return errStorageDown
+	}
+
+	return s.retry(ctx, func(ctx context.Context) error {
+		return database.SaveShip(ctx, details, source)
+	})
+}
+
+// saveAnomaly writes an anomaly. While the database is down it is held
+// until the positions get through.
+func (s *storage) saveAnomaly(ctx context.Context, a database.Anomaly) error {
+	if s.down() {
+		s.mu.Lock()
+		s.anomalies = append(s.anomalies, a)
+		s.mu.Unlock()
+		return errStorageDown
+	}
+
+	err := s.retry(ctx, func(ctx context.Context) error {
+		return database.SaveAnomaly(ctx, a)
+	})
+	if err != nil {
+		s.mu.Lock()
+		s.anomalies = append(s.anomalies, a)
+		s.mu.Unlock()
+	}
+
+	return err
+}
+
+// saveNoaaDatum writes a tide or air gap reading. It is not held if the
+// write fails; there will be another reading shortly.
+func (s *storage) saveNoaaDatum(ctx context.Context, datum database.NoaaDatum) error {
+	return s.retry(ctx, func(ctx context.Context) error {
+		return database.SaveNoaaDatum(ctx, datum)
+	})
+}
+
+// saveNoaaData writes tide predictions or other NOAA readings. They are
+// not held if the write fails.
+func (s *storage) saveNoaaData(ctx context.Context, data []database.NoaaDatum) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	return s.retry(ctx, func(ctx context.Context) error {
+		return database.SaveNoaaData(ctx, data)
+	})
+}
+
+// savePositions writes position reports, along with any held from earlier
+// failures. If the write fails they are all held for next time. If it
+// succeeds, the sightings and anomalies held are written too.
+func (s *storage) savePositions(ctx context.Context, positions []database.Position) error {
+	s.mu.Lock()
+	all := append(s.positions, positions...)
+	s.positions = nil
+	s.mu.Unlock()
+
+	if len(all) == 0 {
+		return nil
+	}
+
+	err := s.retry(ctx, func(ctx context.Context) error {
+		return database.SavePositions(ctx, all)
+	})
+	if err != nil {
+		// Keep the newest reports.
+		if len(all) > maxBufferedPositions {
+			all = all[len(all)-maxBufferedPositions:]
+		}
+		s.mu.Lock()
+		s.positions = append(all, s.positions...)
+		s.mu.Unlock()
+		return err
+	}
+
+	return s.flush(ctx)
+}
+
+// flush writes the sightings and anomalies held, stopping at the first
+// that fails. A held sighting is stamped with the time it is finally
+// written.
+func (s *storage) flush(ctx context.Context) error {
+	s.mu.Lock()
+	sightings, anomalies := s.sightings, s.anomalies
+	s.sightings, s.anomalies = nil, nil
+	s.mu.Unlock()
+
+	for i, p := range sightings {
+		err := s.retry(ctx, func(ctx context.Context) error {
+			return database.SaveSighting(ctx, p.details, p.myLat, p.myLon)
+		})
+		if err != nil {
+			s.mu.Lock()
+			s.sightings = append(sightings[i:], s.sightings...)
+			s.anomalies = append(anomalies, s.anomalies...)
+			s.mu.Unlock()
+			return err
+		}
+	}
+	for i, a := range anomalies {
+		err := s.retry(ctx, func(ctx context.Context) error {
+			return database.SaveAnomaly(ctx, a)
+		})
+		if err != nil {
+			s.mu.Lock()
+			s.anomalies = append(anomalies[i:], s.anomalies...)
+			s.mu.Unlock()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveSighting writes a sighting, along with any held from earlier
+// failures. If the write fails, or the database is down, the sightings are
+// held for next time.
+func (s *storage) saveSighting(ctx context.Context, details database.Ship, myLat, myLon float64) error {
+	s.mu.Lock()
+	s.lastAlert[details.MMSI] = time.Now().Unix()
+	s.sightings = append(s.sightings, pendingSighting{details: details, myLat: myLat, myLon: myLon})
+	s.mu.Unlock()
+
+	if s.down() {
+		return errStorageDown
+	}
+
+	return s.flush(ctx)
+}
+
+// lastSighting returns when we last saw the ship. If the database cannot
+// be read it falls back to when this program last alerted for it.
+func (s *storage) lastSighting(ctx context.Context, details database.Ship) int64 {
+	s.mu.Lock()
+	last := s.lastAlert[details.MMSI]
+	s.mu.Unlock()
+
+	timestamp, err := database.LookupLastSighting(ctx, details)
+	if err != nil {
+		fmt.Println("Database error:", err)
+		return last
+	}
+
+	if timestamp > last {
+		last = timestamp
+	}
+
+	return last
+}
+
+// lastPosition returns the stored position of a ship for the anomaly detector.
+func lastPosition(mmsi string) (database.Position, bool) {
+	p, ok, err := database.LookupLastPosition(context.Background(), mmsi)
+	if err != nil {
+		fmt.Println("Database error:", err)
+	}
+
+	return p, ok
+}
@@ -0,0 +1,159 @@
+package shipahoy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/erikbryant/shipahoy/database"
+)
+
+// flakyStore is a store whose writes fail while it is down.
+type flakyStore struct {
+	database.Store
+	down   bool
+	writes int
+}
+
+func (f *flakyStore) SaveShip(ctx context.Context, details database.Ship, source string) error {
+	f.writes++
+	if f.down {
+		return errors.New("database is down")
+	}
+	return f.Store.SaveShip(ctx, details, source)
+}
+
+func (f *flakyStore) SaveAnomaly(ctx context.Context, a database.Anomaly) error {
+	f.writes++
+	if f.down {
+		return errors.New("database is down")
+	}
+	return f.Store.SaveAnomaly(ctx, a)
+}
+
+func (f *flakyStore) SavePositions(ctx context.Context, positions []database.Position) error {
+	f.writes++
+	if f.down {
+		return errors.New("database is down")
+	}
+	return f.Store.SavePositions(ctx, positions)
+}
+
+func (f *flakyStore) SaveSighting(ctx context.Context, details database.Ship, myLat, myLon float64) error {
+	f.writes++
+	if f.down {
+		return errors.New("database is down")
+	}
+	return f.Store.SaveSighting(ctx, details, myLat, myLon)
+}
+
+func (f *flakyStore) LookupLastSighting(ctx context.Context, details database.Ship) (int64, error) {
+	if f.down {
+		return 0, errors.New("database is down")
+	}
+	return f.Store.LookupLastSighting(ctx, details)
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+
+	flaky := &flakyStore{Store: database.NewMemoryStore(), down: true}
+	database.Use(flaky)
+
+	storageRetryDelay = 0
+	storageAlertAfter = 2
+	alerts := 0
+	storageAlert = func(err error) error {
+		alerts++
+		return nil
+	}
+
+	s := newStorage()
+	details := database.Ship{MMSI: "366990520", Name: "DEL NORTE"}
+
+	// The database is down. Everything is held and we are told once.
+	if s.savePositions(ctx, []database.Position{{MMSI: "366990520", Timestamp: 100, Source: "test"}}) == nil {
+		t.Errorf("ERROR: Expected savePositions to fail while the database is down")
+	}
+	if s.saveSighting(ctx, details, 37.8, -122.4) == nil {
+		t.Errorf("ERROR: Expected saveSighting to fail while the database is down")
+	}
+	s.savePositions(ctx, []database.Position{{MMSI: "366990520", Timestamp: 200, Source: "test"}})
+
+	if alerts != 1 {
+		t.Errorf("ERROR: Expected one alert, got %d", alerts)
+	}
+	if len(s.positions) != 2 || len(s.sightings) != 1 {
+		t.Errorf("ERROR: Expected 2 positions and 1 sighting held, got %d and %d", len(s.positions), len(s.sightings))
+	}
+
+	// We remember the alert even though the database could not.
+	if s.lastSighting(ctx, details) == 0 {
+		t.Errorf("ERROR: Expected lastSighting to fall back to the alert time")
+	}
+
+	// The database is back. What was held is written.
+	flaky.down = false
+
+	err := s.savePositions(ctx, nil)
+	if err != nil {
+		t.Errorf("ERROR: Expected held positions to be written, got %v", err)
+	}
+	err = s.saveSighting(ctx, database.Ship{MMSI: "367123640"}, 37.8, -122.4)
+	if err != nil {
+		t.Errorf("ERROR: Expected held sightings to be written, got %v", err)
+	}
+
+	positions, _ := flaky.CountRows(ctx, "positions")
+	sightings, _ := flaky.CountRows(ctx, "sightings")
+	if positions != 2 || sightings != 2 {
+		t.Errorf("ERROR: Expected 2 positions and 2 sightings stored, got %d and %d", positions, sightings)
+	}
+	if len(s.positions) != 0 || len(s.sightings) != 0 || s.failures != 0 {
+		t.Errorf("ERROR: Expected nothing held, got %d positions, %d sightings, %d failures", len(s.positions), len(s.sightings), s.failures)
+	}
+}
+
+func TestStorageDown(t *testing.T) {
+	flaky := &flakyStore{Store: database.NewMemoryStore(), down: true}
+	database.Use(flaky)
+
+	storageRetryDelay = time.Hour
+	storageAlert = func(err error) error { return nil }
+	t.Cleanup(func() { storageRetryDelay = 2 * time.Second })
+
+	s := newStorage()
+
+	// Waiting to retry stops when the context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if s.savePositions(ctx, []database.Position{{MMSI: "366990520", Timestamp: 100, Source: "test"}}) == nil {
+		t.Errorf("ERROR: Expected savePositions to fail while the database is down")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("ERROR: Expected the retry to stop with the context, took %v", time.Since(start))
+	}
+
+	// Once it has failed, ships are not tried; what can be held is.
+	writes := flaky.writes
+	ctx = context.Background()
+	details := database.Ship{MMSI: "366990520", Name: "DEL NORTE"}
+	err := s.saveShip(ctx, details, "test")
+	if !errors.Is(err, errStorageDown) {
+		t.Errorf("ERROR: Expected saveShip not to be tried, got %v", err)
+	}
+	s.saveSighting(ctx, details, 37.8, -122.4)
+	s.saveAnomaly(ctx, database.Anomaly{MMSI: "366990520", Timestamp: 100})
+	if flaky.writes != writes || len(s.sightings) != 1 || len(s.anomalies) != 1 {
+		t.Errorf("ERROR: Expected nothing tried and 1 sighting and 1 anomaly held, got %d writes, %d and %d", flaky.writes-writes, len(s.sightings), len(s.anomalies))
+	}
+
+	// The positions at the end of the scan get through, and take the rest with them.
+	flaky.down = false
+	err = s.savePositions(ctx, nil)
+	if err != nil || len(s.positions) != 0 || len(s.sightings) != 0 || len(s.anomalies) != 0 {
+		t.Errorf("ERROR: Expected everything held to be written, got %v with %d, %d and %d held", err, len(s.positions), len(s.sightings), len(s.anomalies))
+	}
+}
@@ -122,15 +122,14 @@ func ShipsInRegion(latA, lonA, latB, lonB float64, c chan map[string]interface{}
 			continue
 		}
 
-		if web.ToString(response["name"]) != name {
-			// We have a non-existent MMSI.
-			continue
-		}
-
 		response["directLink"] = directLink(web.ToString(response["name"]), web.ToString(response["imo"]), mmsi)
 		response["lat"] = lat
 		response["lon"] = lon
 		response["mmsi"] = mmsi
+		// The name on the map should match the name in the details. If
+		// it does not, the details are for some other (or no) ship. Let
+		// the caller decide what to do about that.
+		response["mapName"] = name
 
 		// Push 'response' to channel.
 		c <- response
@@ -174,12 +173,5 @@ func getShipDetails(mmsi string) (map[string]interface{}, bool) {
 		response[".ns"] = 1
 	}
 
-	if mmsi == "319762000" && web.ToFloat64(response["ss"]) > 100.0 {
-		// The M/Y SAINT NICHOLAS sometimes reports bad AIS data.
-		// It takes the form of course: 360, speed: 102.3, status: at anchor.
-		// Ignore those reports.
-		return nil, false
-	}
-
 	return response, true
 }